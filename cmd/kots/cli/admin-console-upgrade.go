@@ -13,7 +13,7 @@ func AdminConsoleUpgradeCmd() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:           "upgrade",
 		Short:         "Upgrade the admin console to the latest version",
-		Long:          "Upgrade the admin console to the latest version",
+		Long:          "Upgrade the admin console to the latest version. The ingress that was created at install is kept, unless --ingress is set to replace it.",
 		SilenceUsage:  true,
 		SilenceErrors: false,
 		PreRun: func(cmd *cobra.Command, args []string) {
@@ -22,9 +22,21 @@ func AdminConsoleUpgradeCmd() *cobra.Command {
 		RunE: func(cmd *cobra.Command, args []string) error {
 			v := viper.GetViper()
 
+			settings, err := readSettingsFile(v.GetString("settings-file"))
+			if err != nil {
+				return err
+			}
+
+			ingressOptions, err := getIngressOptions(v)
+			if err != nil {
+				return err
+			}
+
 			upgradeOptions := kotsadmtypes.UpgradeOptions{
 				Namespace:  v.GetString("namespace"),
 				Kubeconfig: v.GetString("kubeconfig"),
+				Settings:   settings,
+				Ingress:    ingressOptions,
 			}
 
 			kotsadm.OverrideVersion = v.GetString("kotsadm-tag")
@@ -48,6 +60,8 @@ func AdminConsoleUpgradeCmd() *cobra.Command {
 
 	cmd.Flags().String("kubeconfig", defaultKubeConfig(), "the kubeconfig to use")
 	cmd.Flags().StringP("namespace", "n", "default", "the namespace where the admin console is running")
+	cmd.Flags().String("settings-file", "", "a file with the scheduling and resource settings of the admin console components, replacing the settings that were used before")
+	addIngressFlags(cmd)
 
 	cmd.Flags().String("kotsadm-tag", "", "set to override the tag of kotsadm. this may create an incompatible deployment because the version of kots and kotsadm are designed to work together")
 	cmd.Flags().String("kotsadm-registry", "", "set to override the registry of kotsadm image. this may create an incompatible deployment because the version of kots and kotsadm are designed to work together")
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"reflect"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/appstate"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+func AppStatusCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "app-status [appdir]",
+		Short:         "Show the status of an application from its status informers",
+		Long:          `Show the state of each resource in the statusInformers of the application that was pulled to appdir, and the state of the whole application. The state is one of ready, updating, degraded or unavailable.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			upstreamDir := filepath.Join(ExpandDir(args[0]), "upstream")
+			statusInformers, err := appstate.LoadStatusInformers(upstreamDir, v.GetString("namespace"))
+			if err != nil {
+				return errors.Wrap(err, "failed to load status informers")
+			}
+
+			cfg, err := config.GetConfig()
+			if err != nil {
+				return errors.Wrap(err, "failed to load config")
+			}
+
+			clientset, err := kubernetes.NewForConfig(cfg)
+			if err != nil {
+				return errors.Wrap(err, "failed to create k8s client")
+			}
+
+			stopCh := make(chan struct{})
+			defer close(stopCh)
+
+			monitor := appstate.NewMonitor(clientset, statusInformers)
+
+			if !v.GetBool("watch") {
+				if err := monitor.Start(stopCh, nil); err != nil {
+					return errors.Wrap(err, "failed to start informers")
+				}
+				status, err := monitor.Status()
+				if err != nil {
+					return errors.Wrap(err, "failed to get app status")
+				}
+				return printAppStatus(status, v.GetBool("json"))
+			}
+
+			printErrors := make(chan error, 1)
+			var lastStatus *appstate.AppStatus
+			onChange := func(status appstate.AppStatus) {
+				// informers report each resource when they sync, only print when the status changes
+				if lastStatus != nil && reflect.DeepEqual(*lastStatus, status) {
+					return
+				}
+				lastStatus = &status
+
+				if err := printAppStatus(status, v.GetBool("json")); err != nil {
+					select {
+					case printErrors <- err:
+					default:
+					}
+				}
+			}
+			if err := monitor.Start(stopCh, onChange); err != nil {
+				return errors.Wrap(err, "failed to start informers")
+			}
+
+			interrupt := make(chan os.Signal, 1)
+			signal.Notify(interrupt, os.Interrupt)
+			select {
+			case <-interrupt:
+				return nil
+			case err := <-printErrors:
+				return err
+			}
+		},
+	}
+
+	cmd.Flags().StringP("namespace", "n", "default", "namespace of the status informers that don't set one")
+	cmd.Flags().Bool("watch", false, "print the status each time a resource changes, until interrupted")
+	cmd.Flags().Bool("json", false, "print the status as json")
+
+	return cmd
+}
+
+func printAppStatus(status appstate.AppStatus, asJSON bool) error {
+	if asJSON {
+		b, err := json.Marshal(status)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal app status")
+		}
+		fmt.Println(string(b))
+		return nil
+	}
+
+	fmt.Printf("App state: %s\n", status.State)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "NAMESPACE\tKIND\tNAME\tSTATE")
+	for _, resourceState := range status.ResourceStates {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", resourceState.Namespace, resourceState.Kind, resourceState.Name, resourceState.State)
+	}
+	return w.Flush()
+}
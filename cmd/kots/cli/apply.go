@@ -0,0 +1,168 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/apply"
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/helmrelease"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+func ApplyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "apply [downstream dir]",
+		Short:         "Build a downstream and apply it to the cluster",
+		Long:          `Build the downstream (or midstream) of an application that was pulled with kots pull, and apply it with server-side apply in install order. Objects from the previous apply that are no longer rendered are deleted, and the command waits for the applied workloads to be ready. Pre-install and pre-upgrade hooks run before the other objects are applied and post-install and post-upgrade hooks after them, each one waited for until its job or pod completes and deleted by its delete policy. Rollback, delete and test hooks are not run. Charts with useHelmInstall are installed or upgraded as helm releases after the other objects. Their images are not rewritten by the midstream, and releases are not removed when their chart is removed from the app.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			downstreamDir := ExpandDir(args[0])
+			appDir, inAppDir := appDirFromOverlayDir(downstreamDir)
+			name := v.GetString("name")
+			if name == "" {
+				name = filepath.Base(appDir)
+			}
+
+			helmReleases := []base.HelmRelease{}
+			if inAppDir {
+				releases, err := base.ReadHelmReleases(filepath.Join(appDir, "base"))
+				if err != nil {
+					return errors.Wrap(err, "failed to read helm releases")
+				}
+				helmReleases = releases
+			}
+
+			log := logger.NewLogger()
+
+			manifests, err := apply.BuildDownstream(downstreamDir, v.GetString("kustomize"))
+			if err != nil {
+				return errors.Wrap(err, "failed to build downstream")
+			}
+
+			cfg, err := config.GetConfig()
+			if err != nil {
+				return errors.Wrap(err, "failed to load config")
+			}
+
+			applier, err := apply.NewApplier(cfg)
+			if err != nil {
+				return errors.Wrap(err, "failed to create applier")
+			}
+
+			options := apply.Options{
+				Name:        name,
+				Namespace:   v.GetString("namespace"),
+				DryRun:      v.GetBool("dry-run"),
+				Prune:       v.GetBool("prune"),
+				Wait:        v.GetBool("wait"),
+				WaitTimeout: v.GetDuration("timeout"),
+			}
+
+			log.ActionWithSpinner("Applying %s", name)
+			result, err := applier.Apply(manifests, options)
+			if err != nil && result == nil {
+				log.FinishSpinnerWithError()
+				return errors.Wrap(err, "failed to apply")
+			}
+			if err != nil {
+				log.FinishSpinnerWithError()
+			} else {
+				log.FinishSpinner()
+			}
+
+			suffix := ""
+			if options.DryRun {
+				suffix = " (dry run)"
+			}
+			for _, ref := range result.Applied {
+				fmt.Printf("%s applied%s\n", ref, suffix)
+			}
+			for _, ref := range result.Pruned {
+				fmt.Printf("%s pruned%s\n", ref, suffix)
+			}
+			if result.Status != nil {
+				fmt.Printf("App state: %s\n", result.Status.State)
+			}
+			if err != nil {
+				return err
+			}
+
+			if len(helmReleases) == 0 {
+				return nil
+			}
+			if options.DryRun {
+				for _, helmRelease := range helmReleases {
+					fmt.Printf("helm release %s installed or upgraded%s\n", helmRelease.Name, suffix)
+				}
+				return nil
+			}
+
+			engine, err := helmrelease.NewEngine(cfg, options.Namespace)
+			if err != nil {
+				return errors.Wrap(err, "failed to create helm release engine")
+			}
+			if options.WaitTimeout != 0 {
+				engine.HookTimeout = options.WaitTimeout
+			}
+
+			for _, helmRelease := range helmReleases {
+				log.ActionWithSpinner("Installing helm release %s", helmRelease.Name)
+				release, err := engine.Upgrade(&helmRelease)
+				if err != nil {
+					log.FinishSpinnerWithError()
+					return errors.Wrapf(err, "failed to install helm release %s", helmRelease.Name)
+				}
+				log.FinishSpinner()
+				fmt.Printf("helm release %s revision %d deployed in namespace %s\n", release.Name, release.Revision, release.Namespace)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("namespace", "n", "default", "namespace for objects that don't set one, and for the inventory of applied objects")
+	cmd.Flags().String("name", "", "name of the app in the inventory of applied objects (defaults to the name of the app dir)")
+	cmd.Flags().Bool("dry-run", false, "send the objects to the server with a dry run, and only report the objects that would be pruned. Custom resources whose definitions are in the manifests are not sent, since the definitions are not created")
+	cmd.Flags().Bool("prune", true, "delete objects from the previous apply that are no longer rendered")
+	cmd.Flags().Bool("wait", true, "wait for the applied workloads to be ready")
+	cmd.Flags().Duration("timeout", apply.DefaultWaitTimeout, "how long to wait for the applied workloads to be ready")
+	cmd.Flags().String("kustomize", apply.DefaultKustomizePath, "the kustomize binary to build the downstream with, kubectl kustomize is used if it's not found")
+
+	return cmd
+}
+
+// appDirFromOverlayDir returns the app dir that the overlay dir is in, such as my-app for
+// my-app/overlays/downstreams/prod. If it's not in an overlays dir, the dir itself is returned
+// and false.
+func appDirFromOverlayDir(dir string) (string, bool) {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		abs = dir
+	}
+
+	parts := strings.Split(filepath.ToSlash(abs), "/")
+	for i := len(parts) - 1; i > 0; i-- {
+		if parts[i] == "overlays" {
+			return filepath.FromSlash(strings.Join(parts[:i], "/")), true
+		}
+	}
+	return abs, false
+}
@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func ConfigExportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "export",
+		Short:         "Export the config values of a pulled application",
+		Long:          `Write the config values as a ConfigValues document. Password values are written decrypted so they can be imported into another installation.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			appConfig, err := config.LoadAppConfig(ExpandDir(v.GetString("appdir")))
+			if err != nil {
+				return errors.Wrap(err, "failed to load app config")
+			}
+
+			exported, err := appConfig.ExportValues()
+			if err != nil {
+				return errors.Wrap(err, "failed to export config values")
+			}
+
+			s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+			var b bytes.Buffer
+			if err := s.Encode(exported, &b); err != nil {
+				return errors.Wrap(err, "failed to marshal config values")
+			}
+
+			if v.GetString("output") == "" {
+				_, err := os.Stdout.Write(b.Bytes())
+				return err
+			}
+
+			if err := ioutil.WriteFile(ExpandDir(v.GetString("output")), b.Bytes(), 0600); err != nil {
+				return errors.Wrap(err, "failed to write config values")
+			}
+
+			return nil
+		},
+	}
+
+	addConfigFlags(cmd)
+	cmd.Flags().StringP("output", "o", "", "file to write the config values to (defaults to stdout)")
+
+	return cmd
+}
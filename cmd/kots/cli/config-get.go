@@ -0,0 +1,49 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func ConfigGetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "get [name]",
+		Short:         "Print the value of a config item",
+		Long:          `Print the value of a config item, or its default when no value has been set. Password values are printed decrypted.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			appConfig, err := config.LoadAppConfig(ExpandDir(v.GetString("appdir")))
+			if err != nil {
+				return errors.Wrap(err, "failed to load app config")
+			}
+
+			value, err := appConfig.GetValue(args[0])
+			if err != nil {
+				return errors.Wrap(err, "failed to get config value")
+			}
+
+			fmt.Println(value)
+			return nil
+		},
+	}
+
+	addConfigFlags(cmd)
+
+	return cmd
+}
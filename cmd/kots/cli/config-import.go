@@ -0,0 +1,64 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func ConfigImportCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "import [config values file]",
+		Short:         "Import config values into a pulled application",
+		Long:          `Validate every value in a ConfigValues document, as written by kots config export, and write them to upstream/userdata/config.yaml. Password values are encrypted with the installation key.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			content, err := ioutil.ReadFile(ExpandDir(args[0]))
+			if err != nil {
+				return errors.Wrap(err, "failed to read config values file")
+			}
+
+			decode := scheme.Codecs.UniversalDeserializer().Decode
+			obj, gvk, err := decode(content, nil, nil)
+			if err != nil {
+				return errors.Wrap(err, "failed to decode config values file")
+			}
+			if gvk.Group != "kots.io" || gvk.Version != "v1beta1" || gvk.Kind != "ConfigValues" {
+				return errors.New("not a config values file")
+			}
+
+			appConfig, err := config.LoadAppConfig(ExpandDir(v.GetString("appdir")))
+			if err != nil {
+				return errors.Wrap(err, "failed to load app config")
+			}
+
+			if err := appConfig.ImportValues(obj.(*kotsv1beta1.ConfigValues)); err != nil {
+				return errors.Wrap(err, "failed to import config values")
+			}
+
+			return saveAndRenderConfig(v, appConfig)
+		},
+	}
+
+	addConfigFlags(cmd)
+	addConfigRenderFlags(cmd)
+
+	return cmd
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"io/ioutil"
+	"os"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func ConfigSchemaCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "schema [config file]",
+		Short:         "Print the JSON Schema for the config values of an application",
+		Long:          `Convert the Config spec of a pulled application, or the given Config file, to a JSON Schema or OpenAPI document that validates ConfigValues.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			var configSpec *kotsv1beta1.Config
+			if len(args) > 0 {
+				content, err := ioutil.ReadFile(ExpandDir(args[0]))
+				if err != nil {
+					return errors.Wrap(err, "failed to read config file")
+				}
+
+				decode := scheme.Codecs.UniversalDeserializer().Decode
+				obj, gvk, err := decode(content, nil, nil)
+				if err != nil {
+					return errors.Wrap(err, "failed to decode config file")
+				}
+				if gvk.Group != "kots.io" || gvk.Version != "v1beta1" || gvk.Kind != "Config" {
+					return errors.New("not a config file")
+				}
+				configSpec = obj.(*kotsv1beta1.Config)
+			} else {
+				appConfig, err := config.LoadAppConfig(ExpandDir(v.GetString("appdir")))
+				if err != nil {
+					return errors.Wrap(err, "failed to load app config")
+				}
+				configSpec = appConfig.Config
+			}
+
+			b, err := config.MarshalConfigSchema(configSpec, v.GetString("format"))
+			if err != nil {
+				return errors.Wrap(err, "failed to create schema")
+			}
+			b = append(b, '\n')
+
+			if v.GetString("output") == "" {
+				_, err := os.Stdout.Write(b)
+				return err
+			}
+
+			if err := ioutil.WriteFile(ExpandDir(v.GetString("output")), b, 0644); err != nil {
+				return errors.Wrap(err, "failed to write schema")
+			}
+
+			return nil
+		},
+	}
+
+	addConfigFlags(cmd)
+	cmd.Flags().String("format", "json-schema", "the schema format to print, json-schema or openapi")
+	cmd.Flags().StringP("output", "o", "", "file to write the schema to (defaults to stdout)")
+
+	return cmd
+}
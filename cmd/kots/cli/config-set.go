@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func ConfigSetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "set [name] [value]",
+		Short:         "Set the value of a config item",
+		Long:          `Validate a value against the config item and write it to upstream/userdata/config.yaml. Password values are encrypted with the installation key.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 2 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			appConfig, err := config.LoadAppConfig(ExpandDir(v.GetString("appdir")))
+			if err != nil {
+				return errors.Wrap(err, "failed to load app config")
+			}
+
+			if err := appConfig.SetValue(args[0], args[1]); err != nil {
+				return errors.Wrap(err, "failed to set config value")
+			}
+
+			return saveAndRenderConfig(v, appConfig)
+		},
+	}
+
+	addConfigFlags(cmd)
+	addConfigRenderFlags(cmd)
+
+	return cmd
+}
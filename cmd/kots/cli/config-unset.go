@@ -0,0 +1,47 @@
+package cli
+
+import (
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/config"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func ConfigUnsetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "unset [name]",
+		Short:         "Remove the value of a config item",
+		Long:          `Remove the value of a config item from upstream/userdata/config.yaml so that its default is used.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			appConfig, err := config.LoadAppConfig(ExpandDir(v.GetString("appdir")))
+			if err != nil {
+				return errors.Wrap(err, "failed to load app config")
+			}
+
+			if err := appConfig.UnsetValue(args[0]); err != nil {
+				return errors.Wrap(err, "failed to unset config value")
+			}
+
+			return saveAndRenderConfig(v, appConfig)
+		},
+	}
+
+	addConfigFlags(cmd)
+	addConfigRenderFlags(cmd)
+
+	return cmd
+}
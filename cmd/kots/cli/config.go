@@ -0,0 +1,108 @@
+package cli
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/config"
+	"github.com/replicatedhq/kots/pkg/rewrite"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func ConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "config",
+		Short:         "Get, set and export config values of a pulled application",
+		Long:          `Read and change the config values in upstream/userdata/config.yaml of an application that was pulled to the local filesystem.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			return nil
+		},
+	}
+
+	cmd.AddCommand(ConfigGetCmd())
+	cmd.AddCommand(ConfigSetCmd())
+	cmd.AddCommand(ConfigUnsetCmd())
+	cmd.AddCommand(ConfigExportCmd())
+	cmd.AddCommand(ConfigImportCmd())
+	cmd.AddCommand(ConfigSchemaCmd())
+
+	return cmd
+}
+
+func addConfigFlags(cmd *cobra.Command) {
+	cmd.Flags().String("appdir", ".", "the directory of the pulled application")
+}
+
+func addConfigRenderFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("render", false, "when set, re-render the base, midstream and downstreams with the new values")
+	cmd.Flags().StringP("namespace", "n", "default", "namespace to render the upstream to in the base (used with --render)")
+	cmd.Flags().Bool("exclude-kots-kinds", true, "set to true to exclude rendering kots custom objects to the base directory (used with --render)")
+}
+
+// saveAndRenderConfig writes the config values and, if requested, re-renders the
+// application from its upstream with them.
+func saveAndRenderConfig(v *viper.Viper, appConfig *config.AppConfig) error {
+	if err := appConfig.Save(); err != nil {
+		return errors.Wrap(err, "failed to save config values")
+	}
+
+	if !v.GetBool("render") {
+		return nil
+	}
+
+	if appConfig.License == nil {
+		return errors.New("a license is required in upstream/userdata to render the application")
+	}
+	if appConfig.Installation == nil {
+		return errors.New("an installation is required in upstream/userdata to render the application")
+	}
+
+	return renderAppDir(v, appConfig.AppDir, appConfig.License, appConfig.Installation, appConfig.ConfigValues)
+}
+
+// renderAppDir re-renders the base, midstream and the existing downstreams of an app dir from its upstream
+func renderAppDir(v *viper.Viper, appDir string, license *kotsv1beta1.License, installation *kotsv1beta1.Installation, configValues *kotsv1beta1.ConfigValues) error {
+	downstreams := []string{}
+	downstreamDirs, err := ioutil.ReadDir(filepath.Join(appDir, "overlays", "downstreams"))
+	if err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to read downstreams")
+	}
+	for _, downstreamDir := range downstreamDirs {
+		if downstreamDir.IsDir() {
+			downstreams = append(downstreams, downstreamDir.Name())
+		}
+	}
+
+	rewriteOptions := rewrite.RewriteOptions{
+		RootDir:          appDir,
+		UpstreamURI:      fmt.Sprintf("replicated://%s", license.Spec.AppSlug),
+		UpstreamPath:     filepath.Join(appDir, "upstream"),
+		Installation:     installation,
+		Downstreams:      downstreams,
+		CreateAppDir:     false,
+		ExcludeKotsKinds: v.GetBool("exclude-kots-kinds"),
+		License:          license,
+		ConfigValues:     configValues,
+		K8sNamespace:     v.GetString("namespace"),
+	}
+	if err := rewrite.Rewrite(rewriteOptions); err != nil {
+		return errors.Wrap(err, "failed to render application")
+	}
+
+	return nil
+}
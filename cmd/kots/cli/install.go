@@ -47,6 +47,16 @@ func InstallCmd() *cobra.Command {
 
 			log := logger.NewLogger()
 
+			settings, err := readSettingsFile(v.GetString("settings-file"))
+			if err != nil {
+				return err
+			}
+
+			ingressOptions, err := getIngressOptions(v)
+			if err != nil {
+				return err
+			}
+
 			rootDir, err := ioutil.TempDir("", "kotsadm")
 			if err != nil {
 				return errors.Wrap(err, "failed to create temp dir")
@@ -79,9 +89,10 @@ func InstallCmd() *cobra.Command {
 			kotsadm.OverrideNamespace = v.GetString("kotsadm-namespace")
 
 			pullOptions := pull.PullOptions{
-				HelmRepoURI: v.GetString("repo"),
-				RootDir:     rootDir,
-				Namespace:   namespace,
+				HelmRepoURI:         v.GetString("repo"),
+				HelmRepoCredentials: getHelmRepoCredentials(v),
+				RootDir:             rootDir,
+				Namespace:           namespace,
 				Downstreams: []string{
 					"this-cluster", // this is the auto-generated operator downstream
 				},
@@ -124,6 +135,25 @@ func InstallCmd() *cobra.Command {
 				NodePort:            v.GetInt32("node-port"),
 				Hostname:            v.GetString("hostname"),
 				ApplicationMetadata: applicationMetadata,
+				ExternalPostgresURI: v.GetString("external-postgres-uri"),
+				ExternalS3Endpoint:  v.GetString("external-s3-endpoint"),
+				ExternalS3Bucket:    v.GetString("external-s3-bucket"),
+				S3AccessKey:         v.GetString("external-s3-access-key"),
+				S3SecretKey:         v.GetString("external-s3-secret-key"),
+				MinimalRBAC:         v.GetBool("minimal-rbac"),
+				AppNamespaces:       v.GetStringSlice("app-namespace"),
+			}
+			if settings != nil {
+				deployOptions.Settings = *settings
+			}
+			if ingressOptions != nil {
+				deployOptions.Ingress = *ingressOptions
+			}
+
+			if deployOptions.MinimalRBAC {
+				if err := printMinimalRBACReport(deployOptions, log); err != nil {
+					return err
+				}
 			}
 
 			log.ActionWithoutSpinner("Deploying Admin Console")
@@ -207,6 +237,9 @@ func InstallCmd() *cobra.Command {
 			log.ActionWithoutSpinner("")
 			log.ActionWithoutSpinner("Press Ctrl+C to exit")
 			log.ActionWithoutSpinner("Go to http://localhost:8800 to access the Admin Console")
+			if ingressOptions != nil {
+				log.ActionWithoutSpinner("The Admin Console is also available at https://%s", ingressOptions.Host)
+			}
 			log.ActionWithoutSpinner("")
 
 			signalChan := make(chan os.Signal, 1)
@@ -232,10 +265,20 @@ func InstallCmd() *cobra.Command {
 	cmd.Flags().Int32("node-port", 0, "the nodeport to assign to the service, when service-type is set to NodePort")
 	cmd.Flags().String("hostname", "localhost:8800", "the hostname to that the admin console will be exposed on")
 	cmd.Flags().String("name", "", "name of the application to use in the Admin Console")
+	cmd.Flags().String("external-postgres-uri", "", "the uri of a postgres database for the admin console to use instead of deploying postgres")
+	cmd.Flags().String("external-s3-endpoint", "", "the endpoint of an s3 compatible object store for the admin console to use instead of deploying minio")
+	cmd.Flags().String("external-s3-bucket", "", "the bucket in the external object store (required with --external-s3-endpoint)")
+	cmd.Flags().String("external-s3-access-key", "", "the access key of the external object store (required with --external-s3-endpoint)")
+	cmd.Flags().String("external-s3-secret-key", "", "the secret key of the external object store (required with --external-s3-endpoint)")
+	cmd.Flags().String("settings-file", "", "a file with the scheduling and resource settings of the admin console components")
+	addIngressFlags(cmd)
+	cmd.Flags().Bool("minimal-rbac", false, "create only namespaced roles with the permissions that the application needs, instead of a cluster role")
+	cmd.Flags().StringSlice("app-namespace", []string{}, "a namespace that the application is deployed to, in addition to --namespace (with --minimal-rbac)")
 	cmd.Flags().String("local-path", "", "specify a local-path to test the behavior of rendering a replicated app locally (only supported on replicated app types currently)")
 	cmd.Flags().String("license-file", "", "path to a license file to use when download a replicated app")
 
 	cmd.Flags().String("repo", "", "repo uri to use when installing a helm chart")
+	addHelmRepoFlags(cmd)
 	cmd.Flags().StringSlice("set", []string{}, "values to pass to helm when running helm template")
 
 	cmd.Flags().String("kotsadm-tag", "", "set to override the tag of kotsadm. this may create an incompatible deployment because the version of kots and kotsadm are designed to work together")
@@ -252,6 +295,122 @@ func InstallCmd() *cobra.Command {
 	return cmd
 }
 
+// readSettingsFile reads an admin console settings file, or returns nil if no file is given
+func readSettingsFile(filename string) (*kotsadmtypes.AdminConsoleSettings, error) {
+	if filename == "" {
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(ExpandDir(filename))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read settings file")
+	}
+
+	settings, err := kotsadm.ParseSettings(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse settings file %s", filename)
+	}
+
+	return settings, nil
+}
+
+func addIngressFlags(cmd *cobra.Command) {
+	cmd.Flags().Bool("ingress", false, "create an ingress with tls for the admin console")
+	cmd.Flags().String("ingress-host", "", "the host of the admin console ingress (required with --ingress)")
+	cmd.Flags().String("ingress-class", "", "the ingress class of the admin console ingress")
+	cmd.Flags().StringSlice("ingress-annotation", []string{}, "an annotation to add to the admin console ingress, as key=value")
+	cmd.Flags().String("ingress-tls-cert", "", "a PEM encoded certificate for the admin console ingress (a self-signed certificate is generated when not set)")
+	cmd.Flags().String("ingress-tls-key", "", "the PEM encoded private key of --ingress-tls-cert")
+}
+
+// getIngressOptions reads the ingress flags, or returns nil if no ingress is created
+func getIngressOptions(v *viper.Viper) (*kotsadmtypes.IngressOptions, error) {
+	if !v.GetBool("ingress") {
+		return nil, nil
+	}
+
+	ingressOptions := kotsadmtypes.IngressOptions{
+		Enabled:      true,
+		Host:         v.GetString("ingress-host"),
+		IngressClass: v.GetString("ingress-class"),
+	}
+	if ingressOptions.Host == "" {
+		return nil, errors.New("--ingress-host is required with --ingress")
+	}
+
+	for _, annotation := range v.GetStringSlice("ingress-annotation") {
+		parts := strings.SplitN(annotation, "=", 2)
+		if len(parts) != 2 {
+			return nil, errors.Errorf("ingress annotation %q is not formatted as key=value", annotation)
+		}
+		if ingressOptions.Annotations == nil {
+			ingressOptions.Annotations = map[string]string{}
+		}
+		ingressOptions.Annotations[parts[0]] = parts[1]
+	}
+
+	certFile := v.GetString("ingress-tls-cert")
+	keyFile := v.GetString("ingress-tls-key")
+	if (certFile == "") != (keyFile == "") {
+		return nil, errors.New("--ingress-tls-cert and --ingress-tls-key must be set together")
+	}
+	if certFile != "" {
+		cert, err := ioutil.ReadFile(ExpandDir(certFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read tls certificate")
+		}
+		key, err := ioutil.ReadFile(ExpandDir(keyFile))
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read tls key")
+		}
+		ingressOptions.TLSCert = cert
+		ingressOptions.TLSKey = key
+	}
+
+	return &ingressOptions, nil
+}
+
+// printMinimalRBACReport prints what the application can't do with minimal rbac, and fails if the
+// installer doesn't have the permissions that the roles grant
+func printMinimalRBACReport(deployOptions kotsadmtypes.DeployOptions, log *logger.Logger) error {
+	report, err := kotsadm.CheckMinimalRBAC(deployOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to check minimal rbac")
+	}
+
+	log.ActionWithoutSpinner("Installing with minimal rbac, the application has these limitations:")
+	for _, limitation := range report.Limitations {
+		log.ChildActionWithoutSpinner(limitation)
+	}
+	log.ActionWithoutSpinner("")
+
+	if len(report.ClusterRBAC) > 0 {
+		log.ActionWithoutSpinner("The operator is removed from the cluster rbac of an earlier install:")
+		for _, clusterRBAC := range report.ClusterRBAC {
+			log.ChildActionWithoutSpinner(clusterRBAC)
+		}
+		log.ActionWithoutSpinner("")
+	}
+
+	if len(report.Missing) == 0 {
+		return nil
+	}
+
+	log.ActionWithoutSpinner("The current user is missing these permissions, which are required to grant the roles:")
+	for _, missing := range report.Missing {
+		log.ChildActionWithoutSpinner(missing)
+	}
+	log.ActionWithoutSpinner("")
+
+	if report.Incomplete {
+		// the cluster couldn't list every permission, so they may be granted by another authorizer
+		log.ActionWithoutSpinner("The permissions of the current user could not all be listed, continuing")
+		return nil
+	}
+
+	return errors.New("missing permissions for minimal rbac")
+}
+
 func promptForNamespace(upstreamURI string) (string, error) {
 	u, err := url.ParseRequestURI(upstreamURI)
 	if err != nil {
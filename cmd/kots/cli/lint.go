@@ -0,0 +1,60 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/policy"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func LintCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "lint [appdir]",
+		Short:         "Check the rendered manifests of an application against policy rules",
+		Long:          `Check the base, midstream and downstream manifests of an application that was pulled with kots pull against the built-in policy rules and the rules in the --policy file. The command fails if any rule with the error severity is violated.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			policyFile := v.GetString("policy")
+			if policyFile != "" {
+				policyFile = ExpandDir(policyFile)
+			}
+
+			rules, err := policy.LoadRules(policyFile)
+			if err != nil {
+				return errors.Wrap(err, "failed to load policy rules")
+			}
+
+			violations, err := policy.CheckDir(rules, ExpandDir(args[0]))
+			if err != nil {
+				return errors.Wrap(err, "failed to check policy")
+			}
+
+			for _, violation := range violations {
+				fmt.Println(violation.String())
+			}
+
+			if errorCount := policy.CountErrors(violations); errorCount > 0 {
+				return errors.Errorf("found %d policy violations with the error severity", errorCount)
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().String("policy", "", "path to a file with custom policy rules, and severity overrides for the built-in rules")
+
+	return cmd
+}
@@ -4,6 +4,9 @@ import (
 	"os"
 	"path"
 
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/gitops"
+	"github.com/replicatedhq/kots/pkg/history"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/pull"
 	"github.com/spf13/cobra"
@@ -31,11 +34,15 @@ func PullCmd() *cobra.Command {
 			// registry host should not have the scheme (https).  need to
 			// strip it if included or else the rewrite images will fail
 
+			downstreams, downstreamSpecFiles := parseDownstreams(v.GetStringSlice("downstream"))
+
 			pullOptions := pull.PullOptions{
 				HelmRepoURI:         v.GetString("repo"),
+				HelmRepoCredentials: getHelmRepoCredentials(v),
 				RootDir:             ExpandDir(v.GetString("rootdir")),
 				Namespace:           v.GetString("namespace"),
-				Downstreams:         v.GetStringSlice("downstream"),
+				Downstreams:         downstreams,
+				DownstreamSpecFiles: downstreamSpecFiles,
 				LocalPath:           ExpandDir(v.GetString("local-path")),
 				LicenseFile:         ExpandDir(v.GetString("license-file")),
 				ExcludeKotsKinds:    v.GetBool("exclude-kots-kinds"),
@@ -43,11 +50,31 @@ func PullCmd() *cobra.Command {
 				SharedPassword:      v.GetString("shared-password"),
 				CreateAppDir:        true,
 				HelmOptions:         v.GetStringSlice("set"),
+				KubeVersion:         v.GetString("kube-version"),
+				APIVersions:         v.GetStringSlice("api-versions"),
+				KeepVersions:        v.GetInt("keep-versions"),
 				RewriteImages:       v.GetBool("rewrite-images"),
+				StrictYAML:          v.GetBool("strict-yaml"),
 				RewriteImageOptions: pull.RewriteImageOptions{
 					Host:      v.GetString("registry-endpoint"),
 					Namespace: v.GetString("image-namespace"),
 				},
+				ReportWriter: os.Stdout,
+			}
+
+			if repo := v.GetString("gitops-repo"); repo != "" {
+				pullOptions.GitOps = &gitops.Options{
+					RepoURI:   repo,
+					Branch:    v.GetString("gitops-branch"),
+					Path:      v.GetString("gitops-path"),
+					NewBranch: v.GetString("gitops-new-branch"),
+					Auth: gitops.Auth{
+						SSHPrivateKeyFile: ExpandDir(v.GetString("gitops-ssh-key")),
+						SSHKnownHostsFile: ExpandDir(v.GetString("gitops-known-hosts")),
+						HTTPSUsername:     v.GetString("gitops-username"),
+						HTTPSPassword:     v.GetString("gitops-password"),
+					},
+				}
 			}
 
 			upstream := pull.RewriteUpstream(args[0])
@@ -59,10 +86,10 @@ func PullCmd() *cobra.Command {
 			log := logger.NewLogger()
 			log.Initialize()
 			log.Info("Kubernetes application files created in %s", renderDir)
-			if len(v.GetStringSlice("downstream")) == 0 {
+			if len(downstreams) == 0 {
 				log.Info("To deploy, run kubectl apply -k %s", path.Join(renderDir, "overlays", "midstream"))
-			} else if len(v.GetStringSlice("downstream")) == 1 {
-				log.Info("To deploy, run kubectl apply -k %s", path.Join(renderDir, "overlays", "downstreams", v.GetStringSlice("downstream")[0]))
+			} else if len(downstreams) == 1 {
+				log.Info("To deploy, run kubectl apply -k %s", path.Join(renderDir, "overlays", "downstreams", downstreams[0]))
 			} else {
 				log.Info("To deploy, run kubectl apply -k from the downstream directory you would like to deploy")
 			}
@@ -72,18 +99,31 @@ func PullCmd() *cobra.Command {
 	}
 
 	cmd.Flags().StringSlice("set", []string{}, "values to pass to helm when running helm template")
+	cmd.Flags().String("kube-version", base.DefaultKubeVersion, "kubernetes version to use for Capabilities.KubeVersion when rendering helm charts")
+	cmd.Flags().StringSlice("api-versions", []string{}, "kubernetes api versions to add to Capabilities.APIVersions when rendering helm charts")
 	cmd.Flags().String("repo", "", "repo uri to use when downloading a helm chart")
+	addHelmRepoFlags(cmd)
 	cmd.Flags().String("rootdir", homeDir(), "root directory that will be used to write the yaml to")
 	cmd.Flags().StringP("namespace", "n", "default", "namespace to render the upstream to in the base")
-	cmd.Flags().StringSlice("downstream", []string{}, "the list of any downstreams to create/update")
+	cmd.Flags().StringSlice("downstream", []string{}, "the list of any downstreams to create/update, as name or name=path to a downstream spec file")
 	cmd.Flags().String("local-path", "", "specify a local-path to pull a locally available replicated app (only supported on replicated app types currently)")
 	cmd.Flags().String("license-file", "", "path to a license file to use when download a replicated app")
 	cmd.Flags().Bool("exclude-kots-kinds", true, "set to true to exclude rendering kots custom objects to the base directory")
 	cmd.Flags().Bool("exclude-admin-console", false, "set to true to exclude the admin console (replicated apps only)")
 	cmd.Flags().String("shared-password", "", "shared password to use when deploying the admin console")
+	cmd.Flags().Int("keep-versions", history.DefaultKeepVersions, "how many versions of the upstream and base of a replicated app to keep in its history, so they can be rolled back to. 0 disables the history")
+	cmd.Flags().Bool("strict-yaml", false, "set to true to fail when a yaml document in the base can't be parsed, instead of skipping its images with a warning")
 	cmd.Flags().Bool("rewrite-images", false, "set to true to force all container images to be rewritten and pushed to a local registry")
 	cmd.Flags().String("image-namespace", "", "the namespace/org in the docker registry to push images to (required when --rewrite-images is set)")
 	cmd.Flags().String("registry-endpoint", "", "the endpoint of the local docker registry to use when pushing images (required when --rewrite-images is set)")
+	cmd.Flags().String("gitops-repo", "", "the uri of a git repo to commit the base and overlays of the app to")
+	cmd.Flags().String("gitops-branch", gitops.DefaultBranch, "the branch of the gitops repo to commit to")
+	cmd.Flags().String("gitops-path", "", "the path in the gitops repo to write the app to")
+	cmd.Flags().String("gitops-new-branch", "", "create this branch from --gitops-branch and push the change to it, so it can be reviewed before it's merged")
+	cmd.Flags().String("gitops-ssh-key", "", "the private key to use when the gitops repo uri is an ssh uri")
+	cmd.Flags().String("gitops-known-hosts", "", "the known hosts file to verify the gitops repo host with when using ssh")
+	cmd.Flags().String("gitops-username", "", "the username to use when the gitops repo uri is an https uri")
+	cmd.Flags().String("gitops-password", "", "the password or token to use when the gitops repo uri is an https uri")
 
 	return cmd
 }
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/history"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+func RollbackCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "rollback [sequence]",
+		Short:         "Roll back a pulled application to a previous version",
+		Long:          `Restore the upstream and base of a previous version from the history of an application that was pulled to the local filesystem, and re-render the midstream and downstreams. Rollback is refused when the current release doesn't allow it. The history is only kept for replicated apps, which are the only releases that can allow rollback.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			appDir := ExpandDir(v.GetString("appdir"))
+
+			if v.GetBool("list") {
+				return printVersions(appDir)
+			}
+
+			if len(args) != 1 {
+				cmd.Help()
+				os.Exit(1)
+			}
+
+			sequence, err := strconv.ParseInt(args[0], 10, 64)
+			if err != nil {
+				return errors.Wrapf(err, "failed to parse sequence %q", args[0])
+			}
+
+			log := logger.NewLogger()
+			log.ActionWithSpinner("Restoring version %d", sequence)
+			version, err := history.Rollback(appDir, sequence)
+			if err != nil {
+				log.FinishSpinnerWithError()
+				return errors.Wrap(err, "failed to roll back")
+			}
+			log.FinishSpinner()
+
+			kotsKinds, err := history.LoadKotsKinds(filepath.Join(appDir, "upstream"))
+			if err != nil {
+				return errors.Wrap(err, "failed to load restored release")
+			}
+			if kotsKinds.License == nil {
+				return errors.New("a license is required in upstream/userdata to render the application")
+			}
+			if kotsKinds.Installation == nil {
+				return errors.New("an installation is required in upstream/userdata to render the application")
+			}
+
+			if err := renderAppDir(v, appDir, kotsKinds.License, kotsKinds.Installation, kotsKinds.ConfigValues); err != nil {
+				return err
+			}
+
+			log.Info("Rolled back to version %d (%s)", version.Sequence, versionName(*version))
+			return nil
+		},
+	}
+
+	cmd.Flags().String("appdir", ".", "the directory of the pulled application")
+	cmd.Flags().Bool("list", false, "list the versions in the history of the application")
+	cmd.Flags().StringP("namespace", "n", "default", "namespace to render the upstream to in the base")
+	cmd.Flags().Bool("exclude-kots-kinds", true, "set to true to exclude rendering kots custom objects to the base directory")
+
+	return cmd
+}
+
+func printVersions(appDir string) error {
+	versions, err := history.List(appDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to list versions")
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "SEQUENCE\tVERSION\tCREATED")
+	for _, version := range versions {
+		fmt.Fprintf(w, "%d\t%s\t%s\n", version.Sequence, versionName(version), version.CreatedAt.Format("2006-01-02 15:04:05"))
+	}
+	return w.Flush()
+}
+
+// versionName is the version label of the version, or its update cursor if it doesn't have one
+func versionName(version history.Version) string {
+	if version.VersionLabel != "" {
+		return version.VersionLabel
+	}
+	return version.UpdateCursor
+}
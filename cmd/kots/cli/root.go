@@ -25,6 +25,12 @@ func RootCmd() *cobra.Command {
 	cmd.AddCommand(UploadCmd())
 	cmd.AddCommand(DownloadCmd())
 	cmd.AddCommand(UpstreamCmd())
+	cmd.AddCommand(ConfigCmd())
+	cmd.AddCommand(LintCmd())
+	cmd.AddCommand(AppStatusCmd())
+	cmd.AddCommand(ApplyCmd())
+	cmd.AddCommand(RollbackCmd())
+	cmd.AddCommand(UninstallCmd())
 	cmd.AddCommand(AdminConsoleCmd())
 	cmd.AddCommand(ResetPasswordCmd())
 	cmd.AddCommand(VersionCmd())
@@ -0,0 +1,138 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/manifoldco/promptui"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/apply"
+	"github.com/replicatedhq/kots/pkg/kotsadm"
+	"github.com/replicatedhq/kots/pkg/kotsadm/types"
+	"github.com/replicatedhq/kots/pkg/logger"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+func UninstallCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:           "uninstall",
+		Short:         "Remove the admin console, and optionally the applications, from a cluster",
+		Long:          `Delete the resources of the admin console in a namespace, and the cluster roles of its operator. With --include-apps, the objects that were applied with kots apply are deleted too. The resources are listed before anything is deleted.`,
+		SilenceUsage:  true,
+		SilenceErrors: false,
+		PreRun: func(cmd *cobra.Command, args []string) {
+			viper.BindPFlags(cmd.Flags())
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			v := viper.GetViper()
+
+			log := logger.NewLogger()
+
+			uninstallOptions := types.UninstallOptions{
+				Namespace:       v.GetString("namespace"),
+				KeepPVCs:        v.GetBool("keep-pvcs"),
+				KeepSecrets:     v.GetBool("keep-secrets"),
+				DeleteNamespace: v.GetBool("delete-namespace"),
+				DryRun:          true,
+			}
+
+			resources, err := kotsadm.Uninstall(uninstallOptions)
+			if err != nil {
+				return errors.Wrap(err, "failed to find admin console resources")
+			}
+
+			var applier *apply.Applier
+			appNamespace := v.GetString("app-namespace")
+			if appNamespace == "" {
+				appNamespace = uninstallOptions.Namespace
+			}
+			appNames := []string{}
+			appObjects := map[string][]apply.ObjectRef{}
+			if v.GetBool("include-apps") {
+				cfg, err := config.GetConfig()
+				if err != nil {
+					return errors.Wrap(err, "failed to load config")
+				}
+
+				applier, err = apply.NewApplier(cfg)
+				if err != nil {
+					return errors.Wrap(err, "failed to create applier")
+				}
+
+				appNames, err = apply.ListInventories(applier.Clientset, appNamespace)
+				if err != nil {
+					return errors.Wrap(err, "failed to list applications")
+				}
+				for _, name := range appNames {
+					refs, err := applier.Delete(name, appNamespace, true)
+					if err != nil {
+						return errors.Wrapf(err, "failed to find objects of %s", name)
+					}
+					appObjects[name] = refs
+				}
+			}
+
+			if len(resources) == 0 && len(appNames) == 0 {
+				log.Info("Nothing to uninstall in namespace %s", uninstallOptions.Namespace)
+				return nil
+			}
+
+			for _, name := range appNames {
+				for _, ref := range appObjects[name] {
+					fmt.Printf("delete %s (%s)\n", ref, name)
+				}
+			}
+			for _, resource := range resources {
+				fmt.Printf("%s %s\n", resource.Action, resource)
+			}
+
+			if v.GetBool("dry-run") {
+				return nil
+			}
+
+			if !v.GetBool("yes") {
+				prompt := promptui.Prompt{
+					Label:     "Delete these resources",
+					IsConfirm: true,
+				}
+				if _, err := prompt.Run(); err != nil {
+					log.Info("Uninstall cancelled")
+					os.Exit(1)
+				}
+			}
+
+			// the applications are deleted first, so they are deleted before their namespace is
+			for _, name := range appNames {
+				log.ActionWithSpinner("Deleting %s", name)
+				if _, err := applier.Delete(name, appNamespace, false); err != nil {
+					log.FinishSpinnerWithError()
+					return errors.Wrapf(err, "failed to delete %s", name)
+				}
+				log.FinishSpinner()
+			}
+
+			log.ActionWithSpinner("Deleting admin console")
+			uninstallOptions.DryRun = false
+			if _, err := kotsadm.Uninstall(uninstallOptions); err != nil {
+				log.FinishSpinnerWithError()
+				return errors.Wrap(err, "failed to uninstall admin console")
+			}
+			log.FinishSpinner()
+
+			return nil
+		},
+	}
+
+	cmd.Flags().StringP("namespace", "n", "default", "the namespace where the admin console is running")
+	cmd.Flags().Bool("keep-pvcs", false, "keep the persistent volume claims of the admin console, so its data is kept")
+	cmd.Flags().Bool("keep-secrets", false, "keep the secrets of the admin console, such as the password and encryption key")
+	cmd.Flags().Bool("delete-namespace", false, "delete the namespace after the admin console is removed (can't be used with --keep-pvcs or --keep-secrets)")
+	cmd.Flags().Bool("include-apps", false, "also delete the objects that were applied with kots apply")
+	cmd.Flags().String("app-namespace", "", "the namespace of the inventory of applied objects (defaults to --namespace)")
+	cmd.Flags().Bool("dry-run", false, "list the resources that would be deleted without deleting them")
+	cmd.Flags().BoolP("yes", "y", false, "don't ask for confirmation before deleting")
+
+	return cmd
+}
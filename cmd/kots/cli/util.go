@@ -4,6 +4,10 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/replicatedhq/kots/pkg/upstream"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func ExpandDir(input string) string {
@@ -27,3 +31,36 @@ func defaultKubeConfig() string {
 	}
 	return filepath.Join(homeDir(), ".kube", "config")
 }
+
+func addHelmRepoFlags(cmd *cobra.Command) {
+	cmd.Flags().String("repo-username", "", "username to authenticate to the helm repo with")
+	cmd.Flags().String("repo-password", "", "password to authenticate to the helm repo with")
+	cmd.Flags().String("repo-ca-file", "", "verify the certificate of the helm repo using this CA bundle")
+	cmd.Flags().String("repo-cert-file", "", "identify to the helm repo using this client certificate")
+	cmd.Flags().String("repo-key-file", "", "identify to the helm repo using this client key")
+}
+
+func getHelmRepoCredentials(v *viper.Viper) upstream.HelmRepoCredentials {
+	return upstream.HelmRepoCredentials{
+		Username: v.GetString("repo-username"),
+		Password: v.GetString("repo-password"),
+		CAFile:   ExpandDir(v.GetString("repo-ca-file")),
+		CertFile: ExpandDir(v.GetString("repo-cert-file")),
+		KeyFile:  ExpandDir(v.GetString("repo-key-file")),
+	}
+}
+
+// parseDownstreams splits --downstream values of the form name=path into the downstream
+// names and the spec file of each downstream that has one
+func parseDownstreams(values []string) ([]string, map[string]string) {
+	names := []string{}
+	specFiles := map[string]string{}
+	for _, value := range values {
+		parts := strings.SplitN(value, "=", 2)
+		names = append(names, parts[0])
+		if len(parts) == 2 {
+			specFiles[parts[0]] = ExpandDir(parts[1])
+		}
+	}
+	return names, specFiles
+}
@@ -70,6 +70,13 @@ func RenderFile(socket string, filePath string, archivePath string) {
 			cipher = c
 		}
 
+		if license != nil {
+			licenseCtx := template.LicenseCtx{
+				License: license,
+			}
+			builder.AddCtx(licenseCtx)
+		}
+
 		if config != nil {
 			templateContextValues := make(map[string]template.ItemValue)
 
@@ -94,13 +101,6 @@ func RenderFile(socket string, filePath string, archivePath string) {
 			kotsconfig.ApplyValuesToConfig(config, configCtx.ItemValues)
 		}
 
-		if license != nil {
-			licenseCtx := template.LicenseCtx{
-				License: license,
-			}
-			builder.AddCtx(licenseCtx)
-		}
-
 		inputContent, err := ioutil.ReadFile(filePath)
 		if err != nil {
 			fmt.Printf("failed to read file %s\n", err.Error())
@@ -0,0 +1,73 @@
+/*
+Copyright 2019 Replicated, Inc..
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConfigMigrationRename moves the value of a config item that was renamed
+type ConfigMigrationRename struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// ConfigMigrationTransform sets the value of a config item from a template. The template
+// can read the previous values with ConfigOption.
+type ConfigMigrationTransform struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+	When  string `json:"when,omitempty"`
+}
+
+// ConfigMigrationSpec defines the desired state of ConfigMigrationSpec
+type ConfigMigrationSpec struct {
+	Renames    []ConfigMigrationRename    `json:"renames,omitempty"`
+	Transforms []ConfigMigrationTransform `json:"transforms,omitempty"`
+	Removals   []string                   `json:"removals,omitempty"`
+}
+
+// ConfigMigrationStatus defines the observed state of ConfigMigration
+type ConfigMigrationStatus struct {
+}
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+// ConfigMigration is the Schema for the configmigration API. A migration is applied once, on the
+// first pull of a release that includes it, and is then recorded by name in the installation.
+// +k8s:openapi-gen=true
+// +kubebuilder:subresource:status
+type ConfigMigration struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ConfigMigrationSpec   `json:"spec,omitempty"`
+	Status ConfigMigrationStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// ConfigMigrationList contains a list of ConfigMigrations
+type ConfigMigrationList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ConfigMigration `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ConfigMigration{}, &ConfigMigrationList{})
+}
@@ -19,6 +19,7 @@ package v1beta1
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"strings"
 
 	"github.com/pkg/errors"
@@ -79,6 +80,46 @@ func (m *MappedChartValue) GetValue() (interface{}, error) {
 	return nil, errors.New("unknown value type")
 }
 
+// getTypedValue returns the value the way it was written in the HelmChart. Unlike GetValue,
+// strings are not escaped for --set and whole numbers are returned as int64.
+func (m *MappedChartValue) getTypedValue() (interface{}, error) {
+	switch m.valueType {
+	case "string":
+		return m.strValue, nil
+	case "bool":
+		return m.boolValue, nil
+	case "float":
+		if m.floatValue == math.Trunc(m.floatValue) && math.Abs(m.floatValue) < math.MaxInt64 {
+			return int64(m.floatValue), nil
+		}
+		return m.floatValue, nil
+	case "nil":
+		return nil, nil
+	case "children":
+		children := map[string]interface{}{}
+		for k, v := range m.children {
+			childValue, err := v.getTypedValue()
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get value of child %s", k)
+			}
+			children[k] = childValue
+		}
+		return children, nil
+	case "array":
+		elements := []interface{}{}
+		for i, v := range m.array {
+			elValue, err := v.getTypedValue()
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to get value of child %d", i)
+			}
+			elements = append(elements, elValue)
+		}
+		return elements, nil
+	}
+
+	return nil, errors.New("unknown value type")
+}
+
 func (m *MappedChartValue) UnmarshalJSON(value []byte) error {
 	var b interface{}
 	err := json.Unmarshal(value, &b)
@@ -228,6 +269,20 @@ func (h *HelmChartSpec) RenderValues(values map[string]MappedChartValue) ([]stri
 	return renderOneLevelValues(values, []string{})
 }
 
+// GetHelmValues returns the values as a typed tree that can be merged into the chart values.
+// Strings are returned unrendered and unescaped.
+func (h *HelmChartSpec) GetHelmValues(values map[string]MappedChartValue) (map[string]interface{}, error) {
+	helmValues := map[string]interface{}{}
+	for k, v := range values {
+		value, err := v.getTypedValue()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get value of %s", k)
+		}
+		helmValues[k] = value
+	}
+	return helmValues, nil
+}
+
 type OptionalValue struct {
 	When   string                      `json:"when"`
 	Values map[string]MappedChartValue `json:"values,omitempty"`
@@ -241,6 +296,10 @@ type HelmChartSpec struct {
 	Values         map[string]MappedChartValue `json:"values,omitempty"`
 	OptionalValues []*OptionalValue            `json:"optionalValues,omitempty"`
 	Builder        map[string]MappedChartValue `json:"builder,omitempty"`
+
+	// UseHelmInstall writes the chart to the base as a helm release unit instead of rendering its templates
+	// into the base. kots apply installs or upgrades the release after applying the rest of the application.
+	UseHelmInstall bool `json:"useHelmInstall,omitempty"`
 }
 
 // HelmChartStatus defines the observed state of HelmChart
@@ -394,3 +394,49 @@ func Test_MappedChartValueGetValue(t *testing.T) {
 		})
 	}
 }
+
+func Test_HelmChartSpecGetHelmValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		values   string
+		expected map[string]interface{}
+	}{
+		{
+			name:   "scalars",
+			values: `{"replicas": 3, "ratio": 0.5, "enabled": true, "name": "a,b", "empty": null}`,
+			expected: map[string]interface{}{
+				"replicas": int64(3),
+				"ratio":    0.5,
+				"enabled":  true,
+				"name":     "a,b",
+				"empty":    nil,
+			},
+		},
+		{
+			name:   "nested",
+			values: `{"image": {"tag": "repl{{ ConfigOption \"tag\" }}", "ports": [80, 443]}}`,
+			expected: map[string]interface{}{
+				"image": map[string]interface{}{
+					"tag":   `repl{{ ConfigOption "tag" }}`,
+					"ports": []interface{}{int64(80), int64(443)},
+				},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			values := map[string]MappedChartValue{}
+			err := json.Unmarshal([]byte(test.values), &values)
+			req.NoError(err)
+
+			spec := HelmChartSpec{}
+			actual, err := spec.GetHelmValues(values)
+			req.NoError(err)
+
+			assert.Equal(t, test.expected, actual)
+		})
+	}
+}
@@ -27,6 +27,8 @@ type InstallationSpec struct {
 	VersionLabel  string `json:"versionLabel,omitempty"`
 	ReleaseNotes  string `json:"releaseNotes,omitempty"`
 	EncryptionKey string `json:"encryptionKey,omitempty"`
+	// ConfigMigrations are the names of the config migrations that were applied to the config values
+	ConfigMigrations []string `json:"configMigrations,omitempty"`
 }
 
 // InstallationStatus defines the observed state of Installation
@@ -379,6 +379,140 @@ func (in *ConfigList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMigration) DeepCopyInto(out *ConfigMigration) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	out.Status = in.Status
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMigration.
+func (in *ConfigMigration) DeepCopy() *ConfigMigration {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMigration)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ConfigMigration) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMigrationList) DeepCopyInto(out *ConfigMigrationList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	out.ListMeta = in.ListMeta
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]ConfigMigration, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMigrationList.
+func (in *ConfigMigrationList) DeepCopy() *ConfigMigrationList {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMigrationList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *ConfigMigrationList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMigrationRename) DeepCopyInto(out *ConfigMigrationRename) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMigrationRename.
+func (in *ConfigMigrationRename) DeepCopy() *ConfigMigrationRename {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMigrationRename)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMigrationSpec) DeepCopyInto(out *ConfigMigrationSpec) {
+	*out = *in
+	if in.Renames != nil {
+		in, out := &in.Renames, &out.Renames
+		*out = make([]ConfigMigrationRename, len(*in))
+		copy(*out, *in)
+	}
+	if in.Transforms != nil {
+		in, out := &in.Transforms, &out.Transforms
+		*out = make([]ConfigMigrationTransform, len(*in))
+		copy(*out, *in)
+	}
+	if in.Removals != nil {
+		in, out := &in.Removals, &out.Removals
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMigrationSpec.
+func (in *ConfigMigrationSpec) DeepCopy() *ConfigMigrationSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMigrationSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMigrationStatus) DeepCopyInto(out *ConfigMigrationStatus) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMigrationStatus.
+func (in *ConfigMigrationStatus) DeepCopy() *ConfigMigrationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMigrationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *ConfigMigrationTransform) DeepCopyInto(out *ConfigMigrationTransform) {
+	*out = *in
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new ConfigMigrationTransform.
+func (in *ConfigMigrationTransform) DeepCopy() *ConfigMigrationTransform {
+	if in == nil {
+		return nil
+	}
+	out := new(ConfigMigrationTransform)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *ConfigSpec) DeepCopyInto(out *ConfigSpec) {
 	*out = *in
@@ -678,7 +812,7 @@ func (in *Installation) DeepCopyInto(out *Installation) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
-	out.Spec = in.Spec
+	in.Spec.DeepCopyInto(&out.Spec)
 	out.Status = in.Status
 }
 
@@ -735,6 +869,11 @@ func (in *InstallationList) DeepCopyObject() runtime.Object {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *InstallationSpec) DeepCopyInto(out *InstallationSpec) {
 	*out = *in
+	if in.ConfigMigrations != nil {
+		in, out := &in.ConfigMigrations, &out.ConfigMigrations
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new InstallationSpec.
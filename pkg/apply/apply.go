@@ -0,0 +1,440 @@
+package apply
+
+import (
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/appstate"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+const (
+	// FieldManager is the manager of the fields that are set by server-side apply
+	FieldManager = "kots"
+	// DefaultWaitTimeout is how long to wait for the applied resources to be ready
+	DefaultWaitTimeout = 5 * time.Minute
+)
+
+var (
+	// crdEstablishedTimeout is how long to wait for an applied custom resource definition to be established
+	crdEstablishedTimeout  = time.Minute
+	crdEstablishedInterval = time.Second
+)
+
+// resettableRESTMapper is a rest mapper that caches discovery, such as the DeferredDiscoveryRESTMapper.
+// It's reset to discover the kinds of custom resource definitions that were applied.
+type resettableRESTMapper interface {
+	meta.RESTMapper
+	Reset()
+}
+
+// Options configure an apply
+type Options struct {
+	// Name identifies the app in the inventory of applied objects
+	Name string
+	// Namespace is used for namespaced objects that don't set one, and for the inventory
+	Namespace string
+	// DryRun sends the objects to the server with a dry run, and doesn't prune or save the inventory
+	DryRun bool
+	// Prune deletes the objects of the previous apply that are not in the manifests anymore
+	Prune bool
+	// Wait waits for the applied resources that have a status informer kind to be ready
+	Wait        bool
+	WaitTimeout time.Duration
+}
+
+// Result has the objects that were applied and pruned, and the status of the app if it was waited for
+type Result struct {
+	Applied []ObjectRef
+	Pruned  []ObjectRef
+	Status  *appstate.AppStatus
+}
+
+// Applier applies the manifests of a built downstream to a cluster
+type Applier struct {
+	Clientset     kubernetes.Interface
+	DynamicClient dynamic.Interface
+	RESTMapper    meta.RESTMapper
+}
+
+// NewApplier creates an applier that discovers the resources of the cluster in the rest config
+func NewApplier(cfg *rest.Config) (*Applier, error) {
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create clientset")
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create dynamic client")
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create discovery client")
+	}
+
+	applier := Applier{
+		Clientset:     clientset,
+		DynamicClient: dynamicClient,
+		RESTMapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+	}
+	return &applier, nil
+}
+
+// Apply applies the objects in the manifests with server-side apply, in install order. Pre-install and
+// pre-upgrade hooks are run first, each one waited for until it completes, then the other objects are
+// applied and post-install and post-upgrade hooks are run after them. Custom resource definitions are
+// waited for until they are established, so that custom resources of their kinds can be applied after
+// them. The objects of the previous apply that are not in the manifests are pruned, and the applied
+// objects are saved to the inventory of the app.
+func (a *Applier) Apply(manifests []byte, options Options) (*Result, error) {
+	if options.Name == "" {
+		return nil, errors.New("name is required")
+	}
+	if options.Namespace == "" {
+		options.Namespace = "default"
+	}
+
+	objects, err := decodeObjects(manifests)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode manifests")
+	}
+	if err := sortForApply(objects); err != nil {
+		return nil, errors.Wrap(err, "failed to sort objects")
+	}
+	preHooks, resources, postHooks, err := splitHooks(objects)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to split hooks")
+	}
+	definedKinds := customResourceKinds(objects)
+
+	result := Result{
+		Applied: []ObjectRef{},
+		Pruned:  []ObjectRef{},
+	}
+
+	for _, obj := range preHooks {
+		if err := a.runHook(obj, options, definedKinds); err != nil {
+			return nil, errors.Wrapf(err, "failed to run hook %s", objectRef(obj))
+		}
+		result.Applied = append(result.Applied, objectRef(obj))
+	}
+
+	for _, obj := range resources {
+		if err := a.applyObject(obj, options, definedKinds); err != nil {
+			return nil, errors.Wrapf(err, "failed to apply %s", objectRef(obj))
+		}
+		result.Applied = append(result.Applied, objectRef(obj))
+
+		if isCustomResourceDefinition(obj) && !options.DryRun {
+			if err := a.waitForEstablished(obj); err != nil {
+				return nil, errors.Wrapf(err, "failed to wait for %s", objectRef(obj))
+			}
+			a.resetRESTMapper()
+		}
+	}
+
+	// post hooks are in the inventory before they run, so that they are pruned if they are removed
+	applied := append([]ObjectRef{}, result.Applied...)
+	for _, obj := range postHooks {
+		ref := objectRef(obj)
+		if ref.Namespace == "" {
+			namespaced, defined := definedKinds[ref.GroupVersionKind().GroupKind()]
+			if !defined {
+				_, namespaced, err = a.resourceClient(ref, options.Namespace)
+				if err != nil {
+					return nil, errors.Wrapf(err, "failed to get resource of %s", ref)
+				}
+			}
+			if namespaced {
+				ref.Namespace = options.Namespace
+			}
+		}
+		applied = append(applied, ref)
+	}
+
+	previous, err := loadInventory(a.Clientset, options.Namespace, options.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load inventory")
+	}
+
+	if options.Prune {
+		pruned, err := a.prune(previous, applied, options.DryRun)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to prune")
+		}
+		result.Pruned = pruned
+	}
+
+	if options.DryRun {
+		for _, obj := range postHooks {
+			if err := a.runHook(obj, options, definedKinds); err != nil {
+				return nil, errors.Wrapf(err, "failed to run hook %s", objectRef(obj))
+			}
+			result.Applied = append(result.Applied, objectRef(obj))
+		}
+		return &result, nil
+	}
+
+	// objects that were not pruned stay in the inventory, so that they are pruned by a later apply
+	inventory := append([]ObjectRef{}, applied...)
+	if !options.Prune {
+		inventory = append(inventory, removedRefs(previous, applied)...)
+	}
+	if err := saveInventory(a.Clientset, options.Namespace, options.Name, inventory); err != nil {
+		return nil, errors.Wrap(err, "failed to save inventory")
+	}
+
+	if options.Wait {
+		status, err := a.wait(result.Applied, options.WaitTimeout)
+		result.Status = status
+		if err != nil {
+			return &result, errors.Wrap(err, "failed to wait for resources")
+		}
+	}
+
+	for _, obj := range postHooks {
+		if err := a.runHook(obj, options, definedKinds); err != nil {
+			return &result, errors.Wrapf(err, "failed to run hook %s", objectRef(obj))
+		}
+		result.Applied = append(result.Applied, objectRef(obj))
+	}
+
+	return &result, nil
+}
+
+// Delete deletes the objects in the inventory of the app, in the reverse of the install order, and then
+// the inventory. With a dry run, the objects that would be deleted are returned and nothing is changed.
+func (a *Applier) Delete(name string, namespace string, dryRun bool) ([]ObjectRef, error) {
+	if namespace == "" {
+		namespace = "default"
+	}
+
+	inventory, err := loadInventory(a.Clientset, namespace, name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load inventory")
+	}
+
+	deleted, err := a.prune(inventory, nil, dryRun)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to delete objects")
+	}
+
+	if dryRun {
+		return deleted, nil
+	}
+
+	if err := deleteInventory(a.Clientset, namespace, name); err != nil {
+		return nil, errors.Wrap(err, "failed to delete inventory")
+	}
+
+	return deleted, nil
+}
+
+// resourceClient returns the client for the kind of the object. Namespaced objects without a
+// namespace are put in the namespace.
+func (a *Applier) resourceClient(ref ObjectRef, namespace string) (dynamic.ResourceInterface, bool, error) {
+	gvk := ref.GroupVersionKind()
+	mapping, err := a.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if meta.IsNoMatchError(err) && a.resetRESTMapper() {
+		// the kind may have been added to the cluster since it was discovered
+		mapping, err = a.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	}
+	if err != nil {
+		return nil, false, errors.Wrapf(err, "failed to get resource for %s", gvk)
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return a.DynamicClient.Resource(mapping.Resource), false, nil
+	}
+	return a.DynamicClient.Resource(mapping.Resource).Namespace(namespace), true, nil
+}
+
+// resetRESTMapper resets the discovery of a rest mapper that caches it, and returns false for other rest mappers
+func (a *Applier) resetRESTMapper() bool {
+	restMapper, ok := a.RESTMapper.(resettableRESTMapper)
+	if !ok {
+		return false
+	}
+	restMapper.Reset()
+	return true
+}
+
+func isCustomResourceDefinition(obj *unstructured.Unstructured) bool {
+	gvk := obj.GroupVersionKind()
+	return gvk.Group == "apiextensions.k8s.io" && gvk.Kind == "CustomResourceDefinition"
+}
+
+// customResourceKinds returns the kinds that the custom resource definitions in the objects define, and
+// whether they are namespaced
+func customResourceKinds(objects []*unstructured.Unstructured) map[schema.GroupKind]bool {
+	kinds := map[schema.GroupKind]bool{}
+	for _, obj := range objects {
+		if !isCustomResourceDefinition(obj) {
+			continue
+		}
+		group, _, _ := unstructured.NestedString(obj.Object, "spec", "group")
+		kind, _, _ := unstructured.NestedString(obj.Object, "spec", "names", "kind")
+		scope, _, _ := unstructured.NestedString(obj.Object, "spec", "scope")
+		kinds[schema.GroupKind{Group: group, Kind: kind}] = scope != "Cluster"
+	}
+	return kinds
+}
+
+// waitForEstablished waits for the Established condition of a custom resource definition
+func (a *Applier) waitForEstablished(crd *unstructured.Unstructured) error {
+	client, _, err := a.resourceClient(objectRef(crd), "")
+	if err != nil {
+		return err
+	}
+
+	err = wait.PollImmediate(crdEstablishedInterval, crdEstablishedTimeout, func() (bool, error) {
+		obj, err := client.Get(crd.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return false, errors.Wrap(err, "failed to get custom resource definition")
+		}
+
+		conditions, _, err := unstructured.NestedSlice(obj.Object, "status", "conditions")
+		if err != nil {
+			return false, errors.Wrap(err, "failed to read conditions")
+		}
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if ok && condition["type"] == "Established" && condition["status"] == "True" {
+				return true, nil
+			}
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return errors.Errorf("custom resource definition %s was not established after %s", crd.GetName(), crdEstablishedTimeout)
+	}
+	return err
+}
+
+// applyObject applies the object with server-side apply. With a dry run, the custom resource definitions
+// in the manifests are not created, so objects of the kinds that they define can't be sent to the server.
+// Those objects count as applied when their kind is in definedKinds.
+func (a *Applier) applyObject(obj *unstructured.Unstructured, options Options, definedKinds map[schema.GroupKind]bool) error {
+	namespace := obj.GetNamespace()
+	if namespace == "" {
+		namespace = options.Namespace
+	}
+
+	client, namespaced, err := a.resourceClient(objectRef(obj), namespace)
+	if err != nil {
+		definedNamespaced, defined := definedKinds[obj.GroupVersionKind().GroupKind()]
+		if !options.DryRun || !defined || !meta.IsNoMatchError(errors.Cause(err)) {
+			return err
+		}
+		setNamespace(obj, namespace, definedNamespaced)
+		return nil
+	}
+	setNamespace(obj, namespace, namespaced)
+
+	b, err := obj.MarshalJSON()
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal object")
+	}
+
+	force := true
+	patchOptions := metav1.PatchOptions{
+		FieldManager: FieldManager,
+		Force:        &force,
+	}
+	if options.DryRun {
+		patchOptions.DryRun = []string{metav1.DryRunAll}
+	}
+
+	if _, err := client.Patch(obj.GetName(), types.ApplyPatchType, b, patchOptions); err != nil {
+		return errors.Wrap(err, "failed to patch")
+	}
+	return nil
+}
+
+// setNamespace puts a namespaced object in the namespace and removes the namespace of other objects
+func setNamespace(obj *unstructured.Unstructured, namespace string, namespaced bool) {
+	if namespaced {
+		obj.SetNamespace(namespace)
+	} else {
+		obj.SetNamespace("")
+	}
+}
+
+// prune deletes the objects of the previous apply that were not applied, in the reverse of the install
+// order. Nothing is deleted with a dry run.
+func (a *Applier) prune(previous []ObjectRef, applied []ObjectRef, dryRun bool) ([]ObjectRef, error) {
+	removed := removedRefs(previous, applied)
+	sortForDelete(removed)
+
+	if dryRun {
+		return removed, nil
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	for _, ref := range removed {
+		client, _, err := a.resourceClient(ref, ref.Namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		err = client.Delete(ref.Name, &metav1.DeleteOptions{PropagationPolicy: &propagation})
+		if err != nil && !kuberneteserrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to delete %s", ref)
+		}
+	}
+
+	return removed, nil
+}
+
+// removedRefs returns the previous refs that are not in the current refs
+func removedRefs(previous []ObjectRef, current []ObjectRef) []ObjectRef {
+	currentKeys := map[string]bool{}
+	for _, ref := range current {
+		currentKeys[ref.key()] = true
+	}
+
+	removed := []ObjectRef{}
+	for _, ref := range previous {
+		if !currentKeys[ref.key()] {
+			removed = append(removed, ref)
+		}
+	}
+	return removed
+}
+
+// wait waits for the applied objects that have a status informer kind to be ready
+func (a *Applier) wait(applied []ObjectRef, timeout time.Duration) (*appstate.AppStatus, error) {
+	if timeout == 0 {
+		timeout = DefaultWaitTimeout
+	}
+
+	statusInformers := []appstate.StatusInformer{}
+	for _, ref := range applied {
+		if statusInformer, ok := appstate.StatusInformerForObject(ref.Kind, ref.Namespace, ref.Name); ok {
+			statusInformers = append(statusInformers, statusInformer)
+		}
+	}
+	if len(statusInformers) == 0 {
+		return nil, nil
+	}
+
+	status, err := appstate.NewMonitor(a.Clientset, statusInformers).WaitForReady(timeout)
+	if err != nil && status.State == "" {
+		return nil, err
+	}
+	return &status, err
+}
@@ -0,0 +1,329 @@
+package apply
+
+import (
+	"testing"
+	"time"
+
+	"github.com/replicatedhq/kots/pkg/appstate"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/runtime/serializer"
+	"k8s.io/apimachinery/pkg/types"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+var (
+	namespaceGVR  = schema.GroupVersionResource{Version: "v1", Resource: "namespaces"}
+	configMapGVR  = schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	deploymentGVR = schema.GroupVersionResource{Group: "apps", Version: "v1", Resource: "deployments"}
+)
+
+// newTestApplier returns an applier with a fake dynamic client that implements server-side apply
+// by creating or replacing the object, and the tracker of the fake dynamic client
+func newTestApplier(objects ...runtime.Object) (*Applier, k8stesting.ObjectTracker) {
+	scheme := runtime.NewScheme()
+	tracker := k8stesting.NewObjectTracker(scheme, serializer.NewCodecFactory(scheme).UniversalDecoder())
+
+	dynamicClient := dynamicfake.NewSimpleDynamicClient(scheme)
+	dynamicClient.PrependReactor("*", "*", k8stesting.ObjectReaction(tracker))
+	dynamicClient.PrependReactor("patch", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		patchAction := action.(k8stesting.PatchAction)
+		if patchAction.GetPatchType() != types.ApplyPatchType {
+			return false, nil, nil
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(patchAction.GetPatch()); err != nil {
+			return true, nil, err
+		}
+
+		gvr, namespace := action.GetResource(), action.GetNamespace()
+		_, err := tracker.Get(gvr, namespace, patchAction.GetName())
+		if kuberneteserrors.IsNotFound(err) {
+			return true, obj, tracker.Create(gvr, obj, namespace)
+		}
+		return true, obj, tracker.Update(gvr, obj, namespace)
+	})
+
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Namespace"}, meta.RESTScopeRoot)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, meta.RESTScopeNamespace)
+	restMapper.Add(schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, meta.RESTScopeNamespace)
+
+	applier := &Applier{
+		Clientset:     fake.NewSimpleClientset(objects...),
+		DynamicClient: dynamicClient,
+		RESTMapper:    restMapper,
+	}
+	return applier, tracker
+}
+
+const testManifests = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  replicas: 1
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: settings
+data:
+  key: value
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test-ns
+`
+
+func Test_ApplyAndPrune(t *testing.T) {
+	req := require.New(t)
+
+	applier, tracker := newTestApplier()
+	options := Options{
+		Name:      "my-app",
+		Namespace: "test-ns",
+		Prune:     true,
+	}
+
+	result, err := applier.Apply([]byte(testManifests), options)
+	req.NoError(err)
+	req.Equal([]ObjectRef{
+		{Version: "v1", Kind: "Namespace", Name: "test-ns"},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "test-ns", Name: "settings"},
+		{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "test-ns", Name: "web"},
+	}, result.Applied)
+	req.Empty(result.Pruned)
+
+	_, err = tracker.Get(namespaceGVR, "", "test-ns")
+	req.NoError(err)
+	_, err = tracker.Get(configMapGVR, "test-ns", "settings")
+	req.NoError(err)
+	_, err = tracker.Get(deploymentGVR, "test-ns", "web")
+	req.NoError(err)
+
+	inventory, err := loadInventory(applier.Clientset, "test-ns", "my-app")
+	req.NoError(err)
+	req.Equal(result.Applied, inventory)
+
+	// a dry run reports the objects that would be pruned and doesn't change anything
+	withoutConfigMap := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: test-ns
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: test-ns
+`
+	options.DryRun = true
+	result, err = applier.Apply([]byte(withoutConfigMap), options)
+	req.NoError(err)
+	req.Equal([]ObjectRef{{Version: "v1", Kind: "ConfigMap", Namespace: "test-ns", Name: "settings"}}, result.Pruned)
+	_, err = tracker.Get(configMapGVR, "test-ns", "settings")
+	req.NoError(err)
+	inventory, err = loadInventory(applier.Clientset, "test-ns", "my-app")
+	req.NoError(err)
+	req.Len(inventory, 3)
+
+	options.DryRun = false
+	result, err = applier.Apply([]byte(withoutConfigMap), options)
+	req.NoError(err)
+	req.Equal([]ObjectRef{{Version: "v1", Kind: "ConfigMap", Namespace: "test-ns", Name: "settings"}}, result.Pruned)
+	_, err = tracker.Get(configMapGVR, "test-ns", "settings")
+	req.True(kuberneteserrors.IsNotFound(err))
+	inventory, err = loadInventory(applier.Clientset, "test-ns", "my-app")
+	req.NoError(err)
+	req.Equal([]ObjectRef{
+		{Version: "v1", Kind: "Namespace", Name: "test-ns"},
+		{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "test-ns", Name: "web"},
+	}, inventory)
+}
+
+func Test_ApplyWait(t *testing.T) {
+	req := require.New(t)
+
+	replicas := int32(1)
+	readyDeployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "test-ns"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: 1, UpdatedReplicas: 1},
+	}
+	applier, _ := newTestApplier(readyDeployment)
+
+	result, err := applier.Apply([]byte(testManifests), Options{
+		Name:      "my-app",
+		Namespace: "test-ns",
+		Wait:      true,
+	})
+	req.NoError(err)
+	req.NotNil(result.Status)
+	req.Equal(appstate.StateReady, result.Status.State)
+}
+
+func Test_Delete(t *testing.T) {
+	req := require.New(t)
+
+	applier, tracker := newTestApplier()
+	_, err := applier.Apply([]byte(testManifests), Options{Name: "my-app", Namespace: "test-ns"})
+	req.NoError(err)
+
+	names, err := ListInventories(applier.Clientset, "test-ns")
+	req.NoError(err)
+	req.Equal([]string{"my-app"}, names)
+
+	deleted, err := applier.Delete("my-app", "test-ns", true)
+	req.NoError(err)
+	req.Len(deleted, 3)
+	_, err = tracker.Get(deploymentGVR, "test-ns", "web")
+	req.NoError(err)
+
+	deleted, err = applier.Delete("my-app", "test-ns", false)
+	req.NoError(err)
+	req.Equal([]ObjectRef{
+		{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "test-ns", Name: "web"},
+		{Version: "v1", Kind: "ConfigMap", Namespace: "test-ns", Name: "settings"},
+		{Version: "v1", Kind: "Namespace", Name: "test-ns"},
+	}, deleted)
+	_, err = tracker.Get(deploymentGVR, "test-ns", "web")
+	req.True(kuberneteserrors.IsNotFound(err))
+
+	names, err = ListInventories(applier.Clientset, "test-ns")
+	req.NoError(err)
+	req.Empty(names)
+}
+
+// discoveryRESTMapper knows the kinds that were discovered, and discovers them again when it's reset
+type discoveryRESTMapper struct {
+	*meta.DefaultRESTMapper
+	discover func() *meta.DefaultRESTMapper
+	resets   int
+}
+
+func (m *discoveryRESTMapper) Reset() {
+	m.resets++
+	m.DefaultRESTMapper = m.discover()
+}
+
+const crdManifests = `apiVersion: example.com/v1
+kind: Widget
+metadata:
+  name: my-widget
+spec:
+  size: 3
+---
+apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: widgets.example.com
+spec:
+  group: example.com
+  names:
+    kind: Widget
+    plural: widgets
+  scope: Namespaced
+  version: v1
+`
+
+func Test_ApplyCustomResourceDefinition(t *testing.T) {
+	req := require.New(t)
+
+	crdEstablishedInterval = time.Millisecond
+	defer func() { crdEstablishedInterval = time.Second }()
+
+	crdGVR := schema.GroupVersionResource{Group: "apiextensions.k8s.io", Version: "v1beta1", Resource: "customresourcedefinitions"}
+	widgetGVR := schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}
+
+	applier, tracker := newTestApplier()
+
+	// the widget kind is discovered once its definition is in the cluster
+	restMapper := &discoveryRESTMapper{}
+	restMapper.discover = func() *meta.DefaultRESTMapper {
+		m := meta.NewDefaultRESTMapper(nil)
+		m.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot)
+		if _, err := tracker.Get(crdGVR, "", "widgets.example.com"); err == nil {
+			m.Add(schema.GroupVersionKind{Group: "example.com", Version: "v1", Kind: "Widget"}, meta.RESTScopeNamespace)
+		}
+		return m
+	}
+	restMapper.DefaultRESTMapper = restMapper.discover()
+	applier.RESTMapper = restMapper
+
+	// the definition is established on the second get
+	gets := 0
+	applier.DynamicClient.(*dynamicfake.FakeDynamicClient).PrependReactor("get", "customresourcedefinitions", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		obj, err := tracker.Get(crdGVR, "", action.(k8stesting.GetAction).GetName())
+		if err != nil {
+			return true, nil, err
+		}
+		gets++
+		if gets > 1 {
+			crd := obj.(*unstructured.Unstructured).DeepCopy()
+			err := unstructured.SetNestedSlice(crd.Object, []interface{}{
+				map[string]interface{}{"type": "Established", "status": "True"},
+			}, "status", "conditions")
+			return true, crd, err
+		}
+		return true, obj, nil
+	})
+
+	result, err := applier.Apply([]byte(crdManifests), Options{Name: "my-app", Namespace: "test-ns"})
+	req.NoError(err)
+	req.Equal([]ObjectRef{
+		{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition", Name: "widgets.example.com"},
+		{Group: "example.com", Version: "v1", Kind: "Widget", Namespace: "test-ns", Name: "my-widget"},
+	}, result.Applied)
+	req.Equal(2, gets)
+	req.Equal(1, restMapper.resets)
+
+	_, err = tracker.Get(widgetGVR, "test-ns", "my-widget")
+	req.NoError(err)
+
+	// a definition that isn't established fails the apply
+	crdEstablishedTimeout = 10 * time.Millisecond
+	defer func() { crdEstablishedTimeout = time.Minute }()
+	gets = -100
+
+	_, err = applier.Apply([]byte(crdManifests), Options{Name: "my-app", Namespace: "test-ns"})
+	req.Error(err)
+	req.Contains(err.Error(), "custom resource definition widgets.example.com was not established")
+}
+
+func Test_ApplyCustomResourceDefinitionDryRun(t *testing.T) {
+	req := require.New(t)
+
+	// the definition is not created with a dry run, so the widget kind is never discovered
+	applier, tracker := newTestApplier()
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(schema.GroupVersionKind{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition"}, meta.RESTScopeRoot)
+	applier.RESTMapper = restMapper
+
+	result, err := applier.Apply([]byte(crdManifests), Options{Name: "my-app", Namespace: "test-ns", DryRun: true})
+	req.NoError(err)
+	req.Equal([]ObjectRef{
+		{Group: "apiextensions.k8s.io", Version: "v1beta1", Kind: "CustomResourceDefinition", Name: "widgets.example.com"},
+		{Group: "example.com", Version: "v1", Kind: "Widget", Namespace: "test-ns", Name: "my-widget"},
+	}, result.Applied)
+
+	_, err = tracker.Get(schema.GroupVersionResource{Group: "example.com", Version: "v1", Resource: "widgets"}, "test-ns", "my-widget")
+	req.True(kuberneteserrors.IsNotFound(err))
+
+	// kinds that are not defined in the manifests still fail
+	_, err = applier.Apply([]byte("apiVersion: example.com/v1\nkind: Gadget\nmetadata:\n  name: my-gadget\n"), Options{Name: "my-app", Namespace: "test-ns", DryRun: true})
+	req.Error(err)
+	req.Contains(err.Error(), `no matches for kind "Gadget"`)
+}
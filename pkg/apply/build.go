@@ -0,0 +1,38 @@
+package apply
+
+import (
+	"bytes"
+	"os/exec"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultKustomizePath is the kustomize binary that downstreams are built with
+const DefaultKustomizePath = "kustomize"
+
+// BuildDownstream runs kustomize build on the downstream dir and returns the manifests. When the
+// kustomize binary is not found, kubectl kustomize is used instead.
+func BuildDownstream(downstreamDir string, kustomizePath string) ([]byte, error) {
+	if kustomizePath == "" {
+		kustomizePath = DefaultKustomizePath
+	}
+
+	var cmd *exec.Cmd
+	if path, err := exec.LookPath(kustomizePath); err == nil {
+		cmd = exec.Command(path, "build", downstreamDir)
+	} else if path, err := exec.LookPath("kubectl"); err == nil {
+		cmd = exec.Command(path, "kustomize", downstreamDir)
+	} else {
+		return nil, errors.Errorf("neither %s nor kubectl were found", kustomizePath)
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, errors.Wrapf(err, "failed to build %s: %s", downstreamDir, strings.TrimSpace(stderr.String()))
+	}
+
+	return stdout.Bytes(), nil
+}
@@ -0,0 +1,122 @@
+package apply
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/dynamic"
+)
+
+const hookDeletePolicyAnnotation = "kots.io/hook-delete-policy"
+
+// hookInterval is how often a hook is checked for completion
+var hookInterval = time.Second
+
+// runHook applies a hook and waits for it to complete. A previous hook object with the same name is
+// deleted first, unless the hook has a delete policy without before-hook-creation. The hook is deleted
+// after it completes if its delete policy has hook-succeeded or hook-failed. With a dry run the hook is
+// only applied.
+func (a *Applier) runHook(obj *unstructured.Unstructured, options Options, definedKinds map[schema.GroupKind]bool) error {
+	if options.DryRun {
+		return a.applyObject(obj, options, definedKinds)
+	}
+
+	deletePolicies := hookDeletePolicies(obj)
+
+	namespace := obj.GetNamespace()
+	if namespace == "" {
+		namespace = options.Namespace
+	}
+	client, _, err := a.resourceClient(objectRef(obj), namespace)
+	if err != nil {
+		return err
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	deleteOptions := &metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	if len(deletePolicies) == 0 || deletePolicies["before-hook-creation"] {
+		if err := client.Delete(obj.GetName(), deleteOptions); err != nil && !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to delete previous hook")
+		}
+	}
+
+	if err := a.applyObject(obj, options, definedKinds); err != nil {
+		return err
+	}
+
+	timeout := options.WaitTimeout
+	if timeout == 0 {
+		timeout = DefaultWaitTimeout
+	}
+
+	hookErr := waitForHook(client, obj, timeout)
+	if deletePolicies["hook-succeeded"] && hookErr == nil || deletePolicies["hook-failed"] && hookErr != nil {
+		if err := client.Delete(obj.GetName(), deleteOptions); err != nil && !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to delete hook")
+		}
+	}
+
+	return hookErr
+}
+
+func hookDeletePolicies(obj *unstructured.Unstructured) map[string]bool {
+	policies := map[string]bool{}
+	for _, policy := range strings.Split(obj.GetAnnotations()[hookDeletePolicyAnnotation], ",") {
+		if policy = strings.TrimSpace(policy); policy != "" {
+			policies[policy] = true
+		}
+	}
+	return policies
+}
+
+// waitForHook waits for a hook job to complete or a hook pod to succeed. Hooks of other kinds are
+// complete once they are applied.
+func waitForHook(client dynamic.ResourceInterface, obj *unstructured.Unstructured, timeout time.Duration) error {
+	if obj.GetKind() != "Job" && obj.GetKind() != "Pod" {
+		return nil
+	}
+
+	err := wait.PollImmediate(hookInterval, timeout, func() (bool, error) {
+		hook, err := client.Get(obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return false, errors.Wrapf(err, "failed to get %s", objectRef(obj))
+		}
+
+		if obj.GetKind() == "Pod" {
+			phase, _, _ := unstructured.NestedString(hook.Object, "status", "phase")
+			switch phase {
+			case "Succeeded":
+				return true, nil
+			case "Failed":
+				return false, errors.Errorf("pod %s failed", obj.GetName())
+			}
+			return false, nil
+		}
+
+		conditions, _, _ := unstructured.NestedSlice(hook.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["status"] != "True" {
+				continue
+			}
+			switch condition["type"] {
+			case "Complete":
+				return true, nil
+			case "Failed":
+				return false, errors.Errorf("job %s failed: %v", obj.GetName(), condition["message"])
+			}
+		}
+		return false, nil
+	})
+	if err == wait.ErrWaitTimeout {
+		return errors.Errorf("%s did not complete after %s", objectRef(obj), timeout)
+	}
+	return err
+}
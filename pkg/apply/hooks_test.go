@@ -0,0 +1,135 @@
+package apply
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+// hookManifests are in the order kustomize build writes them, which is not the order they are applied in
+const hookManifests = `apiVersion: v1
+kind: Secret
+metadata:
+  name: migrate-credentials
+  annotations:
+    kots.io/hook: pre-install
+    kots.io/hook-weight: "-5"
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    kots.io/hook: pre-install,pre-upgrade
+    kots.io/hook-weight: "5"
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: notify
+  annotations:
+    kots.io/hook: post-install
+    kots.io/hook-delete-policy: hook-succeeded
+`
+
+func Test_ApplyHooks(t *testing.T) {
+	req := require.New(t)
+
+	hookInterval = time.Millisecond
+	defer func() { hookInterval = time.Second }()
+
+	jobGVR := schema.GroupVersionResource{Group: "batch", Version: "v1", Resource: "jobs"}
+
+	applier, tracker := newTestApplier()
+	dynamicClient := applier.DynamicClient.(*dynamicfake.FakeDynamicClient)
+
+	// jobs complete once they have been checked twice, unless they are set to fail
+	jobGets := map[string]int{}
+	failJob := ""
+	dynamicClient.PrependReactor("get", "jobs", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := action.(k8stesting.GetAction).GetName()
+		obj, err := tracker.Get(jobGVR, action.GetNamespace(), name)
+		if err != nil {
+			return true, nil, err
+		}
+		jobGets[name]++
+		if jobGets[name] < 2 {
+			return true, obj, nil
+		}
+
+		condition := map[string]interface{}{"type": "Complete", "status": "True"}
+		if name == failJob {
+			condition = map[string]interface{}{"type": "Failed", "status": "True", "message": "BackoffLimitExceeded"}
+		}
+		job := obj.(*unstructured.Unstructured).DeepCopy()
+		err = unstructured.SetNestedSlice(job.Object, []interface{}{condition}, "status", "conditions")
+		return true, job, err
+	})
+
+	actions := []string{}
+	dynamicClient.PrependReactor("*", "*", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		name := ""
+		switch a := action.(type) {
+		case k8stesting.GetAction:
+			name = a.GetName()
+		case k8stesting.PatchAction:
+			name = a.GetName()
+		case k8stesting.DeleteAction:
+			name = a.GetName()
+		}
+		actions = append(actions, fmt.Sprintf("%s %s/%s", action.GetVerb(), action.GetResource().Resource, name))
+		return false, nil, nil
+	})
+
+	result, err := applier.Apply([]byte(hookManifests), Options{Name: "my-app", Namespace: "test-ns"})
+	req.NoError(err)
+
+	// previous pre hooks are deleted and each one completes before the next object is applied. The post
+	// hook runs after the deployment, and its delete policy deletes it when it succeeds instead.
+	req.Equal([]string{
+		"delete secrets/migrate-credentials",
+		"patch secrets/migrate-credentials",
+		"delete jobs/migrate",
+		"patch jobs/migrate",
+		"get jobs/migrate",
+		"get jobs/migrate",
+		"patch deployments/web",
+		"patch jobs/notify",
+		"get jobs/notify",
+		"get jobs/notify",
+		"delete jobs/notify",
+	}, actions)
+	req.Equal([]ObjectRef{
+		{Version: "v1", Kind: "Secret", Namespace: "test-ns", Name: "migrate-credentials"},
+		{Group: "batch", Version: "v1", Kind: "Job", Namespace: "test-ns", Name: "migrate"},
+		{Group: "apps", Version: "v1", Kind: "Deployment", Namespace: "test-ns", Name: "web"},
+		{Group: "batch", Version: "v1", Kind: "Job", Namespace: "test-ns", Name: "notify"},
+	}, result.Applied)
+
+	_, err = tracker.Get(jobGVR, "test-ns", "migrate")
+	req.NoError(err)
+	_, err = tracker.Get(jobGVR, "test-ns", "notify")
+	req.Error(err)
+
+	// a pre hook that fails stops the apply before the deployment is applied
+	actions = []string{}
+	jobGets = map[string]int{}
+	failJob = "migrate"
+
+	_, err = applier.Apply([]byte(hookManifests), Options{Name: "my-app", Namespace: "test-ns"})
+	req.Error(err)
+	req.Contains(err.Error(), "job migrate failed: BackoffLimitExceeded")
+	req.NotContains(actions, "patch deployments/web")
+}
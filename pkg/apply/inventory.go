@@ -0,0 +1,109 @@
+package apply
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	inventoryLabel   = "kots.io/apply-inventory"
+	inventoryDataKey = "objects"
+)
+
+// inventoryName is the name of the config map that has the objects that were applied for the app
+func inventoryName(name string) string {
+	return fmt.Sprintf("kots-apply-inventory.%s", name)
+}
+
+// loadInventory returns the objects of the previous apply, or nil if there's no inventory yet
+func loadInventory(clientset kubernetes.Interface, namespace string, name string) ([]ObjectRef, error) {
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(inventoryName(name), metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get inventory config map")
+	}
+
+	refs := []ObjectRef{}
+	if err := json.Unmarshal([]byte(configMap.Data[inventoryDataKey]), &refs); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal inventory")
+	}
+	return refs, nil
+}
+
+// ListInventories returns the names of the apps that have an inventory of applied objects in the namespace
+func ListInventories(clientset kubernetes.Interface, namespace string) ([]string, error) {
+	configMaps, err := clientset.CoreV1().ConfigMaps(namespace).List(metav1.ListOptions{
+		LabelSelector: inventoryLabel,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list inventory config maps")
+	}
+
+	names := []string{}
+	for _, configMap := range configMaps.Items {
+		names = append(names, configMap.Labels[inventoryLabel])
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// saveInventory creates or updates the inventory config map with the objects that were applied
+func saveInventory(clientset kubernetes.Interface, namespace string, name string, refs []ObjectRef) error {
+	b, err := json.Marshal(refs)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal inventory")
+	}
+
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      inventoryName(name),
+			Namespace: namespace,
+			Labels: map[string]string{
+				inventoryLabel: name,
+			},
+		},
+		Data: map[string]string{
+			inventoryDataKey: string(b),
+		},
+	}
+
+	existing, err := clientset.CoreV1().ConfigMaps(namespace).Get(configMap.Name, metav1.GetOptions{})
+	if kuberneteserrors.IsNotFound(err) {
+		if _, err := clientset.CoreV1().ConfigMaps(namespace).Create(configMap); err != nil {
+			return errors.Wrap(err, "failed to create inventory config map")
+		}
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to get inventory config map")
+	}
+
+	existing.Labels = configMap.Labels
+	existing.Data = configMap.Data
+	if _, err := clientset.CoreV1().ConfigMaps(namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update inventory config map")
+	}
+	return nil
+}
+
+// deleteInventory deletes the inventory config map of the app
+func deleteInventory(clientset kubernetes.Interface, namespace string, name string) error {
+	err := clientset.CoreV1().ConfigMaps(namespace).Delete(inventoryName(name), &metav1.DeleteOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrap(err, "failed to delete inventory config map")
+	}
+	return nil
+}
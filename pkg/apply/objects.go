@@ -0,0 +1,159 @@
+package apply
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// ObjectRef identifies an object that was applied
+type ObjectRef struct {
+	Group     string `json:"group"`
+	Version   string `json:"version"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name"`
+}
+
+func (r ObjectRef) GroupVersionKind() schema.GroupVersionKind {
+	return schema.GroupVersionKind{Group: r.Group, Version: r.Version, Kind: r.Kind}
+}
+
+func (r ObjectRef) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Kind, r.Namespace, r.Name)
+}
+
+// key identifies the object independently of the version of its kind
+func (r ObjectRef) key() string {
+	return fmt.Sprintf("%s/%s/%s/%s", r.Group, r.Kind, r.Namespace, r.Name)
+}
+
+func objectRef(obj *unstructured.Unstructured) ObjectRef {
+	gvk := obj.GroupVersionKind()
+	return ObjectRef{
+		Group:     gvk.Group,
+		Version:   gvk.Version,
+		Kind:      gvk.Kind,
+		Namespace: obj.GetNamespace(),
+		Name:      obj.GetName(),
+	}
+}
+
+// decodeObjects returns the objects in a multi-document yaml stream, such as the output of kustomize build
+func decodeObjects(manifests []byte) ([]*unstructured.Unstructured, error) {
+	docs, err := k8sdoc.SplitYAML(manifests)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to split manifests")
+	}
+
+	objects := []*unstructured.Unstructured{}
+	for _, doc := range docs {
+		b, err := yaml.YAMLToJSON(doc.Content)
+		if err != nil {
+			return nil, &k8sdoc.ParseError{File: "manifests", Index: doc.Index, Err: err}
+		}
+		if string(b) == "null" || string(b) == "{}" {
+			continue
+		}
+
+		obj := &unstructured.Unstructured{}
+		if err := obj.UnmarshalJSON(b); err != nil {
+			return nil, &k8sdoc.ParseError{File: "manifests", Index: doc.Index, Err: err}
+		}
+		if obj.IsList() {
+			list, err := obj.ToList()
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to read list at index %d", doc.Index)
+			}
+			for i := range list.Items {
+				objects = append(objects, &list.Items[i])
+			}
+			continue
+		}
+		objects = append(objects, obj)
+	}
+
+	return objects, nil
+}
+
+func kindOrder(kind string) int {
+	for i, k := range base.InstallOrder {
+		if k == kind {
+			return i
+		}
+	}
+	return len(base.InstallOrder)
+}
+
+func installOrderKey(obj *unstructured.Unstructured) (base.InstallOrderKey, error) {
+	annotations, _, err := unstructured.NestedMap(obj.Object, "metadata", "annotations")
+	if err != nil {
+		return base.InstallOrderKey{}, errors.Wrapf(err, "failed to read annotations of %s", objectRef(obj))
+	}
+
+	key, err := base.NewInstallOrderKey(obj.GetKind(), annotations)
+	if err != nil {
+		return base.InstallOrderKey{}, errors.Wrapf(err, "failed to get install order of %s", objectRef(obj))
+	}
+	return key, nil
+}
+
+// sortForApply orders objects in the same install order as the resources of a base, so that objects
+// are created after the objects they depend on. The order of objects in the same position is kept.
+func sortForApply(objects []*unstructured.Unstructured) error {
+	keys := map[*unstructured.Unstructured]base.InstallOrderKey{}
+	for _, obj := range objects {
+		key, err := installOrderKey(obj)
+		if err != nil {
+			return err
+		}
+		keys[obj] = key
+	}
+
+	sort.SliceStable(objects, func(i, j int) bool {
+		return keys[objects[i]].Less(keys[objects[j]])
+	})
+	return nil
+}
+
+// splitHooks returns the pre-install and pre-upgrade hooks, the other objects and the post-install and
+// post-upgrade hooks, keeping their order
+func splitHooks(objects []*unstructured.Unstructured) ([]*unstructured.Unstructured, []*unstructured.Unstructured, []*unstructured.Unstructured, error) {
+	preHooks := []*unstructured.Unstructured{}
+	resources := []*unstructured.Unstructured{}
+	postHooks := []*unstructured.Unstructured{}
+
+	for _, obj := range objects {
+		key, err := installOrderKey(obj)
+		if err != nil {
+			return nil, nil, nil, err
+		}
+
+		switch key.HookStage() {
+		case -1:
+			preHooks = append(preHooks, obj)
+		case 1:
+			postHooks = append(postHooks, obj)
+		default:
+			resources = append(resources, obj)
+		}
+	}
+
+	return preHooks, resources, postHooks, nil
+}
+
+// sortForDelete orders refs in the reverse of the install order
+func sortForDelete(refs []ObjectRef) {
+	sort.SliceStable(refs, func(i, j int) bool {
+		return kindOrder(refs[i].Kind) > kindOrder(refs[j].Kind)
+	})
+}
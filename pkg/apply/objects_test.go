@@ -0,0 +1,70 @@
+package apply
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sortForApply(t *testing.T) {
+	req := require.New(t)
+
+	manifests := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: notify
+  annotations:
+    kots.io/hook: post-install
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: late
+  annotations:
+    kots.io/creation-phase: "1"
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    kots.io/hook: pre-install,pre-upgrade
+    kots.io/hook-weight: "5"
+---
+apiVersion: v1
+kind: Secret
+metadata:
+  name: migrate-credentials
+  annotations:
+    kots.io/hook: pre-install
+    kots.io/hook-weight: "-5"
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: app
+---
+apiVersion: batch/v1
+kind: Job
+metadata:
+  name: setup
+  annotations:
+    kots.io/creation-phase: "-1"
+`
+	objects, err := decodeObjects([]byte(manifests))
+	req.NoError(err)
+
+	err = sortForApply(objects)
+	req.NoError(err)
+
+	names := []string{}
+	for _, obj := range objects {
+		names = append(names, obj.GetName())
+	}
+	req.Equal([]string{"migrate-credentials", "migrate", "setup", "app", "web", "late", "notify"}, names)
+}
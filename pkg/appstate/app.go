@@ -0,0 +1,84 @@
+package appstate
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/replicatedhq/kots/pkg/template"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// LoadStatusInformers finds the Application in the upstream dir of a pulled app and parses its status
+// informers. Templated entries are rendered with the config values and license of the app.
+func LoadStatusInformers(upstreamDir string, defaultNamespace string) ([]StatusInformer, error) {
+	var app *kotsv1beta1.Application
+	var config *kotsv1beta1.Config
+	var configValues *kotsv1beta1.ConfigValues
+	var license *kotsv1beta1.License
+	var installation *kotsv1beta1.Installation
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	err := filepath.Walk(upstreamDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !k8sdoc.IsYAMLFile(path) {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+
+		obj, gvk, err := decode(content, nil, nil)
+		if err != nil {
+			return nil
+		}
+		if gvk.Group != "kots.io" || gvk.Version != "v1beta1" {
+			return nil
+		}
+
+		switch gvk.Kind {
+		case "Application":
+			app = obj.(*kotsv1beta1.Application)
+		case "Config":
+			config = obj.(*kotsv1beta1.Config)
+		case "ConfigValues":
+			configValues = obj.(*kotsv1beta1.ConfigValues)
+		case "License":
+			license = obj.(*kotsv1beta1.License)
+		case "Installation":
+			installation = obj.(*kotsv1beta1.Installation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk upstream dir")
+	}
+
+	if app == nil {
+		return nil, errors.New("application not found in upstream")
+	}
+
+	var cipher *crypto.AESCipher
+	if installation != nil && installation.Spec.EncryptionKey != "" {
+		c, err := crypto.AESCipherFromString(installation.Spec.EncryptionKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create cipher")
+		}
+		cipher = c
+	}
+
+	builder, err := template.NewBuilder(config, configValues, license, cipher)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create template builder")
+	}
+
+	return ParseStatusInformers(app.Spec.StatusInformers, defaultNamespace, &builder)
+}
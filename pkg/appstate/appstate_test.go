@@ -0,0 +1,182 @@
+package appstate
+
+import (
+	"testing"
+	"time"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/kotskinds/multitype"
+	"github.com/replicatedhq/kots/pkg/template"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_ParseStatusInformers(t *testing.T) {
+	req := require.New(t)
+
+	config := &kotsv1beta1.Config{
+		Spec: kotsv1beta1.ConfigSpec{
+			Groups: []kotsv1beta1.ConfigGroup{
+				{
+					Name: "database",
+					Items: []kotsv1beta1.ConfigItem{
+						{Name: "db_type", Type: "text", Default: multitype.FromString("embedded")},
+						{Name: "db_namespace", Type: "text", Default: multitype.FromString("db")},
+					},
+				},
+			},
+		},
+	}
+	configValues := &kotsv1beta1.ConfigValues{
+		Spec: kotsv1beta1.ConfigValuesSpec{
+			Values: map[string]kotsv1beta1.ConfigValue{
+				"db_type": {Value: "external"},
+			},
+		},
+	}
+	builder, err := template.NewBuilder(config, configValues, nil, nil)
+	req.NoError(err)
+
+	informers, err := ParseStatusInformers([]string{
+		"deployment/web",
+		"Deploy/worker",
+		"monitoring/sts/prometheus",
+		`repl{{ ConfigOption "db_namespace" }}/svc/postgres`,
+		`repl{{ if ConfigOptionEquals "db_type" "embedded" }}statefulset/postgresrepl{{ end }}`,
+	}, "default", &builder)
+	req.NoError(err)
+	req.Equal([]StatusInformer{
+		{Kind: DeploymentKind, Name: "web", Namespace: "default"},
+		{Kind: DeploymentKind, Name: "worker", Namespace: "default"},
+		{Kind: StatefulSetKind, Name: "prometheus", Namespace: "monitoring"},
+		{Kind: ServiceKind, Name: "postgres", Namespace: "db"},
+	}, informers)
+
+	_, err = ParseStatusInformers([]string{"configmap/settings"}, "default", nil)
+	req.Error(err)
+	_, err = ParseStatusInformers([]string{"web"}, "default", nil)
+	req.Error(err)
+}
+
+func Test_deploymentState(t *testing.T) {
+	tests := []struct {
+		name     string
+		replicas int32
+		status   appsv1.DeploymentStatus
+		expected State
+	}{
+		{
+			name:     "ready",
+			replicas: 2,
+			status:   appsv1.DeploymentStatus{ReadyReplicas: 2, UpdatedReplicas: 2},
+			expected: StateReady,
+		},
+		{
+			name:     "rolling out",
+			replicas: 2,
+			status:   appsv1.DeploymentStatus{ReadyReplicas: 2, UpdatedReplicas: 1},
+			expected: StateUpdating,
+		},
+		{
+			name:     "some replicas not ready",
+			replicas: 2,
+			status:   appsv1.DeploymentStatus{ReadyReplicas: 1, UpdatedReplicas: 2},
+			expected: StateDegraded,
+		},
+		{
+			name:     "no replicas ready",
+			replicas: 2,
+			status:   appsv1.DeploymentStatus{UpdatedReplicas: 2},
+			expected: StateUnavailable,
+		},
+		{
+			name:     "scaled to zero",
+			replicas: 0,
+			status:   appsv1.DeploymentStatus{},
+			expected: StateReady,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+			replicas := test.replicas
+			deployment := &appsv1.Deployment{
+				Spec:   appsv1.DeploymentSpec{Replicas: &replicas},
+				Status: test.status,
+			}
+			req.Equal(test.expected, deploymentState(deployment))
+		})
+	}
+}
+
+func Test_MinState(t *testing.T) {
+	req := require.New(t)
+	req.Equal(StateReady, MinState())
+	req.Equal(StateUpdating, MinState(StateReady, StateUpdating))
+	req.Equal(StateDegraded, MinState(StateUpdating, StateDegraded, StateReady))
+	req.Equal(StateUnavailable, MinState(StateDegraded, StateUnavailable))
+}
+
+func Test_Monitor(t *testing.T) {
+	req := require.New(t)
+
+	replicas := int32(1)
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status:     appsv1.DeploymentStatus{ReadyReplicas: 1, UpdatedReplicas: 1},
+	}
+	pvc := &corev1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "db"},
+		Status:     corev1.PersistentVolumeClaimStatus{Phase: corev1.ClaimPending},
+	}
+	clientset := fake.NewSimpleClientset(deployment, pvc)
+
+	monitor := NewMonitor(clientset, []StatusInformer{
+		{Kind: DeploymentKind, Name: "web", Namespace: "default"},
+		{Kind: PersistentVolumeClaimKind, Name: "data", Namespace: "db"},
+		{Kind: ServiceKind, Name: "web", Namespace: "default"},
+	})
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	statuses := make(chan AppStatus, 100)
+	err := monitor.Start(stopCh, func(status AppStatus) {
+		statuses <- status
+	})
+	req.NoError(err)
+
+	status, err := monitor.Status()
+	req.NoError(err)
+	req.Equal(StateUnavailable, status.State)
+	req.Equal([]ResourceState{
+		{Kind: DeploymentKind, Name: "web", Namespace: "default", State: StateReady},
+		{Kind: PersistentVolumeClaimKind, Name: "data", Namespace: "db", State: StateUpdating},
+		{Kind: ServiceKind, Name: "web", Namespace: "default", State: StateUnavailable},
+	}, status.ResourceStates)
+
+	_, err = clientset.CoreV1().Services("default").Create(&corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "default"},
+	})
+	req.NoError(err)
+	pvc.Status.Phase = corev1.ClaimBound
+	_, err = clientset.CoreV1().PersistentVolumeClaims("db").UpdateStatus(pvc)
+	req.NoError(err)
+
+	timeout := time.After(10 * time.Second)
+	for {
+		select {
+		case status := <-statuses:
+			if status.State == StateReady {
+				return
+			}
+		case <-timeout:
+			req.FailNow("timed out waiting for the app to be ready")
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package appstate
+
+import (
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/template"
+)
+
+const (
+	DeploymentKind            = "deployment"
+	StatefulSetKind           = "statefulset"
+	DaemonSetKind             = "daemonset"
+	ServiceKind               = "service"
+	IngressKind               = "ingress"
+	PersistentVolumeClaimKind = "persistentvolumeclaim"
+)
+
+var kindAliases = map[string]string{
+	"deployment":             DeploymentKind,
+	"deployments":            DeploymentKind,
+	"deploy":                 DeploymentKind,
+	"statefulset":            StatefulSetKind,
+	"statefulsets":           StatefulSetKind,
+	"sts":                    StatefulSetKind,
+	"daemonset":              DaemonSetKind,
+	"daemonsets":             DaemonSetKind,
+	"ds":                     DaemonSetKind,
+	"service":                ServiceKind,
+	"services":               ServiceKind,
+	"svc":                    ServiceKind,
+	"ingress":                IngressKind,
+	"ingresses":              IngressKind,
+	"ing":                    IngressKind,
+	"persistentvolumeclaim":  PersistentVolumeClaimKind,
+	"persistentvolumeclaims": PersistentVolumeClaimKind,
+	"pvc":                    PersistentVolumeClaimKind,
+}
+
+// ParseStatusInformers parses the status informers of an Application, which are kind/name or
+// namespace/kind/name. Entries are rendered with the builder when it's not nil, and entries that
+// render to an empty string are skipped so that informers can be turned off by config options.
+func ParseStatusInformers(entries []string, defaultNamespace string, builder *template.Builder) ([]StatusInformer, error) {
+	informers := []StatusInformer{}
+	for _, entry := range entries {
+		if builder != nil {
+			rendered, err := builder.RenderTemplate(entry, entry)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to render status informer %q", entry)
+			}
+			entry = rendered
+		}
+
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		informer, err := parseStatusInformer(entry, defaultNamespace)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse status informer %q", entry)
+		}
+		informers = append(informers, informer)
+	}
+
+	return informers, nil
+}
+
+func parseStatusInformer(entry string, defaultNamespace string) (StatusInformer, error) {
+	informer := StatusInformer{
+		Namespace: defaultNamespace,
+	}
+
+	parts := strings.Split(entry, "/")
+	switch len(parts) {
+	case 2:
+		informer.Kind, informer.Name = parts[0], parts[1]
+	case 3:
+		informer.Namespace, informer.Kind, informer.Name = parts[0], parts[1], parts[2]
+	default:
+		return StatusInformer{}, errors.New("expected kind/name or namespace/kind/name")
+	}
+
+	kind, ok := kindAliases[strings.ToLower(informer.Kind)]
+	if !ok {
+		return StatusInformer{}, errors.Errorf("unsupported kind %q", informer.Kind)
+	}
+	informer.Kind = kind
+
+	if informer.Name == "" || informer.Namespace == "" {
+		return StatusInformer{}, errors.New("name and namespace are required")
+	}
+
+	return informer, nil
+}
+
+// StatusInformerForObject returns the status informer for an object of the kind, and false if the
+// state of the kind isn't computed
+func StatusInformerForObject(kind string, namespace string, name string) (StatusInformer, bool) {
+	informerKind, ok := kindAliases[strings.ToLower(kind)]
+	if !ok {
+		return StatusInformer{}, false
+	}
+	return StatusInformer{Kind: informerKind, Name: name, Namespace: namespace}, true
+}
@@ -0,0 +1,224 @@
+package appstate
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/cache"
+)
+
+// DefaultResyncPeriod is how often the informers recompute the states of resources that didn't change
+const DefaultResyncPeriod = 10 * time.Minute
+
+// Monitor watches the resources of status informers with client-go informers, and computes the
+// state of each resource and of the app from the informer caches
+type Monitor struct {
+	clientset       kubernetes.Interface
+	statusInformers []StatusInformer
+	resyncPeriod    time.Duration
+
+	factories map[string]informers.SharedInformerFactory
+
+	mu       sync.Mutex
+	onChange func(AppStatus)
+}
+
+func NewMonitor(clientset kubernetes.Interface, statusInformers []StatusInformer) *Monitor {
+	return &Monitor{
+		clientset:       clientset,
+		statusInformers: statusInformers,
+		resyncPeriod:    DefaultResyncPeriod,
+		factories:       map[string]informers.SharedInformerFactory{},
+	}
+}
+
+// Start starts the informers and waits for their caches to sync. When onChange is not nil, it's called
+// with the status of the app each time a watched resource changes, until stopCh is closed.
+func (m *Monitor) Start(stopCh <-chan struct{}, onChange func(AppStatus)) error {
+	m.onChange = onChange
+
+	handler := cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { m.notify() },
+		UpdateFunc: func(oldObj, newObj interface{}) { m.notify() },
+		DeleteFunc: func(obj interface{}) { m.notify() },
+	}
+
+	for _, statusInformer := range m.statusInformers {
+		informer, err := m.informerFor(statusInformer)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create informer for %s", statusInformer)
+		}
+		informer.AddEventHandler(handler)
+	}
+
+	for _, factory := range m.factories {
+		factory.Start(stopCh)
+	}
+	for namespace, factory := range m.factories {
+		for informerType, synced := range factory.WaitForCacheSync(stopCh) {
+			if !synced {
+				return errors.Errorf("failed to sync %s informer in namespace %s", informerType, namespace)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *Monitor) factoryFor(namespace string) informers.SharedInformerFactory {
+	factory, ok := m.factories[namespace]
+	if !ok {
+		factory = informers.NewSharedInformerFactoryWithOptions(m.clientset, m.resyncPeriod, informers.WithNamespace(namespace))
+		m.factories[namespace] = factory
+	}
+	return factory
+}
+
+func (m *Monitor) informerFor(statusInformer StatusInformer) (cache.SharedIndexInformer, error) {
+	factory := m.factoryFor(statusInformer.Namespace)
+
+	switch statusInformer.Kind {
+	case DeploymentKind:
+		return factory.Apps().V1().Deployments().Informer(), nil
+	case StatefulSetKind:
+		return factory.Apps().V1().StatefulSets().Informer(), nil
+	case DaemonSetKind:
+		return factory.Apps().V1().DaemonSets().Informer(), nil
+	case ServiceKind:
+		return factory.Core().V1().Services().Informer(), nil
+	case IngressKind:
+		return factory.Extensions().V1beta1().Ingresses().Informer(), nil
+	case PersistentVolumeClaimKind:
+		return factory.Core().V1().PersistentVolumeClaims().Informer(), nil
+	}
+
+	return nil, errors.Errorf("unsupported kind %s", statusInformer.Kind)
+}
+
+func (m *Monitor) notify() {
+	if m.onChange == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	status, err := m.Status()
+	if err != nil {
+		return
+	}
+	m.onChange(status)
+}
+
+// Status computes the status of the app from the informer caches. Resources that don't exist are unavailable.
+func (m *Monitor) Status() (AppStatus, error) {
+	resourceStates := []ResourceState{}
+	for _, statusInformer := range m.statusInformers {
+		state, err := m.resourceState(statusInformer)
+		if err != nil {
+			return AppStatus{}, errors.Wrapf(err, "failed to get state of %s", statusInformer)
+		}
+
+		resourceStates = append(resourceStates, ResourceState{
+			Kind:      statusInformer.Kind,
+			Name:      statusInformer.Name,
+			Namespace: statusInformer.Namespace,
+			State:     state,
+		})
+	}
+
+	return NewAppStatus(resourceStates), nil
+}
+
+func (m *Monitor) resourceState(statusInformer StatusInformer) (State, error) {
+	factory := m.factoryFor(statusInformer.Namespace)
+	namespace, name := statusInformer.Namespace, statusInformer.Name
+
+	switch statusInformer.Kind {
+	case DeploymentKind:
+		deployment, err := factory.Apps().V1().Deployments().Lister().Deployments(namespace).Get(name)
+		if err != nil {
+			return missingResourceState(err)
+		}
+		return deploymentState(deployment), nil
+	case StatefulSetKind:
+		statefulSet, err := factory.Apps().V1().StatefulSets().Lister().StatefulSets(namespace).Get(name)
+		if err != nil {
+			return missingResourceState(err)
+		}
+		return statefulSetState(statefulSet), nil
+	case DaemonSetKind:
+		daemonSet, err := factory.Apps().V1().DaemonSets().Lister().DaemonSets(namespace).Get(name)
+		if err != nil {
+			return missingResourceState(err)
+		}
+		return daemonSetState(daemonSet), nil
+	case ServiceKind:
+		service, err := factory.Core().V1().Services().Lister().Services(namespace).Get(name)
+		if err != nil {
+			return missingResourceState(err)
+		}
+		return serviceState(service), nil
+	case IngressKind:
+		ingress, err := factory.Extensions().V1beta1().Ingresses().Lister().Ingresses(namespace).Get(name)
+		if err != nil {
+			return missingResourceState(err)
+		}
+		return ingressState(ingress), nil
+	case PersistentVolumeClaimKind:
+		pvc, err := factory.Core().V1().PersistentVolumeClaims().Lister().PersistentVolumeClaims(namespace).Get(name)
+		if err != nil {
+			return missingResourceState(err)
+		}
+		return persistentVolumeClaimState(pvc), nil
+	}
+
+	return "", errors.Errorf("unsupported kind %s", statusInformer.Kind)
+}
+
+// missingResourceState returns the unavailable state for resources that don't exist
+func missingResourceState(err error) (State, error) {
+	if kuberneteserrors.IsNotFound(err) {
+		return StateUnavailable, nil
+	}
+	return "", err
+}
+
+// WaitForReady starts the monitor and waits until the app is ready. The last status is returned with an
+// error if the app isn't ready before the timeout.
+func (m *Monitor) WaitForReady(timeout time.Duration) (AppStatus, error) {
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+
+	changed := make(chan struct{}, 1)
+	err := m.Start(stopCh, func(status AppStatus) {
+		select {
+		case changed <- struct{}{}:
+		default:
+		}
+	})
+	if err != nil {
+		return AppStatus{}, errors.Wrap(err, "failed to start informers")
+	}
+
+	deadline := time.After(timeout)
+	for {
+		status, err := m.Status()
+		if err != nil {
+			return AppStatus{}, errors.Wrap(err, "failed to get status")
+		}
+		if status.State == StateReady {
+			return status, nil
+		}
+
+		select {
+		case <-changed:
+		case <-deadline:
+			return status, errors.Errorf("timed out waiting for resources to be ready, app state is %s", status.State)
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package appstate
+
+import (
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	extensionsv1beta1 "k8s.io/api/extensions/v1beta1"
+)
+
+// replicasState is the state of a workload with the number of desired, ready and updated replicas
+func replicasState(desired int32, ready int32, updated int32) State {
+	if desired > 0 && ready == 0 {
+		return StateUnavailable
+	}
+	if updated < desired {
+		return StateUpdating
+	}
+	if ready < desired {
+		return StateDegraded
+	}
+	return StateReady
+}
+
+func deploymentState(deployment *appsv1.Deployment) State {
+	if deployment.Status.ObservedGeneration < deployment.Generation {
+		return StateUpdating
+	}
+
+	desired := int32(1)
+	if deployment.Spec.Replicas != nil {
+		desired = *deployment.Spec.Replicas
+	}
+	return replicasState(desired, deployment.Status.ReadyReplicas, deployment.Status.UpdatedReplicas)
+}
+
+func statefulSetState(statefulSet *appsv1.StatefulSet) State {
+	if statefulSet.Status.ObservedGeneration < statefulSet.Generation {
+		return StateUpdating
+	}
+
+	desired := int32(1)
+	if statefulSet.Spec.Replicas != nil {
+		desired = *statefulSet.Spec.Replicas
+	}
+	updated := statefulSet.Status.UpdatedReplicas
+	if statefulSet.Status.UpdateRevision == statefulSet.Status.CurrentRevision {
+		// the update is done, and updated replicas are counted as current replicas
+		updated = statefulSet.Status.CurrentReplicas
+	}
+	return replicasState(desired, statefulSet.Status.ReadyReplicas, updated)
+}
+
+func daemonSetState(daemonSet *appsv1.DaemonSet) State {
+	if daemonSet.Status.ObservedGeneration < daemonSet.Generation {
+		return StateUpdating
+	}
+	return replicasState(daemonSet.Status.DesiredNumberScheduled, daemonSet.Status.NumberReady, daemonSet.Status.UpdatedNumberScheduled)
+}
+
+// serviceState is ready unless the service is a load balancer that doesn't have an address yet
+func serviceState(service *corev1.Service) State {
+	if service.Spec.Type == corev1.ServiceTypeLoadBalancer && len(service.Status.LoadBalancer.Ingress) == 0 {
+		return StateUpdating
+	}
+	return StateReady
+}
+
+// ingressState is ready when the ingress controller has given the ingress an address
+func ingressState(ingress *extensionsv1beta1.Ingress) State {
+	if len(ingress.Status.LoadBalancer.Ingress) == 0 {
+		return StateUpdating
+	}
+	return StateReady
+}
+
+func persistentVolumeClaimState(pvc *corev1.PersistentVolumeClaim) State {
+	switch pvc.Status.Phase {
+	case corev1.ClaimBound:
+		return StateReady
+	case corev1.ClaimLost:
+		return StateUnavailable
+	default:
+		return StateUpdating
+	}
+}
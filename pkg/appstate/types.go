@@ -0,0 +1,65 @@
+package appstate
+
+type State string
+
+const (
+	StateReady       State = "ready"
+	StateUpdating    State = "updating"
+	StateDegraded    State = "degraded"
+	StateUnavailable State = "unavailable"
+)
+
+// StatusInformer is a resource that the state of the app is computed from
+type StatusInformer struct {
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+func (i StatusInformer) String() string {
+	return i.Namespace + "/" + i.Kind + "/" + i.Name
+}
+
+// ResourceState is the state of one status informer resource
+type ResourceState struct {
+	Kind      string `json:"kind"`
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+	State     State  `json:"state"`
+}
+
+// AppStatus is the state of the app, which is the least ready state of its resources
+type AppStatus struct {
+	State          State           `json:"state"`
+	ResourceStates []ResourceState `json:"resourceStates"`
+}
+
+var stateOrder = map[State]int{
+	StateUnavailable: 0,
+	StateDegraded:    1,
+	StateUpdating:    2,
+	StateReady:       3,
+}
+
+// MinState returns the least ready of the states. An app with no resources is ready.
+func MinState(states ...State) State {
+	min := StateReady
+	for _, state := range states {
+		if stateOrder[state] < stateOrder[min] {
+			min = state
+		}
+	}
+	return min
+}
+
+// NewAppStatus computes the state of the app from the states of its resources
+func NewAppStatus(resourceStates []ResourceState) AppStatus {
+	states := []State{}
+	for _, resourceState := range resourceStates {
+		states = append(states, resourceState.State)
+	}
+	return AppStatus{
+		State:          MinState(states...),
+		ResourceStates: resourceStates,
+	}
+}
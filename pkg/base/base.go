@@ -1,17 +1,15 @@
 package base
 
 import (
-	"bytes"
 	"crypto/sha256"
 	"fmt"
 	"strconv"
+	"strings"
 
 	"github.com/pkg/errors"
 	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
 	troubleshootscheme "github.com/replicatedhq/troubleshoot/pkg/client/troubleshootclientset/scheme"
 	"gopkg.in/yaml.v2"
-	batchv1 "k8s.io/api/batch/v1"
-	serializer "k8s.io/apimachinery/pkg/runtime/serializer/json"
 	"k8s.io/client-go/kubernetes/scheme"
 )
 
@@ -20,6 +18,9 @@ type Base struct {
 	Namespace string
 	Files     []BaseFile
 	Bases     []Base
+	Hooks     []BaseFile
+	// HelmReleases are charts that are installed as helm releases
+	HelmReleases []HelmRelease
 }
 
 type BaseFile struct {
@@ -55,34 +56,71 @@ func GetGVKWithNameHash(content []byte) []byte {
 	return h.Sum(nil)
 }
 
-func (f BaseFile) transpileHelmHooksToKotsHooks() error {
-	decode := scheme.Codecs.UniversalDeserializer().Decode
-	obj, gvk, err := decode(f.Content, nil, nil)
-	if err != nil {
-		return nil // this isn't an error, it's just not a job witih a hook, that's certain
+// transpileHelmHooksToKotsHooks copies the helm hook, hook weight and hook delete policy
+// annotations of any kind to their kots equivalents. The helm annotations are left in place.
+func (f *BaseFile) transpileHelmHooksToKotsHooks() error {
+	doc := yaml.MapSlice{}
+	if err := yaml.Unmarshal(f.Content, &doc); err != nil {
+		return nil // this isn't an error, it's just not an object with a hook, that's certain
 	}
 
-	// we currently only support hooks on jobs
-	if gvk.Group != "batch" || gvk.Version != "v1" || gvk.Kind != "Job" {
+	metadata, ok := mapSliceValue(doc, "metadata").(yaml.MapSlice)
+	if !ok {
+		return nil
+	}
+	annotations, ok := mapSliceValue(metadata, "annotations").(yaml.MapSlice)
+	if !ok {
 		return nil
 	}
 
-	job := obj.(*batchv1.Job)
+	kotsAnnotations := map[string]string{}
 
-	helmHookDeletePolicyAnnotation, ok := job.Annotations["helm.sh/hook-delete-policy"]
-	if !ok {
+	if helmHook, ok := mapSliceValue(annotations, helmHookAnnotation).(string); ok {
+		phases, err := helmHookToKotsHookPhases(helmHook)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse hook of %s", f.Path)
+		}
+		if len(phases) > 0 {
+			kotsAnnotations[kotsHookAnnotation] = strings.Join(phases, ",")
+		}
+	}
+
+	switch helmHookWeight := mapSliceValue(annotations, helmHookWeightAnnotation).(type) {
+	case nil:
+	case int:
+		kotsAnnotations[kotsHookWeightAnnotation] = strconv.Itoa(helmHookWeight)
+	case string:
+		weight, err := strconv.Atoi(strings.TrimSpace(helmHookWeight))
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse hook weight of %s", f.Path)
+		}
+		kotsAnnotations[kotsHookWeightAnnotation] = strconv.Itoa(weight)
+	default:
+		return errors.Errorf("unexpected type in hook weight annotation of %s: %T", f.Path, helmHookWeight)
+	}
+
+	if helmHookDeletePolicy, ok := mapSliceValue(annotations, helmHookDeletePolicyAnnotation).(string); ok {
+		kotsAnnotations[kotsHookDeletePolicyAnnotation] = helmHookDeletePolicy
+	}
+
+	if len(kotsAnnotations) == 0 {
 		return nil
 	}
 
-	job.Annotations["kots.io/hook-delete-policy"] = helmHookDeletePolicyAnnotation
+	for _, key := range []string{kotsHookAnnotation, kotsHookWeightAnnotation, kotsHookDeletePolicyAnnotation} {
+		if value, ok := kotsAnnotations[key]; ok {
+			annotations = setMapSliceValue(annotations, key, value)
+		}
+	}
+	metadata = setMapSliceValue(metadata, "annotations", annotations)
+	doc = setMapSliceValue(doc, "metadata", metadata)
 
-	s := serializer.NewYAMLSerializer(serializer.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
-	var b bytes.Buffer
-	if err := s.Encode(job, &b); err != nil {
-		return errors.Wrap(err, "failed to encode job")
+	b, err := yaml.Marshal(doc)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal object with hooks")
 	}
 
-	f.Content = b.Bytes()
+	f.Content = b
 	return nil
 }
 
@@ -72,7 +72,7 @@ spec:
           backoffLimit: 4`,
 		},
 		{
-			name: "a job with a helm hook",
+			name: "a job with a helm hook delete policy",
 			content: `apiVersion: batch/v1
 kind: Job
 metadata:
@@ -93,16 +93,61 @@ kind: Job
 metadata:
   name: pi
   annotations:
-    "helm.sh/hook-delete-policy": "hook-succeeded"
+    helm.sh/hook-delete-policy: hook-succeeded
+    kots.io/hook-delete-policy: hook-succeeded
 spec:
   template:
     spec:
       containers:
-        - name: pi
-          image: perl
-          command: ["perl",  "-Mbignum=bpi", "-wle", "print bpi(2000)"]
-          restartPolicy: Never
-          backoffLimit: 4`,
+      - name: pi
+        image: perl
+        command:
+        - perl
+        - -Mbignum=bpi
+        - -wle
+        - print bpi(2000)
+        restartPolicy: Never
+        backoffLimit: 4
+`,
+		},
+		{
+			name: "a config map with a hook and weight",
+			content: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: pre
+  annotations:
+    "helm.sh/hook": pre-install, pre-upgrade,test-success
+    "helm.sh/hook-weight": "-5"
+data:
+  replicas: 3`,
+			expected: `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: pre
+  annotations:
+    helm.sh/hook: pre-install, pre-upgrade,test-success
+    helm.sh/hook-weight: "-5"
+    kots.io/hook: pre-install,pre-upgrade,test
+    kots.io/hook-weight: "-5"
+data:
+  replicas: 3
+`,
+		},
+		{
+			name: "a crd install hook",
+			content: `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: crd
+  annotations:
+    "helm.sh/hook": crd-install`,
+			expected: `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: crd
+  annotations:
+    "helm.sh/hook": crd-install`,
 		},
 	}
 
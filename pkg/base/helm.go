@@ -9,15 +9,19 @@ import (
 	"path/filepath"
 	"strings"
 
+	"github.com/Masterminds/semver"
 	"github.com/ghodss/yaml"
 	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
 	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
 	"github.com/replicatedhq/kots/pkg/util"
 	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/engine"
 	"k8s.io/helm/pkg/proto/hapi/chart"
 	"k8s.io/helm/pkg/renderutil"
 	"k8s.io/helm/pkg/strvals"
 	"k8s.io/helm/pkg/timeconv"
+	tversion "k8s.io/helm/pkg/version"
 )
 
 func RenderHelm(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error) {
@@ -45,40 +49,32 @@ func RenderHelm(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base,
 		}
 	}
 
+	// typed values are set first so that --set style options can override them
 	vals := map[string]interface{}{}
+	for k, v := range renderOptions.HelmValues {
+		vals[k] = v
+	}
 	for _, value := range renderOptions.HelmOptions {
 		if err := strvals.ParseInto(value, vals); err != nil {
 			return nil, errors.Wrap(err, "failed to parse helm value")
 		}
 	}
 
-	marshalledVals, err := yaml.Marshal(vals)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to marshal helm values")
-	}
-
-	config := &chart.Config{Raw: string(marshalledVals), Values: map[string]*chart.Value{}}
-
-	c, err := chartutil.Load(chartPath)
+	chartAPIVersion, err := getChartAPIVersion(chartPath)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to load chart")
-	}
-
-	renderOpts := renderutil.Options{
-		ReleaseOptions: chartutil.ReleaseOptions{
-			Name:      u.Name,
-			IsInstall: true,
-			IsUpgrade: false,
-			Time:      timeconv.Now(),
-			Namespace: renderOptions.Namespace,
-		},
-		KubeVersion: "1.16.0",
+		return nil, errors.Wrap(err, "failed to get chart api version")
 	}
 
 	// Silence the go logger because helm will complain about some of our template strings
 	golog.SetOutput(ioutil.Discard)
 	defer golog.SetOutput(os.Stdout)
-	rendered, err := renderutil.Render(c, config, renderOpts)
+
+	var rendered map[string]string
+	if chartAPIVersion == chartAPIVersionV2 {
+		rendered, err = renderHelmV3(u.Name, chartPath, vals, renderOptions)
+	} else {
+		rendered, err = renderHelmV2(u.Name, chartPath, vals, renderOptions)
+	}
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to render chart")
 	}
@@ -98,8 +94,11 @@ func RenderHelm(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base,
 			continue
 		}
 
-		fileStrings := strings.Split(v, "\n---\n")
-		if len(fileStrings) == 1 {
+		docs, err := k8sdoc.SplitYAML([]byte(v))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to split %s", k)
+		}
+		if len(docs) <= 1 {
 			baseFile := BaseFile{
 				Path:    k,
 				Content: []byte(v),
@@ -112,13 +111,13 @@ func RenderHelm(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base,
 			continue
 		}
 
-		for idx, fileString := range fileStrings {
+		for _, doc := range docs {
 			filename := strings.TrimSuffix(k, filepath.Ext(k))
-			filename = fmt.Sprintf("%s-%d%s", filename, idx+1, filepath.Ext(k))
+			filename = fmt.Sprintf("%s-%d%s", filename, doc.Index+1, filepath.Ext(k))
 
 			baseFile := BaseFile{
 				Path:    filename,
-				Content: []byte(fileString),
+				Content: doc.Content,
 			}
 			if err := baseFile.transpileHelmHooksToKotsHooks(); err != nil {
 				return nil, errors.Wrap(err, "failed to transpile helm hooks to kots hooks")
@@ -155,7 +154,89 @@ func RenderHelm(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base,
 		baseFiles = cleanedBaseFiles
 	}
 
+	// hooks are kept apart so that a helm release can run them in their phases. The base writes the
+	// install and upgrade hooks with the rest of the files, and kots apply runs them in their phases
+	baseFiles, hooks, err := splitHooks(baseFiles)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to split hooks from chart")
+	}
+
 	return &Base{
 		Files: baseFiles,
+		Hooks: hooks,
 	}, nil
 }
+
+func renderHelmV2(name string, chartPath string, vals map[string]interface{}, renderOptions *RenderOptions) (map[string]string, error) {
+	marshalledVals, err := yaml.Marshal(vals)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal helm values")
+	}
+
+	config := &chart.Config{Raw: string(marshalledVals), Values: map[string]*chart.Value{}}
+
+	c, err := chartutil.Load(chartPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load chart")
+	}
+
+	if req, err := chartutil.LoadRequirements(c); err == nil {
+		if err := renderutil.CheckDependencies(c, req); err != nil {
+			return nil, errors.Wrap(err, "failed to check chart dependencies")
+		}
+	} else if err != chartutil.ErrRequirementsNotFound {
+		return nil, errors.Wrap(err, "failed to load chart requirements")
+	}
+
+	if err := chartutil.ProcessRequirementsEnabled(c, config); err != nil {
+		return nil, errors.Wrap(err, "failed to process enabled requirements")
+	}
+	if err := chartutil.ProcessRequirementsImportValues(c); err != nil {
+		return nil, errors.Wrap(err, "failed to process requirements import values")
+	}
+
+	kubeVersion, err := parseKubeVersion(renderOptions.KubeVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse kube version")
+	}
+
+	// copy the default so that the rest of the version info is filled in
+	kubeVersionInfo := *chartutil.DefaultKubeVersion
+	kubeVersionInfo.Major = fmt.Sprint(kubeVersion.Major())
+	kubeVersionInfo.Minor = fmt.Sprint(kubeVersion.Minor())
+	kubeVersionInfo.GitVersion = fmt.Sprintf("v%d.%d.0", kubeVersion.Major(), kubeVersion.Minor())
+
+	caps := &chartutil.Capabilities{
+		APIVersions:   chartutil.NewVersionSet(append([]string{"v1"}, renderOptions.APIVersions...)...),
+		KubeVersion:   &kubeVersionInfo,
+		TillerVersion: tversion.GetVersionProto(),
+	}
+
+	releaseOptions := chartutil.ReleaseOptions{
+		Name:      name,
+		IsInstall: true,
+		IsUpgrade: false,
+		Time:      timeconv.Now(),
+		Namespace: renderOptions.Namespace,
+	}
+
+	renderVals, err := chartutil.ToRenderValuesCaps(c, config, releaseOptions, caps)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create render values")
+	}
+
+	rendered, err := engine.New().Render(c, renderVals)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render templates")
+	}
+
+	return rendered, nil
+}
+
+func parseKubeVersion(kubeVersion string) (*semver.Version, error) {
+	if kubeVersion == "" {
+		kubeVersion = DefaultKubeVersion
+	}
+
+	return semver.NewVersion(kubeVersion)
+}
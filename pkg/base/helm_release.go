@@ -0,0 +1,183 @@
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+)
+
+const (
+	// HelmReleasesDir is the directory in the base that helm release units are written to
+	HelmReleasesDir = "helm"
+
+	helmReleaseFile        = "release.yaml"
+	helmReleaseChartFile   = "chart.tgz"
+	helmReleaseValuesFile  = "values.yaml"
+	helmReleaseFileVersion = "v1"
+)
+
+// HelmRelease is a chart that is installed as a helm release instead of being rendered
+// into the kustomize base. It's written to the base as the chart archive and its values.
+type HelmRelease struct {
+	Name         string
+	Namespace    string
+	ChartName    string
+	ChartVersion string
+	ChartArchive []byte
+	Values       map[string]interface{}
+}
+
+// helmReleaseMetadata is the content of release.yaml in a helm release unit
+type helmReleaseMetadata struct {
+	Version      string `json:"version"`
+	Name         string `json:"name"`
+	Namespace    string `json:"namespace,omitempty"`
+	ChartName    string `json:"chartName"`
+	ChartVersion string `json:"chartVersion"`
+}
+
+// writeHelmReleases writes each release to helm/<name> in the base. These files are not part
+// of the kustomization.
+func writeHelmReleases(renderDir string, releases []HelmRelease) error {
+	for _, release := range releases {
+		releaseDir := path.Join(renderDir, HelmReleasesDir, release.Name)
+		if err := os.MkdirAll(releaseDir, 0744); err != nil {
+			return errors.Wrap(err, "failed to mkdir for helm release")
+		}
+
+		metadata := helmReleaseMetadata{
+			Version:      helmReleaseFileVersion,
+			Name:         release.Name,
+			Namespace:    release.Namespace,
+			ChartName:    release.ChartName,
+			ChartVersion: release.ChartVersion,
+		}
+		b, err := yaml.Marshal(metadata)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal helm release")
+		}
+		if err := ioutil.WriteFile(path.Join(releaseDir, helmReleaseFile), b, 0644); err != nil {
+			return errors.Wrap(err, "failed to write helm release")
+		}
+
+		if err := ioutil.WriteFile(path.Join(releaseDir, helmReleaseChartFile), release.ChartArchive, 0644); err != nil {
+			return errors.Wrap(err, "failed to write helm release chart")
+		}
+
+		values := release.Values
+		if values == nil {
+			values = map[string]interface{}{}
+		}
+		b, err = yaml.Marshal(values)
+		if err != nil {
+			return errors.Wrap(err, "failed to marshal helm release values")
+		}
+		if err := ioutil.WriteFile(path.Join(releaseDir, helmReleaseValuesFile), b, 0644); err != nil {
+			return errors.Wrap(err, "failed to write helm release values")
+		}
+	}
+
+	return nil
+}
+
+// ReadHelmReleases reads the helm release units that were written to the base in baseDir and its sub-bases
+func ReadHelmReleases(baseDir string) ([]HelmRelease, error) {
+	releases := []HelmRelease{}
+
+	err := filepath.Walk(baseDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || info.Name() != helmReleaseFile || filepath.Base(filepath.Dir(filepath.Dir(p))) != HelmReleasesDir {
+			return nil
+		}
+
+		release, err := readHelmRelease(filepath.Dir(p))
+		if err != nil {
+			return errors.Wrapf(err, "failed to read helm release in %s", filepath.Dir(p))
+		}
+		releases = append(releases, *release)
+		return nil
+	})
+	if err != nil {
+		if os.IsNotExist(err) {
+			return releases, nil
+		}
+		return nil, errors.Wrap(err, "failed to walk base")
+	}
+
+	return releases, nil
+}
+
+func readHelmRelease(releaseDir string) (*HelmRelease, error) {
+	b, err := ioutil.ReadFile(filepath.Join(releaseDir, helmReleaseFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read release")
+	}
+	metadata := helmReleaseMetadata{}
+	if err := yaml.Unmarshal(b, &metadata); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal release")
+	}
+	if metadata.Version != helmReleaseFileVersion {
+		return nil, errors.Errorf("unknown helm release version %q", metadata.Version)
+	}
+
+	chartArchive, err := ioutil.ReadFile(filepath.Join(releaseDir, helmReleaseChartFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read chart")
+	}
+
+	b, err = ioutil.ReadFile(filepath.Join(releaseDir, helmReleaseValuesFile))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read values")
+	}
+	values := map[string]interface{}{}
+	if err := yaml.Unmarshal(b, &values); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal values")
+	}
+
+	release := HelmRelease{
+		Name:         metadata.Name,
+		Namespace:    metadata.Namespace,
+		ChartName:    metadata.ChartName,
+		ChartVersion: metadata.ChartVersion,
+		ChartArchive: chartArchive,
+		Values:       values,
+	}
+	return &release, nil
+}
+
+// RenderHelmRelease renders the chart of a helm release with its values. Hooks are returned
+// separately in the Hooks of the base.
+func RenderHelmRelease(release *HelmRelease, renderOptions *RenderOptions) (*Base, error) {
+	tmpFile, err := ioutil.TempFile("", "kots")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temp file")
+	}
+	defer os.Remove(tmpFile.Name())
+
+	if _, err := tmpFile.Write(release.ChartArchive); err != nil {
+		tmpFile.Close()
+		return nil, errors.Wrap(err, "failed to write chart to temp file")
+	}
+	if err := tmpFile.Close(); err != nil {
+		return nil, errors.Wrap(err, "failed to close temp file")
+	}
+
+	helmUpstream, err := chartArchiveToSparseUpstream(tmpFile.Name())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read chart archive")
+	}
+	helmUpstream.Name = release.Name
+
+	options := *renderOptions
+	options.SplitMultiDocYAML = true
+	options.Namespace = release.Namespace
+	options.HelmValues = release.Values
+
+	return RenderHelm(helmUpstream, &options)
+}
@@ -0,0 +1,134 @@
+package base
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_writeAndReadHelmReleases(t *testing.T) {
+	req := require.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	b := Base{
+		Bases: []Base{
+			{
+				Path: "charts/sub",
+				HelmReleases: []HelmRelease{
+					{
+						Name:         "sub",
+						Namespace:    "other",
+						ChartName:    "sub-chart",
+						ChartVersion: "1.0.0",
+						ChartArchive: []byte("not really a chart"),
+						Values: map[string]interface{}{
+							"image": map[string]interface{}{"tag": "1.0"},
+						},
+					},
+				},
+			},
+		},
+		HelmReleases: []HelmRelease{
+			{
+				Name:         "app",
+				ChartName:    "app-chart",
+				ChartVersion: "0.1.0",
+				ChartArchive: []byte("also not a chart"),
+			},
+		},
+	}
+	err = b.WriteBase(WriteOptions{BaseDir: tmpDir, Overwrite: true})
+	req.NoError(err)
+
+	kustomization, err := ioutil.ReadFile(filepath.Join(tmpDir, "kustomization.yaml"))
+	req.NoError(err)
+	req.NotContains(string(kustomization), "helm/")
+
+	releases, err := ReadHelmReleases(tmpDir)
+	req.NoError(err)
+	req.Equal([]HelmRelease{
+		{
+			Name:         "sub",
+			Namespace:    "other",
+			ChartName:    "sub-chart",
+			ChartVersion: "1.0.0",
+			ChartArchive: []byte("not really a chart"),
+			Values: map[string]interface{}{
+				"image": map[string]interface{}{"tag": "1.0"},
+			},
+		},
+		{
+			Name:         "app",
+			ChartName:    "app-chart",
+			ChartVersion: "0.1.0",
+			ChartArchive: []byte("also not a chart"),
+			Values:       map[string]interface{}{},
+		},
+	}, releases)
+}
+
+func Test_renderReplicatedUseHelmInstall(t *testing.T) {
+	req := require.New(t)
+
+	chartFiles := map[string]string{
+		"app/Chart.yaml":  "apiVersion: v1\nname: app\nversion: 0.1.0\n",
+		"app/values.yaml": "replicas: 1\n",
+		"app/templates/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: app
+spec:
+  replicas: {{ .Values.replicas }}
+`,
+	}
+	buf := bytes.NewBuffer(nil)
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range chartFiles {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		req.NoError(err)
+		_, err = tw.Write([]byte(content))
+		req.NoError(err)
+	}
+	req.NoError(tw.Close())
+	req.NoError(gzw.Close())
+
+	u := &upstreamtypes.Upstream{
+		Name: "test",
+		Files: []upstreamtypes.UpstreamFile{
+			{Path: "app-0.1.0.tgz", Content: buf.Bytes()},
+			{Path: "app.yaml", Content: []byte(`apiVersion: kots.io/v1beta1
+kind: HelmChart
+metadata:
+  name: app
+spec:
+  chart:
+    name: app
+    chartVersion: 0.1.0
+  useHelmInstall: true
+  values:
+    replicas: 2`)},
+		},
+	}
+
+	b, err := renderReplicated(u, &RenderOptions{})
+	req.NoError(err)
+
+	for _, file := range b.Files {
+		req.NotContains(string(file.Content), "kind: Deployment")
+	}
+	req.Empty(b.Bases)
+	req.Len(b.HelmReleases, 1)
+	req.Equal("app", b.HelmReleases[0].ChartName)
+	req.Equal(map[string]interface{}{"replicas": int64(2)}, b.HelmReleases[0].Values)
+}
@@ -0,0 +1,340 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/xeipuuv/gojsonschema"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/engine"
+	"k8s.io/helm/pkg/proto/hapi/chart"
+	"k8s.io/helm/pkg/timeconv"
+)
+
+// chartAPIVersionV2 is the Chart.yaml apiVersion of charts that were built for helm 3
+const chartAPIVersionV2 = "v2"
+
+// helmV3Chartfile is the part of a helm 3 Chart.yaml that the helm 2 chart loader doesn't read
+type helmV3Chartfile struct {
+	APIVersion   string                  `json:"apiVersion"`
+	Name         string                  `json:"name"`
+	Type         string                  `json:"type,omitempty"`
+	Dependencies []*chartutil.Dependency `json:"dependencies,omitempty"`
+}
+
+// helmV3Capabilities has the shape of .Capabilities in helm 3 templates
+type helmV3Capabilities struct {
+	KubeVersion helmV3KubeVersion
+	APIVersions chartutil.VersionSet
+	HelmVersion helmV3VersionInfo
+}
+
+type helmV3KubeVersion struct {
+	Version string
+	Major   string
+	Minor   string
+}
+
+func (kv helmV3KubeVersion) String() string {
+	return kv.Version
+}
+
+// GitVersion is deprecated in helm 3 but still used by many charts
+func (kv helmV3KubeVersion) GitVersion() string {
+	return kv.Version
+}
+
+type helmV3VersionInfo struct {
+	Version string
+}
+
+func getChartAPIVersion(chartPath string) (string, error) {
+	chartfile, err := readHelmV3Chartfile(chartPath)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to read Chart.yaml")
+	}
+
+	return chartfile.APIVersion, nil
+}
+
+func readHelmV3Chartfile(chartPath string) (*helmV3Chartfile, error) {
+	b, err := ioutil.ReadFile(filepath.Join(chartPath, "Chart.yaml"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read file")
+	}
+
+	chartfile := helmV3Chartfile{}
+	if err := yaml.Unmarshal(b, &chartfile); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal")
+	}
+
+	return &chartfile, nil
+}
+
+// renderHelmV3 renders an apiVersion v2 chart on the helm 2 engine with the chart semantics of helm 3:
+// dependencies are read from Chart.yaml, library charts are not rendered, CRDs in crds/ are included as-is,
+// values are validated against values.schema.json and lookup returns no objects, as it does in helm 3 when
+// not connected to a cluster. Template functions behave as they do in helm 2, so only templates that render
+// the same in both are supported.
+func renderHelmV3(name string, chartPath string, vals map[string]interface{}, renderOptions *RenderOptions) (map[string]string, error) {
+	if err := prepareHelmV3Chart(chartPath); err != nil {
+		return nil, errors.Wrap(err, "failed to prepare chart")
+	}
+
+	marshalledVals, err := yaml.Marshal(vals)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal helm values")
+	}
+
+	config := &chart.Config{Raw: string(marshalledVals), Values: map[string]*chart.Value{}}
+
+	c, err := chartutil.Load(chartPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load chart")
+	}
+
+	if err := chartutil.ProcessRequirementsEnabled(c, config); err != nil {
+		return nil, errors.Wrap(err, "failed to process enabled dependencies")
+	}
+	if err := chartutil.ProcessRequirementsImportValues(c); err != nil {
+		return nil, errors.Wrap(err, "failed to process dependencies import values")
+	}
+
+	caps, err := getHelmV3Capabilities(renderOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get capabilities")
+	}
+
+	releaseOptions := chartutil.ReleaseOptions{
+		Name:      name,
+		IsInstall: true,
+		IsUpgrade: false,
+		Time:      timeconv.Now(),
+		Namespace: renderOptions.Namespace,
+	}
+
+	renderVals, err := chartutil.ToRenderValuesCaps(c, config, releaseOptions, &chartutil.Capabilities{APIVersions: caps.APIVersions})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create render values")
+	}
+	renderVals["Capabilities"] = caps
+	if release, ok := renderVals["Release"].(map[string]interface{}); ok {
+		release["Service"] = "Helm"
+	}
+
+	values, err := renderVals.Table("Values")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get values")
+	}
+	if err := validateHelmV3Values(c, values); err != nil {
+		return nil, errors.Wrap(err, "failed to validate values")
+	}
+
+	renderer := engine.New()
+	renderer.FuncMap["lookup"] = func(apiVersion string, kind string, namespace string, name string) (map[string]interface{}, error) {
+		return map[string]interface{}{}, nil
+	}
+
+	rendered, err := renderer.Render(c, renderVals)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render templates")
+	}
+
+	addHelmV3CRDs(c, c.Metadata.Name, rendered)
+
+	return rendered, nil
+}
+
+// prepareHelmV3Chart changes a helm 3 chart on disk so that the helm 2 loader and engine
+// read it the way helm 3 would
+func prepareHelmV3Chart(chartPath string) error {
+	chartsDir := filepath.Join(chartPath, "charts")
+
+	// dependency archives are expanded so that their Chart.yaml files can be prepared too
+	archives, err := filepath.Glob(filepath.Join(chartsDir, "*.tgz"))
+	if err != nil {
+		return errors.Wrap(err, "failed to list dependency archives")
+	}
+	for _, archive := range archives {
+		if err := chartutil.ExpandFile(chartsDir, archive); err != nil {
+			return errors.Wrapf(err, "failed to expand %s", filepath.Base(archive))
+		}
+		if err := os.Remove(archive); err != nil {
+			return errors.Wrapf(err, "failed to remove %s", filepath.Base(archive))
+		}
+	}
+
+	chartfile, err := readHelmV3Chartfile(chartPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read Chart.yaml")
+	}
+
+	if chartfile.APIVersion == chartAPIVersionV2 && len(chartfile.Dependencies) > 0 {
+		requirementsPath := filepath.Join(chartPath, "requirements.yaml")
+		if _, err := os.Stat(requirementsPath); os.IsNotExist(err) {
+			b, err := yaml.Marshal(chartutil.Requirements{Dependencies: chartfile.Dependencies})
+			if err != nil {
+				return errors.Wrap(err, "failed to marshal dependencies")
+			}
+			if err := ioutil.WriteFile(requirementsPath, b, 0644); err != nil {
+				return errors.Wrap(err, "failed to write dependencies")
+			}
+		}
+	}
+
+	if chartfile.Type == "library" {
+		if err := hideLibraryChartTemplates(chartPath); err != nil {
+			return errors.Wrapf(err, "failed to prepare library chart %s", chartfile.Name)
+		}
+	}
+
+	subcharts, err := ioutil.ReadDir(chartsDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return errors.Wrap(err, "failed to read dependencies")
+	}
+	for _, subchart := range subcharts {
+		if !subchart.IsDir() {
+			continue
+		}
+		if err := prepareHelmV3Chart(filepath.Join(chartsDir, subchart.Name())); err != nil {
+			return errors.Wrapf(err, "failed to prepare dependency %s", subchart.Name())
+		}
+	}
+
+	return nil
+}
+
+// hideLibraryChartTemplates prefixes the templates of a library chart with an underscore.
+// The engine only renders the defines in those files, which is all helm 3 uses from a library chart.
+func hideLibraryChartTemplates(chartPath string) error {
+	templatesDir := filepath.Join(chartPath, "templates")
+	if _, err := os.Stat(templatesDir); os.IsNotExist(err) {
+		return nil
+	}
+
+	return filepath.Walk(templatesDir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || strings.HasPrefix(info.Name(), "_") {
+			return nil
+		}
+
+		return os.Rename(p, filepath.Join(filepath.Dir(p), "_"+info.Name()))
+	})
+}
+
+func getHelmV3Capabilities(renderOptions *RenderOptions) (*helmV3Capabilities, error) {
+	kubeVersion, err := parseKubeVersion(renderOptions.KubeVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse kube version")
+	}
+
+	apiVersions := []string{}
+	for _, gv := range scheme.Scheme.PrioritizedVersionsAllGroups() {
+		apiVersions = append(apiVersions, gv.String())
+	}
+	apiVersions = append(apiVersions, renderOptions.APIVersions...)
+
+	caps := &helmV3Capabilities{
+		KubeVersion: helmV3KubeVersion{
+			Version: fmt.Sprintf("v%d.%d.%d", kubeVersion.Major(), kubeVersion.Minor(), kubeVersion.Patch()),
+			Major:   fmt.Sprint(kubeVersion.Major()),
+			Minor:   fmt.Sprint(kubeVersion.Minor()),
+		},
+		APIVersions: chartutil.NewVersionSet(apiVersions...),
+		HelmVersion: helmV3VersionInfo{
+			Version: "v3.0.0",
+		},
+	}
+
+	return caps, nil
+}
+
+// validateHelmV3Values validates the values of the chart and each enabled dependency against
+// their values.schema.json, and returns all of the errors
+func validateHelmV3Values(c *chart.Chart, values chartutil.Values) error {
+	validationErrors := []string{}
+	collectHelmV3ValuesErrors(c, values, &validationErrors)
+
+	if len(validationErrors) > 0 {
+		return errors.Errorf("values don't meet the specifications of the schema(s) in the following chart(s):\n%s", strings.Join(validationErrors, "\n"))
+	}
+
+	return nil
+}
+
+func collectHelmV3ValuesErrors(c *chart.Chart, values chartutil.Values, validationErrors *[]string) {
+	for _, f := range c.Files {
+		if f.TypeUrl != "values.schema.json" {
+			continue
+		}
+
+		errs, err := validateAgainstSchema(f.Value, values)
+		if err != nil {
+			*validationErrors = append(*validationErrors, fmt.Sprintf("%s:\n- %s", c.Metadata.Name, err.Error()))
+		} else if len(errs) > 0 {
+			*validationErrors = append(*validationErrors, fmt.Sprintf("%s:\n- %s", c.Metadata.Name, strings.Join(errs, "\n- ")))
+		}
+	}
+
+	for _, dependency := range c.Dependencies {
+		dependencyValues, err := values.Table(dependency.Metadata.Name)
+		if err != nil {
+			dependencyValues = chartutil.Values{}
+		}
+		collectHelmV3ValuesErrors(dependency, dependencyValues, validationErrors)
+	}
+}
+
+func validateAgainstSchema(schema []byte, values chartutil.Values) ([]string, error) {
+	// round trip through json so that the validator only sees plain maps
+	b, err := json.Marshal(values)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal values")
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schema), gojsonschema.NewBytesLoader(b))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to validate values")
+	}
+
+	errs := []string{}
+	for _, resultError := range result.Errors() {
+		errs = append(errs, resultError.String())
+	}
+
+	return errs, nil
+}
+
+// addHelmV3CRDs adds the files in the crds directory of the chart and its enabled dependencies.
+// helm 3 doesn't template these files.
+func addHelmV3CRDs(c *chart.Chart, chartPath string, rendered map[string]string) {
+	for _, f := range c.Files {
+		if !strings.HasPrefix(f.TypeUrl, "crds/") {
+			continue
+		}
+
+		ext := path.Ext(f.TypeUrl)
+		if ext != ".yaml" && ext != ".yml" && ext != ".json" {
+			continue
+		}
+
+		rendered[path.Join(chartPath, f.TypeUrl)] = string(f.Value)
+	}
+
+	for _, dependency := range c.Dependencies {
+		addHelmV3CRDs(dependency, path.Join(chartPath, "charts", dependency.Metadata.Name), rendered)
+	}
+}
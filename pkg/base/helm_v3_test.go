@@ -0,0 +1,208 @@
+package base
+
+import (
+	"testing"
+
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/require"
+)
+
+func helmV3TestUpstream() *upstreamtypes.Upstream {
+	return &upstreamtypes.Upstream{
+		Name: "test-chart",
+		Type: "helm",
+		Files: []upstreamtypes.UpstreamFile{
+			{
+				Path: "Chart.yaml",
+				Content: []byte(`apiVersion: v2
+name: test-chart
+version: 0.1.0
+dependencies:
+- name: sub
+  version: 0.1.0
+  condition: sub.enabled
+- name: common
+  version: 0.1.0
+`),
+			},
+			{
+				Path: "values.yaml",
+				Content: []byte(`replicas: 1
+sub:
+  enabled: true
+`),
+			},
+			{
+				Path: "values.schema.json",
+				Content: []byte(`{
+  "type": "object",
+  "properties": {
+    "replicas": {"type": "integer", "minimum": 1}
+  }
+}`),
+			},
+			{
+				Path: "crds/crd.yaml",
+				Content: []byte(`apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: crd
+  annotations:
+    not-templated: "{{ .Values.replicas }}"
+`),
+			},
+			{
+				Path: "templates/deployment.yaml",
+				Content: []byte(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: {{ include "common.name" . }}
+  annotations:
+    kube-version: {{ .Capabilities.KubeVersion.Version }}
+    has-batch: "{{ .Capabilities.APIVersions.Has "batch/v1" }}"
+    existing: "{{ len (lookup "v1" "Secret" .Release.Namespace "existing") }}"
+    service: {{ .Release.Service }}
+spec:
+  replicas: {{ .Values.replicas }}
+`),
+			},
+			{
+				Path: "charts/sub/Chart.yaml",
+				Content: []byte(`apiVersion: v2
+name: sub
+version: 0.1.0
+`),
+			},
+			{
+				Path: "charts/sub/templates/configmap.yaml",
+				Content: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: sub
+`),
+			},
+			{
+				Path: "charts/common/Chart.yaml",
+				Content: []byte(`apiVersion: v2
+name: common
+version: 0.1.0
+type: library
+`),
+			},
+			{
+				Path: "charts/common/templates/_helpers.tpl",
+				Content: []byte(`{{- define "common.name" -}}
+{{ .Release.Name }}-app
+{{- end -}}
+`),
+			},
+			{
+				Path: "charts/common/templates/configmap.yaml",
+				Content: []byte(`apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: not-rendered
+`),
+			},
+		},
+	}
+}
+
+func Test_RenderHelmV3(t *testing.T) {
+	tests := []struct {
+		name          string
+		helmOptions   []string
+		expectedFiles map[string]string
+		expectedError string
+	}{
+		{
+			name:        "defaults",
+			helmOptions: []string{},
+			expectedFiles: map[string]string{
+				"templates/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test-chart-app
+  annotations:
+    kube-version: v1.17.2
+    has-batch: "true"
+    existing: "0"
+    service: Helm
+spec:
+  replicas: 1
+`,
+				"charts/sub/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: sub
+`,
+				"crds/crd.yaml": `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: crd
+  annotations:
+    not-templated: "{{ .Values.replicas }}"
+`,
+			},
+		},
+		{
+			name:        "disabled dependency",
+			helmOptions: []string{"sub.enabled=false", "replicas=2"},
+			expectedFiles: map[string]string{
+				"templates/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: test-chart-app
+  annotations:
+    kube-version: v1.17.2
+    has-batch: "true"
+    existing: "0"
+    service: Helm
+spec:
+  replicas: 2
+`,
+				"crds/crd.yaml": `apiVersion: apiextensions.k8s.io/v1beta1
+kind: CustomResourceDefinition
+metadata:
+  name: crd
+  annotations:
+    not-templated: "{{ .Values.replicas }}"
+`,
+			},
+		},
+		{
+			name:          "invalid values",
+			helmOptions:   []string{"replicas=0"},
+			expectedError: "replicas: Must be greater than or equal to 1",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			b, err := RenderHelm(helmV3TestUpstream(), &RenderOptions{
+				SplitMultiDocYAML: true,
+				Namespace:         "default",
+				HelmOptions:       test.helmOptions,
+				KubeVersion:       "1.17.2",
+			})
+			if test.expectedError != "" {
+				req.Error(err)
+				req.Contains(err.Error(), test.expectedError)
+				return
+			}
+			req.NoError(err)
+
+			files := map[string]string{}
+			for _, file := range b.Files {
+				include, err := file.ShouldBeIncludedInBaseKustomization(false)
+				req.NoError(err)
+				if include {
+					files[file.Path] = string(file.Content)
+				}
+			}
+			req.Equal(test.expectedFiles, files)
+		})
+	}
+}
@@ -0,0 +1,312 @@
+package base
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/template"
+	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+var (
+	helmIntValueRegexp = regexp.MustCompile(`^-?(0|[1-9][0-9]*)$`)
+)
+
+// helmValueSource is where a value in the rendered chart values came from
+type helmValueSource struct {
+	// Key is the dotted path of the value in the HelmChart values
+	Key string
+	// ConfigItems are the config items that the value template references
+	ConfigItems []string
+}
+
+func (s helmValueSource) String() string {
+	if len(s.ConfigItems) == 0 {
+		return fmt.Sprintf("key %q", s.Key)
+	}
+	return fmt.Sprintf("key %q (config item %s)", s.Key, strings.Join(quoteAll(s.ConfigItems), ", "))
+}
+
+// renderHelmChartValues renders the templates in the HelmChart values and returns them as typed values,
+// along with the source of each value keyed by its dotted path. A rendered string is converted to the type
+// that the chart's values.schema.json expects at its path, or to a bool or number when it looks like one.
+// Strings that are not templates keep the type they were written with.
+func renderHelmChartValues(kotsHelmChart *kotsv1beta1.HelmChart, values map[string]kotsv1beta1.MappedChartValue, builder template.Builder, schema map[string]interface{}) (map[string]interface{}, map[string]helmValueSource, error) {
+	helmValues, err := kotsHelmChart.Spec.GetHelmValues(values)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get helm values")
+	}
+
+	sources := map[string]helmValueSource{}
+	rendered, err := renderHelmValue(kotsHelmChart.Name, helmValues, []string{}, builder, schema, sources)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return rendered.(map[string]interface{}), sources, nil
+}
+
+func renderHelmValue(chartName string, value interface{}, valuePath []string, builder template.Builder, schema map[string]interface{}, sources map[string]helmValueSource) (interface{}, error) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		rendered := map[string]interface{}{}
+		for k, child := range v {
+			renderedKey, err := builder.RenderTemplate(k, k)
+			if err != nil {
+				source := helmValueSource{Key: strings.Join(append(valuePath, k), "."), ConfigItems: template.ConfigOptionReferences(k)}
+				return nil, errors.Wrapf(err, "failed to render HelmChart %s %s", chartName, source)
+			}
+			renderedChild, err := renderHelmValue(chartName, child, appendPath(valuePath, renderedKey), builder, schema, sources)
+			if err != nil {
+				return nil, err
+			}
+			rendered[renderedKey] = renderedChild
+		}
+		return rendered, nil
+
+	case []interface{}:
+		rendered := []interface{}{}
+		for i, child := range v {
+			renderedChild, err := renderHelmValue(chartName, child, appendPath(valuePath, strconv.Itoa(i)), builder, schema, sources)
+			if err != nil {
+				return nil, err
+			}
+			rendered = append(rendered, renderedChild)
+		}
+		return rendered, nil
+
+	case string:
+		source := helmValueSource{Key: strings.Join(valuePath, "."), ConfigItems: template.ConfigOptionReferences(v)}
+		sources[source.Key] = source
+
+		rendered, err := builder.RenderTemplate(source.Key, v)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to render HelmChart %s %s", chartName, source)
+		}
+		if rendered == v {
+			return v, nil
+		}
+		return typedHelmValue(rendered, schemaTypesAtPath(schema, valuePath)), nil
+
+	default:
+		sources[strings.Join(valuePath, ".")] = helmValueSource{Key: strings.Join(valuePath, ".")}
+		return v, nil
+	}
+}
+
+func appendPath(valuePath []string, key string) []string {
+	p := make([]string, 0, len(valuePath)+1)
+	p = append(p, valuePath...)
+	return append(p, key)
+}
+
+// typedHelmValue converts a rendered template to the first of the schema types it can be parsed as.
+// Without schema types, "true" and "false" become bools and integers without leading zeros become
+// integers. Other values, such as "1.10", stay strings unless the schema says they are numbers.
+func typedHelmValue(rendered string, schemaTypes []string) interface{} {
+	if len(schemaTypes) == 0 {
+		schemaTypes = []string{"boolean", "integer", "string"}
+	}
+
+	for _, schemaType := range schemaTypes {
+		switch schemaType {
+		case "string":
+			return rendered
+		case "boolean":
+			if rendered == "true" || rendered == "false" {
+				return rendered == "true"
+			}
+		case "integer":
+			if helmIntValueRegexp.MatchString(rendered) {
+				if i, err := strconv.ParseInt(rendered, 10, 64); err == nil {
+					return i
+				}
+			}
+		case "number":
+			if helmIntValueRegexp.MatchString(rendered) {
+				if i, err := strconv.ParseInt(rendered, 10, 64); err == nil {
+					return i
+				}
+			}
+			if f, err := strconv.ParseFloat(rendered, 64); err == nil {
+				return f
+			}
+		case "null":
+			if rendered == "" || rendered == "null" {
+				return nil
+			}
+		}
+	}
+
+	return rendered
+}
+
+// schemaTypesAtPath returns the types that the json schema allows for the value at the path,
+// following properties, additionalProperties and items. Nil is returned if the schema doesn't say.
+func schemaTypesAtPath(schema map[string]interface{}, valuePath []string) []string {
+	node := schema
+	for _, key := range valuePath {
+		if node == nil {
+			return nil
+		}
+
+		var next map[string]interface{}
+		if properties, ok := node["properties"].(map[string]interface{}); ok {
+			next, _ = properties[key].(map[string]interface{})
+		}
+		if next == nil {
+			if _, err := strconv.Atoi(key); err == nil {
+				next, _ = node["items"].(map[string]interface{})
+			}
+		}
+		if next == nil {
+			next, _ = node["additionalProperties"].(map[string]interface{})
+		}
+		node = next
+	}
+	if node == nil {
+		return nil
+	}
+
+	switch t := node["type"].(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		types := []string{}
+		for _, item := range t {
+			if s, ok := item.(string); ok {
+				types = append(types, s)
+			}
+		}
+		return types
+	}
+
+	return nil
+}
+
+// getHelmChartSchema returns the contents of values.schema.json and values.yaml in the root of the chart.
+// The schema is nil when the chart doesn't have one.
+func getHelmChartSchema(u *upstreamtypes.Upstream) ([]byte, map[string]interface{}, error) {
+	var schema []byte
+	defaults := map[string]interface{}{}
+
+	for _, file := range u.Files {
+		switch file.Path {
+		case "values.schema.json":
+			schema = file.Content
+		case "values.yaml":
+			if err := yaml.Unmarshal(file.Content, &defaults); err != nil {
+				return nil, nil, errors.Wrap(err, "failed to unmarshal values.yaml")
+			}
+			if defaults == nil {
+				defaults = map[string]interface{}{}
+			}
+		}
+	}
+
+	return schema, defaults, nil
+}
+
+// validateHelmChartValues validates the chart defaults merged with the rendered HelmChart values against the
+// chart's values.schema.json. Each error names the HelmChart value and config items that produced it.
+func validateHelmChartValues(chartName string, schema []byte, defaults map[string]interface{}, values map[string]interface{}, sources map[string]helmValueSource) error {
+	merged := mergeHelmValues(defaults, values)
+
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal values")
+	}
+
+	result, err := gojsonschema.Validate(gojsonschema.NewBytesLoader(schema), gojsonschema.NewBytesLoader(b))
+	if err != nil {
+		return errors.Wrap(err, "failed to validate values")
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	validationErrors := []string{}
+	for _, resultError := range result.Errors() {
+		field := resultError.Field()
+		if property, ok := resultError.Details()["property"].(string); ok && resultError.Type() == "required" {
+			if field == "(root)" {
+				field = property
+			} else {
+				field = field + "." + property
+			}
+		}
+
+		message := fmt.Sprintf("%s: %s", resultError.Field(), resultError.Description())
+		if source, ok := findHelmValueSource(sources, field); ok {
+			if source.Key == resultError.Field() {
+				message = fmt.Sprintf("%s: %s", source, resultError.Description())
+			} else {
+				message = fmt.Sprintf("%s: %s", source, message)
+			}
+		}
+		validationErrors = append(validationErrors, message)
+	}
+	sort.Strings(validationErrors)
+
+	return errors.Errorf("values of HelmChart %s don't match the chart's values.schema.json:\n- %s", chartName, strings.Join(validationErrors, "\n- "))
+}
+
+// findHelmValueSource returns the source of the value at the field, or of the closest parent that was set in the HelmChart
+func findHelmValueSource(sources map[string]helmValueSource, field string) (helmValueSource, bool) {
+	for field != "" {
+		if source, ok := sources[field]; ok {
+			return source, true
+		}
+
+		idx := strings.LastIndex(field, ".")
+		if idx == -1 {
+			break
+		}
+		field = field[:idx]
+	}
+
+	return helmValueSource{}, false
+}
+
+// mergeHelmValues returns a copy of dst with the values in src merged over it. Maps are merged recursively
+// and a nil value removes the key, the same way helm coalesces values.
+func mergeHelmValues(dst map[string]interface{}, src map[string]interface{}) map[string]interface{} {
+	merged := map[string]interface{}{}
+	for k, v := range dst {
+		merged[k] = v
+	}
+
+	for k, v := range src {
+		if v == nil {
+			delete(merged, k)
+			continue
+		}
+
+		srcMap, srcIsMap := v.(map[string]interface{})
+		dstMap, dstIsMap := merged[k].(map[string]interface{})
+		if srcIsMap && dstIsMap {
+			merged[k] = mergeHelmValues(dstMap, srcMap)
+			continue
+		}
+
+		merged[k] = v
+	}
+
+	return merged
+}
+
+func quoteAll(in []string) []string {
+	quoted := []string{}
+	for _, s := range in {
+		quoted = append(quoted, strconv.Quote(s))
+	}
+	return quoted
+}
@@ -0,0 +1,114 @@
+package base
+
+import (
+	"encoding/json"
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/template"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_renderHelmChartValues(t *testing.T) {
+	tests := []struct {
+		name          string
+		values        string
+		schema        string
+		defaults      map[string]interface{}
+		expected      map[string]interface{}
+		expectedError string
+	}{
+		{
+			name:   "types without schema",
+			values: `{"enabled": "repl{{ ConfigOptionEquals \"enabled\" \"1\" }}", "replicas": "repl{{ ConfigOption \"replicas\" }}", "zip": "repl{{ ConfigOption \"zip\" }}", "literal": "true", "port": 8080, "version": "repl{{ ConfigOption \"version\" }}", "scale": "repl{{ ConfigOption \"scale\" }}"}`,
+			expected: map[string]interface{}{
+				"enabled":  true,
+				"replicas": int64(3),
+				"zip":      "01234",
+				"literal":  "true",
+				"port":     int64(8080),
+				"version":  "1.10",
+				"scale":    "1e3",
+			},
+		},
+		{
+			name:   "numbers from schema",
+			values: `{"version": "repl{{ ConfigOption \"version\" }}", "scale": "repl{{ ConfigOption \"scale\" }}"}`,
+			schema: `{"type": "object", "properties": {"version": {"type": "number"}, "scale": {"type": "number"}}}`,
+			expected: map[string]interface{}{
+				"version": 1.1,
+				"scale":   float64(1000),
+			},
+		},
+		{
+			name:   "types from schema",
+			values: `{"image": {"tag": "repl{{ ConfigOption \"replicas\" }}"}, "replicas": "repl{{ ConfigOption \"replicas\" }}"}`,
+			schema: `{"type": "object", "properties": {"image": {"type": "object", "properties": {"tag": {"type": "string"}}}, "replicas": {"type": "integer"}}}`,
+			expected: map[string]interface{}{
+				"image": map[string]interface{}{
+					"tag": "3",
+				},
+				"replicas": int64(3),
+			},
+		},
+		{
+			name:          "schema error names the key and config item",
+			values:        `{"replicas": "repl{{ ConfigOption \"name\" }}"}`,
+			schema:        `{"type": "object", "properties": {"replicas": {"type": "integer"}}}`,
+			expectedError: "values of HelmChart test don't match the chart's values.schema.json:\n- key \"replicas\" (config item \"name\"): Invalid type. Expected: integer, given: string",
+		},
+		{
+			name:          "schema error in chart defaults",
+			values:        `{"replicas": 1}`,
+			schema:        `{"type": "object", "required": ["image"], "properties": {"replicas": {"type": "integer"}, "image": {"type": "string"}}}`,
+			defaults:      map[string]interface{}{"image": float64(1)},
+			expectedError: "values of HelmChart test don't match the chart's values.schema.json:\n- image: Invalid type. Expected: string, given: integer",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			values := map[string]kotsv1beta1.MappedChartValue{}
+			err := json.Unmarshal([]byte(test.values), &values)
+			req.NoError(err)
+
+			kotsHelmChart := &kotsv1beta1.HelmChart{
+				ObjectMeta: metav1.ObjectMeta{Name: "test"},
+			}
+
+			builder := template.Builder{}
+			builder.AddCtx(template.StaticCtx{})
+			builder.AddCtx(template.ConfigCtx{ItemValues: map[string]template.ItemValue{
+				"enabled":  {Value: "1"},
+				"replicas": {Value: "3"},
+				"zip":      {Value: "01234"},
+				"name":     {Value: "abc"},
+				"version":  {Value: "1.10"},
+				"scale":    {Value: "1e3"},
+			}})
+
+			schema := map[string]interface{}{}
+			if test.schema != "" {
+				err = json.Unmarshal([]byte(test.schema), &schema)
+				req.NoError(err)
+			}
+
+			actual, sources, err := renderHelmChartValues(kotsHelmChart, values, builder, schema)
+			req.NoError(err)
+
+			if test.schema != "" {
+				err = validateHelmChartValues(kotsHelmChart.Name, []byte(test.schema), test.defaults, actual, sources)
+				if test.expectedError != "" {
+					req.EqualError(err, test.expectedError)
+					return
+				}
+				req.NoError(err)
+			}
+
+			req.Equal(test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,193 @@
+package base
+
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	helmHookAnnotation             = "helm.sh/hook"
+	helmHookWeightAnnotation       = "helm.sh/hook-weight"
+	helmHookDeletePolicyAnnotation = "helm.sh/hook-delete-policy"
+
+	kotsHookAnnotation             = "kots.io/hook"
+	kotsHookWeightAnnotation       = "kots.io/hook-weight"
+	kotsHookDeletePolicyAnnotation = "kots.io/hook-delete-policy"
+)
+
+// KotsHookPhases are the phases a hook can run in, in the order they are written
+var KotsHookPhases = []string{
+	"pre-install",
+	"post-install",
+	"pre-upgrade",
+	"post-upgrade",
+	"pre-rollback",
+	"post-rollback",
+	"pre-delete",
+	"post-delete",
+	"test",
+}
+
+// helmHookToKotsHookPhases parses the comma separated list of phases in a helm hook annotation.
+// test-success and test-failure are the older names of the test hook. CRDs installed with the
+// crd-install hook are deployed with the rest of the resources, so that hook has no phase.
+func helmHookToKotsHookPhases(helmHook string) ([]string, error) {
+	phases := []string{}
+	for _, phase := range strings.Split(helmHook, ",") {
+		phase = strings.TrimSpace(phase)
+		if phase == "" || phase == "crd-install" {
+			continue
+		}
+
+		if phase == "test-success" || phase == "test-failure" {
+			phase = "test"
+		}
+
+		if !isKotsHookPhase(phase) {
+			return nil, errors.Errorf("unknown hook %q", phase)
+		}
+
+		found := false
+		for _, p := range phases {
+			if p == phase {
+				found = true
+			}
+		}
+		if !found {
+			phases = append(phases, phase)
+		}
+	}
+
+	return phases, nil
+}
+
+func isKotsHookPhase(phase string) bool {
+	for _, p := range KotsHookPhases {
+		if p == phase {
+			return true
+		}
+	}
+	return false
+}
+
+// HookPhases returns the phases and weight from the kots hook annotations. A file without
+// a kots hook annotation is not a hook and has no phases.
+func (f BaseFile) HookPhases() ([]string, int, error) {
+	o := OverlySimpleGVK{}
+	if err := yaml.Unmarshal(f.Content, &o); err != nil {
+		return nil, 0, nil
+	}
+
+	phases, weight, err := hookPhases(o.Metadata.Annotations)
+	if err != nil {
+		return nil, 0, errors.Wrapf(err, "failed to parse hook of %s", f.Path)
+	}
+
+	return phases, weight, nil
+}
+
+func hookPhases(annotations map[string]interface{}) ([]string, int, error) {
+	hook, ok := annotations[kotsHookAnnotation].(string)
+	if !ok || hook == "" {
+		return nil, 0, nil
+	}
+
+	phases, err := helmHookToKotsHookPhases(hook)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	weight := 0
+	switch val := annotations[kotsHookWeightAnnotation].(type) {
+	case nil:
+	case int:
+		weight = val
+	case string:
+		weight, err = strconv.Atoi(strings.TrimSpace(val))
+		if err != nil {
+			return nil, 0, errors.Wrap(err, "failed to parse hook weight")
+		}
+	default:
+		return nil, 0, errors.Errorf("unexpected type in hook weight annotation: %T", val)
+	}
+
+	return phases, weight, nil
+}
+
+// splitHooks separates the files that are hooks from the rest of the files
+func splitHooks(files []BaseFile) ([]BaseFile, []BaseFile, error) {
+	resources := []BaseFile{}
+	hooks := []BaseFile{}
+
+	for _, file := range files {
+		phases, _, err := file.HookPhases()
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get hook phases")
+		}
+
+		if len(phases) > 0 {
+			hooks = append(hooks, file)
+		} else {
+			resources = append(resources, file)
+		}
+	}
+
+	return resources, hooks, nil
+}
+
+// deployedHooks returns the hooks that are written to the base kustomization. Hooks that run on
+// install or upgrade keep their annotations, and kots apply runs them before or after the rest of
+// the resources. Hooks that only run on rollback, delete or test are not deployed, a downstream
+// has no step that runs them.
+func deployedHooks(hooks []BaseFile) ([]BaseFile, error) {
+	deployed := []BaseFile{}
+	for _, hook := range hooks {
+		phases, _, err := hook.HookPhases()
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get hook phases")
+		}
+
+		if hookStage(phases) != 0 {
+			deployed = append(deployed, hook)
+		}
+	}
+
+	return deployed, nil
+}
+
+// hookStage is -1 for hooks that run before the resources on install or upgrade, 1 for hooks that
+// run after them, and 0 for files that are not hooks or don't run on install or upgrade.
+func hookStage(phases []string) int {
+	stage := 0
+	for _, phase := range phases {
+		switch phase {
+		case "pre-install", "pre-upgrade":
+			return -1
+		case "post-install", "post-upgrade":
+			stage = 1
+		}
+	}
+	return stage
+}
+
+func mapSliceValue(m yaml.MapSlice, key string) interface{} {
+	for _, item := range m {
+		if item.Key == key {
+			return item.Value
+		}
+	}
+	return nil
+}
+
+func setMapSliceValue(m yaml.MapSlice, key string, value interface{}) yaml.MapSlice {
+	for i, item := range m {
+		if item.Key == key {
+			m[i].Value = value
+			return m
+		}
+	}
+	return append(m, yaml.MapItem{Key: key, Value: value})
+}
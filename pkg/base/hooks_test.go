@@ -0,0 +1,83 @@
+package base
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/stretchr/testify/require"
+)
+
+func Test_WriteBaseWithHooks(t *testing.T) {
+	req := require.New(t)
+
+	renderDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(renderDir)
+
+	hooks := []BaseFile{
+		{
+			Path: "templates/migrate.yaml",
+			Content: []byte(`apiVersion: batch/v1
+kind: Job
+metadata:
+  name: migrate
+  annotations:
+    kots.io/hook: pre-install,pre-upgrade
+    kots.io/hook-weight: "5"
+spec:
+  template:
+    spec:
+      containers:
+      - name: migrate
+        image: private.example.com/migrate:1.0`),
+		},
+		{
+			Path: "templates/secret.yaml",
+			Content: []byte(`apiVersion: v1
+kind: Secret
+metadata:
+  name: secret
+  annotations:
+    kots.io/hook: pre-install
+    kots.io/hook-weight: "-5"`),
+		},
+		{
+			Path: "templates/tests/test.yaml",
+			Content: []byte(`apiVersion: v1
+kind: Pod
+metadata:
+  name: test
+  annotations:
+    kots.io/hook: test`),
+		},
+	}
+
+	b := Base{
+		Files: []BaseFile{
+			{
+				Path:    "deployment.yaml",
+				Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web"),
+			},
+		},
+		Hooks: hooks,
+	}
+	err = b.WriteBase(WriteOptions{BaseDir: renderDir, Overwrite: true})
+	req.NoError(err)
+
+	// the test hook doesn't run on install or upgrade, so it isn't deployed
+	k, err := k8sutil.ReadKustomizationFromFile(filepath.Join(renderDir, "kustomization.yaml"))
+	req.NoError(err)
+	req.ElementsMatch([]string{"templates/secret.yaml", "templates/migrate.yaml", "deployment.yaml"}, k.Resources)
+
+	_, err = os.Stat(filepath.Join(renderDir, "templates", "tests", "test.yaml"))
+	req.True(os.IsNotExist(err))
+
+	// hooks are in the base, so the midstream rewrites their images and adds pull secrets to them
+	objects, err := FindObjectsWithImages(FindObjectsWithImagesOptions{BaseDir: renderDir})
+	req.NoError(err)
+	req.Len(objects, 1)
+	req.Equal("migrate", objects[0].Metadata.Name)
+}
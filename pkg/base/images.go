@@ -9,6 +9,7 @@ import (
 	"github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/image"
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/replicatedhq/kots/pkg/logger"
 	kustomizeimage "sigs.k8s.io/kustomize/v3/pkg/image"
 )
 
@@ -16,10 +17,13 @@ type FindPrivateImagesOptions struct {
 	BaseDir            string
 	AppSlug            string
 	ReplicatedRegistry registry.RegistryOptions
+	// Strict fails when a document in the base can't be parsed, instead of skipping it with a warning
+	Strict bool
+	Log    *logger.Logger
 }
 
 func FindPrivateImages(options FindPrivateImagesOptions) ([]kustomizeimage.Image, []*k8sdoc.Doc, error) {
-	upstreamImages, objects, err := image.GetPrivateImages(options.BaseDir)
+	upstreamImages, objects, err := image.GetPrivateImages(options.BaseDir, options.Strict, options.Log)
 	if err != nil {
 		return nil, nil, errors.Wrap(err, "failed to list upstream images")
 	}
@@ -50,10 +54,13 @@ func FindPrivateImages(options FindPrivateImagesOptions) ([]kustomizeimage.Image
 
 type FindObjectsWithImagesOptions struct {
 	BaseDir string
+	// Strict fails when a document in the base can't be parsed, instead of skipping it with a warning
+	Strict bool
+	Log    *logger.Logger
 }
 
 func FindObjectsWithImages(options FindObjectsWithImagesOptions) ([]*k8sdoc.Doc, error) {
-	objects, err := image.GetObjectsWithImages(options.BaseDir)
+	objects, err := image.GetObjectsWithImages(options.BaseDir, options.Strict, options.Log)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to list upstream images")
 	}
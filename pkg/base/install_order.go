@@ -0,0 +1,164 @@
+package base
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v2"
+)
+
+const creationPhaseAnnotation = "kots.io/creation-phase"
+
+// InstallOrder is the order that kots apply creates kinds in, so that resources are created after the
+// resources they depend on. Kinds that are not listed, such as custom resources, come after all of these.
+var InstallOrder = []string{
+	"Namespace",
+	"CustomResourceDefinition",
+	"NetworkPolicy",
+	"ResourceQuota",
+	"LimitRange",
+	"PodSecurityPolicy",
+	"PodDisruptionBudget",
+	"ServiceAccount",
+	"ClusterRole",
+	"ClusterRoleList",
+	"ClusterRoleBinding",
+	"ClusterRoleBindingList",
+	"Role",
+	"RoleList",
+	"RoleBinding",
+	"RoleBindingList",
+	"Secret",
+	"SecretList",
+	"ConfigMap",
+	"StorageClass",
+	"PersistentVolume",
+	"PersistentVolumeClaim",
+	"Service",
+	"DaemonSet",
+	"Pod",
+	"ReplicationController",
+	"ReplicaSet",
+	"Deployment",
+	"HorizontalPodAutoscaler",
+	"StatefulSet",
+	"Job",
+	"CronJob",
+	"Ingress",
+	"APIService",
+}
+
+// InstallOrderKey is the position of an object in the install order
+type InstallOrderKey struct {
+	hookStage  int
+	phase      int
+	hookWeight int
+	kindOrder  int
+}
+
+// NewInstallOrderKey returns the install order of an object from its kind and annotations. Pre-install and
+// pre-upgrade hooks come first and post-install and post-upgrade hooks last. Within that, objects are ordered
+// by their kots.io/creation-phase annotation, then by hook weight and then by the install order of their kind.
+// Objects without the annotation are in phase 0.
+func NewInstallOrderKey(kind string, annotations map[string]interface{}) (InstallOrderKey, error) {
+	phase, err := creationPhase(annotations)
+	if err != nil {
+		return InstallOrderKey{}, errors.Wrap(err, "failed to parse creation phase")
+	}
+
+	phases, hookWeight, err := hookPhases(annotations)
+	if err != nil {
+		return InstallOrderKey{}, errors.Wrap(err, "failed to parse hook")
+	}
+
+	kindOrder := len(InstallOrder)
+	for i, k := range InstallOrder {
+		if k == kind {
+			kindOrder = i
+			break
+		}
+	}
+
+	key := InstallOrderKey{
+		hookStage:  hookStage(phases),
+		phase:      phase,
+		hookWeight: hookWeight,
+		kindOrder:  kindOrder,
+	}
+	return key, nil
+}
+
+// HookStage is -1 for pre-install and pre-upgrade hooks, 1 for post-install and post-upgrade hooks and 0 for
+// other objects
+func (k InstallOrderKey) HookStage() int {
+	return k.hookStage
+}
+
+// Less reports whether k is installed before other
+func (k InstallOrderKey) Less(other InstallOrderKey) bool {
+	if k.hookStage != other.hookStage {
+		return k.hookStage < other.hookStage
+	}
+	if k.phase != other.phase {
+		return k.phase < other.phase
+	}
+	if k.hookWeight != other.hookWeight {
+		return k.hookWeight < other.hookWeight
+	}
+	return k.kindOrder < other.kindOrder
+}
+
+type installOrderFile struct {
+	file BaseFile
+	key  InstallOrderKey
+}
+
+// sortBaseFilesForInstall orders files by their install order key and then by path. This only makes the
+// kustomization read in the order that kots apply creates the resources in, kustomize build orders its
+// output by kind.
+func sortBaseFilesForInstall(files []BaseFile) ([]BaseFile, error) {
+	orderFiles := []installOrderFile{}
+	for _, file := range files {
+		o := OverlySimpleGVK{}
+		_ = yaml.Unmarshal(file.Content, &o) // files that aren't objects sort with the unknown kinds
+
+		key, err := NewInstallOrderKey(o.Kind, o.Metadata.Annotations)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get install order of %s", file.Path)
+		}
+
+		orderFiles = append(orderFiles, installOrderFile{
+			file: file,
+			key:  key,
+		})
+	}
+
+	sort.SliceStable(orderFiles, func(i, j int) bool {
+		if orderFiles[i].key != orderFiles[j].key {
+			return orderFiles[i].key.Less(orderFiles[j].key)
+		}
+		return orderFiles[i].file.Path < orderFiles[j].file.Path
+	})
+
+	sorted := []BaseFile{}
+	for _, orderFile := range orderFiles {
+		sorted = append(sorted, orderFile.file)
+	}
+
+	return sorted, nil
+}
+
+func creationPhase(annotations map[string]interface{}) (int, error) {
+	switch val := annotations[creationPhaseAnnotation].(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return val, nil
+	case string:
+		return strconv.Atoi(strings.TrimSpace(val))
+	default:
+		return 0, errors.Errorf("unexpected type %T", val)
+	}
+}
@@ -0,0 +1,79 @@
+package base
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_sortBaseFilesForInstall(t *testing.T) {
+	tests := []struct {
+		name          string
+		files         []BaseFile
+		expected      []string
+		expectedError string
+	}{
+		{
+			name: "kinds in install order",
+			files: []BaseFile{
+				{Path: "deployment.yaml", Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: app")},
+				{Path: "custom.yaml", Content: []byte("apiVersion: example.com/v1\nkind: Widget\nmetadata:\n  name: widget")},
+				{Path: "crd.yaml", Content: []byte("apiVersion: apiextensions.k8s.io/v1beta1\nkind: CustomResourceDefinition\nmetadata:\n  name: widgets.example.com")},
+				{Path: "b-secret.yaml", Content: []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: b")},
+				{Path: "a-secret.yaml", Content: []byte("apiVersion: v1\nkind: Secret\nmetadata:\n  name: a")},
+				{Path: "role.yaml", Content: []byte("apiVersion: rbac.authorization.k8s.io/v1\nkind: Role\nmetadata:\n  name: role")},
+				{Path: "sa.yaml", Content: []byte("apiVersion: v1\nkind: ServiceAccount\nmetadata:\n  name: sa")},
+				{Path: "ns.yaml", Content: []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns")},
+			},
+			expected: []string{
+				"ns.yaml",
+				"crd.yaml",
+				"sa.yaml",
+				"role.yaml",
+				"a-secret.yaml",
+				"b-secret.yaml",
+				"deployment.yaml",
+				"custom.yaml",
+			},
+		},
+		{
+			name: "creation phase",
+			files: []BaseFile{
+				{Path: "ns.yaml", Content: []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns")},
+				{Path: "late.yaml", Content: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: late\n  annotations:\n    kots.io/creation-phase: \"10\"")},
+				{Path: "early.yaml", Content: []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: early\n  annotations:\n    kots.io/creation-phase: \"-1\"")},
+			},
+			expected: []string{
+				"early.yaml",
+				"ns.yaml",
+				"late.yaml",
+			},
+		},
+		{
+			name: "invalid creation phase",
+			files: []BaseFile{
+				{Path: "bad.yaml", Content: []byte("apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: bad\n  annotations:\n    kots.io/creation-phase: first")},
+			},
+			expectedError: `failed to get install order of bad.yaml: failed to parse creation phase: strconv.Atoi: parsing "first": invalid syntax`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			sorted, err := sortBaseFilesForInstall(test.files)
+			if test.expectedError != "" {
+				req.EqualError(err, test.expectedError)
+				return
+			}
+			req.NoError(err)
+
+			actual := []string{}
+			for _, file := range sorted {
+				actual = append(actual, file.Path)
+			}
+			req.Equal(test.expected, actual)
+		})
+	}
+}
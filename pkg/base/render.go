@@ -6,10 +6,16 @@ import (
 	upstreamtypes "github.com/replicatedhq/kots/pkg/upstream/types"
 )
 
+// DefaultKubeVersion is the kubernetes version that charts are rendered for when none is set
+const DefaultKubeVersion = "1.16.0"
+
 type RenderOptions struct {
 	SplitMultiDocYAML bool
 	Namespace         string
 	HelmOptions       []string
+	HelmValues        map[string]interface{}
+	KubeVersion       string
+	APIVersions       []string
 	Log               *logger.Logger
 }
 
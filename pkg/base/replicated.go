@@ -4,6 +4,7 @@ import (
 	"archive/tar"
 	"bytes"
 	"compress/gzip"
+	"encoding/json"
 	"io"
 	"io/ioutil"
 	"os"
@@ -27,20 +28,6 @@ import (
 func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (*Base, error) {
 	config, configValues, license := findConfig(u, renderOptions.Log)
 
-	var templateContext map[string]template.ItemValue
-	if configValues != nil {
-		ctx := map[string]template.ItemValue{}
-		for k, v := range configValues.Spec.Values {
-			ctx[k] = template.ItemValue{
-				Value:   v.Value,
-				Default: v.Default,
-			}
-		}
-		templateContext = ctx
-	} else {
-		templateContext = map[string]template.ItemValue{}
-	}
-
 	var cipher *crypto.AESCipher
 	if u.EncryptionKey != "" {
 		c, err := crypto.AESCipherFromString(u.EncryptionKey)
@@ -53,24 +40,12 @@ func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (
 	base := Base{
 		Files: []BaseFile{},
 		Bases: []Base{},
+		Hooks: []BaseFile{},
 	}
 
-	builder := template.Builder{}
-	builder.AddCtx(template.StaticCtx{})
-
-	if config != nil {
-		configCtx, err := builder.NewConfigContext(config.Spec.Groups, templateContext, cipher)
-		if err != nil {
-			return nil, errors.Wrap(err, "failed to create config context")
-		}
-		builder.AddCtx(configCtx)
-	}
-
-	if license != nil {
-		licenseCtx := template.LicenseCtx{
-			License: license,
-		}
-		builder.AddCtx(licenseCtx)
+	builder, err := template.NewBuilder(config, configValues, license, cipher)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create template builder")
 	}
 
 	for _, upstreamFile := range u.Files {
@@ -144,18 +119,45 @@ func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (
 			}
 		}
 
-		localValues, err := kotsHelmChart.Spec.RenderValues(mergedValues)
+		schema, defaultValues, err := getHelmChartSchema(helmUpstream)
 		if err != nil {
-			return nil, errors.Wrap(err, "failed to render local values for chart")
+			return nil, errors.Wrap(err, "failed to get chart schema")
+		}
+		parsedSchema := map[string]interface{}{}
+		if schema != nil {
+			if err := json.Unmarshal(schema, &parsedSchema); err != nil {
+				return nil, errors.Wrap(err, "failed to parse values.schema.json")
+			}
 		}
 
-		for i, localValue := range localValues {
-			renderedValue, err := builder.RenderTemplate(localValue, localValue)
+		helmValues, helmValueSources, err := renderHelmChartValues(kotsHelmChart, mergedValues, builder, parsedSchema)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to render helm value mapping")
+		}
+
+		if schema != nil {
+			if err := validateHelmChartValues(kotsHelmChart.Name, schema, defaultValues, helmValues, helmValueSources); err != nil {
+				return nil, errors.Wrap(err, "failed to validate helm values")
+			}
+		}
+
+		if kotsHelmChart.Spec.UseHelmInstall {
+			releaseNamespace, err := builder.RenderTemplate(kotsHelmChart.Name, kotsHelmChart.Spec.Namespace)
 			if err != nil {
-				return nil, errors.Wrap(err, "failed to render helm value mapping")
+				return nil, errors.Wrap(err, "failed to render helm release namespace")
 			}
 
-			localValues[i] = renderedValue
+			base.HelmReleases = append(base.HelmReleases, HelmRelease{
+				Name:         kotsHelmChart.Name,
+				Namespace:    releaseNamespace,
+				ChartName:    kotsHelmChart.Spec.Chart.Name,
+				ChartVersion: kotsHelmChart.Spec.Chart.ChartVersion,
+				ChartArchive: archive,
+				Values:       helmValues,
+			})
+			// the release is installed by kots apply, so the templates of the chart are not
+			// rendered into the base
+			continue
 		}
 
 		namespace := kotsHelmChart.Spec.Namespace
@@ -166,40 +168,22 @@ func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (
 		helmBase, err := RenderHelm(helmUpstream, &RenderOptions{
 			SplitMultiDocYAML: true,
 			Namespace:         namespace,
-			HelmOptions:       localValues,
+			HelmValues:        helmValues,
+			KubeVersion:       renderOptions.KubeVersion,
+			APIVersions:       renderOptions.APIVersions,
 			Log:               nil,
 		})
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to render helm chart in upstream")
 		}
 
-		helmBaseFiles := []BaseFile{}
-		for _, helmBaseFile := range helmBase.Files {
-			filePath := filepath.Join("charts", kotsHelmChart.Name, helmBaseFile.Path)
-
-			// this is a little bit of an abuse of the next function
-			include, err := helmBaseFile.ShouldBeIncludedInBaseKustomization(false)
-			if err != nil {
-				return nil, errors.Wrapf(err, "failed to determine if file %s should be included in base", filePath)
-			}
-
-			if !include {
-				continue
-			}
-
-			upstreamFile := upstreamtypes.UpstreamFile{
-				Path:    filePath,
-				Content: helmBaseFile.Content,
-			}
-
-			u.Files = append(u.Files, upstreamFile)
-
-			baseFile, err := upstreamFileToBaseFile(upstreamFile, builder, renderOptions.Log)
-			if err != nil {
-				return nil, errors.Wrapf(err, "failed to convert upstream file %s to base", filePath)
-			}
-
-			helmBaseFiles = append(helmBaseFiles, baseFile)
+		helmBaseFiles, err := helmFilesToBaseFiles(u, kotsHelmChart.Name, helmBase.Files, builder, renderOptions.Log)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to convert helm files to base")
+		}
+		helmBaseHooks, err := helmFilesToBaseFiles(u, kotsHelmChart.Name, helmBase.Hooks, builder, renderOptions.Log)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to convert helm hooks to base")
 		}
 
 		if kotsHelmChart.Spec.Namespace != "" {
@@ -207,15 +191,50 @@ func renderReplicated(u *upstreamtypes.Upstream, renderOptions *RenderOptions) (
 				Path:      filepath.Join("charts", kotsHelmChart.Name),
 				Namespace: kotsHelmChart.Spec.Namespace,
 				Files:     helmBaseFiles,
+				Hooks:     helmBaseHooks,
 			})
 		} else {
 			base.Files = append(base.Files, helmBaseFiles...)
+			base.Hooks = append(base.Hooks, helmBaseHooks...)
 		}
 	}
 
 	return &base, nil
 }
 
+func helmFilesToBaseFiles(u *upstreamtypes.Upstream, chartName string, helmFiles []BaseFile, builder template.Builder, log *logger.Logger) ([]BaseFile, error) {
+	baseFiles := []BaseFile{}
+	for _, helmBaseFile := range helmFiles {
+		filePath := filepath.Join("charts", chartName, helmBaseFile.Path)
+
+		// this is a little bit of an abuse of the next function
+		include, err := helmBaseFile.ShouldBeIncludedInBaseKustomization(false)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to determine if file %s should be included in base", filePath)
+		}
+
+		if !include {
+			continue
+		}
+
+		upstreamFile := upstreamtypes.UpstreamFile{
+			Path:    filePath,
+			Content: helmBaseFile.Content,
+		}
+
+		u.Files = append(u.Files, upstreamFile)
+
+		baseFile, err := upstreamFileToBaseFile(upstreamFile, builder, log)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to convert upstream file %s to base", filePath)
+		}
+
+		baseFiles = append(baseFiles, baseFile)
+	}
+
+	return baseFiles, nil
+}
+
 func upstreamFileToBaseFile(upstreamFile types.UpstreamFile, builder template.Builder, log *logger.Logger) (BaseFile, error) {
 	rendered, err := builder.RenderTemplate(upstreamFile.Path, string(upstreamFile.Content))
 	if err != nil {
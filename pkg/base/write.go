@@ -39,11 +39,22 @@ func (b *Base) WriteBase(options WriteOptions) error {
 		}
 	}
 
-	resources, patches, err := deduplicateOnContent(b.Files, options.ExcludeKotsKinds)
+	hooks, err := deployedHooks(b.Hooks)
+	if err != nil {
+		return errors.Wrap(err, "failed to get deployed hooks")
+	}
+	files := append(append([]BaseFile{}, b.Files...), hooks...)
+
+	resources, patches, err := deduplicateOnContent(files, options.ExcludeKotsKinds)
 	if err != nil {
 		return errors.Wrap(err, "failed to deduplicate content")
 	}
 
+	resources, err = sortBaseFilesForInstall(resources)
+	if err != nil {
+		return errors.Wrap(err, "failed to sort resources in install order")
+	}
+
 	kustomizeResources := []string{}
 	kustomizePatches := []kustomizetypes.PatchStrategicMerge{}
 	kustomizeBases := []string{}
@@ -80,6 +91,10 @@ func (b *Base) WriteBase(options WriteOptions) error {
 		kustomizePatches = append(kustomizePatches, kustomizetypes.PatchStrategicMerge(path.Join(".", file.Path)))
 	}
 
+	if err := writeHelmReleases(renderDir, b.HelmReleases); err != nil {
+		return errors.Wrap(err, "failed to write helm releases")
+	}
+
 	for _, base := range b.Bases {
 		if base.Path == "" {
 			return errors.New("kustomize sub-base path cannot be empty")
@@ -103,7 +118,7 @@ func (b *Base) WriteBase(options WriteOptions) error {
 		kustomization.Namespace = b.Namespace
 	}
 
-	if err := k8sutil.WriteKustomizationToFile(&kustomization, path.Join(renderDir, "kustomization.yaml")); err != nil {
+	if err := k8sutil.WriteKustomizationToFileInOrder(&kustomization, path.Join(renderDir, "kustomization.yaml")); err != nil {
 		return errors.Wrap(err, "failed to write kustomization to file")
 	}
 
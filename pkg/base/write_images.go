@@ -17,12 +17,14 @@ type WriteUpstreamImageOptions struct {
 	DestRegistry   registry.RegistryOptions
 	DryRun         bool
 	IsAirgap       bool
-	Log            *logger.Logger
-	ReportWriter   io.Writer
+	// Strict fails when a document in the base can't be parsed, instead of skipping it with a warning
+	Strict       bool
+	Log          *logger.Logger
+	ReportWriter io.Writer
 }
 
 func CopyUpstreamImages(options WriteUpstreamImageOptions) ([]kustomizeimage.Image, error) {
-	newImages, err := image.CopyImages(options.SourceRegistry, options.DestRegistry, options.AppSlug, options.Log, options.ReportWriter, options.BaseDir, options.DryRun, options.IsAirgap)
+	newImages, err := image.CopyImages(options.SourceRegistry, options.DestRegistry, options.AppSlug, options.Log, options.ReportWriter, options.BaseDir, options.DryRun, options.IsAirgap, options.Strict)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to save images")
 	}
@@ -78,6 +78,10 @@ func ApplyValuesToConfig(config *kotsv1beta1.Config, values map[string]template.
 				config.Spec.Groups[idxG].Items[idxI].Value = multitype.FromString(value.ValueStr())
 				config.Spec.Groups[idxG].Items[idxI].Default = multitype.FromString(value.DefaultStr())
 			}
+			if i.Type == template.ComputedItemType {
+				// computed values always come from the template
+				config.Spec.Groups[idxG].Items[idxI].ReadOnly = true
+			}
 			for idxC, c := range i.Items {
 				value, ok := values[c.Name]
 				if ok {
@@ -0,0 +1,239 @@
+package config
+
+import (
+	"encoding/json"
+	"regexp"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/template"
+)
+
+var (
+	templateDelimRegexp = regexp.MustCompile(`repl\{\{|\{\{repl`)
+)
+
+const (
+	jsonSchemaDraft = "http://json-schema.org/draft-07/schema#"
+	openAPIVersion  = "3.0.0"
+)
+
+// JSONSchema is the subset of JSON Schema (draft-07) needed to describe a ConfigValues document.
+// The x-kots extensions carry information that can't be expressed in the schema itself, such as
+// when-conditions that need the template engine to evaluate.
+type JSONSchema struct {
+	Schema               string                 `json:"$schema,omitempty"`
+	Title                string                 `json:"title,omitempty"`
+	Description          string                 `json:"description,omitempty"`
+	Type                 string                 `json:"type,omitempty"`
+	Format               string                 `json:"format,omitempty"`
+	Enum                 []string               `json:"enum,omitempty"`
+	Default              interface{}            `json:"default,omitempty"`
+	ReadOnly             bool                   `json:"readOnly,omitempty"`
+	WriteOnly            bool                   `json:"writeOnly,omitempty"`
+	MinLength            *int                   `json:"minLength,omitempty"`
+	Required             []string               `json:"required,omitempty"`
+	Properties           map[string]*JSONSchema `json:"properties,omitempty"`
+	AdditionalProperties *bool                  `json:"additionalProperties,omitempty"`
+
+	KotsGroup  string `json:"x-kots-group,omitempty"`
+	KotsType   string `json:"x-kots-type,omitempty"`
+	KotsWhen   string `json:"x-kots-when,omitempty"`
+	KotsHidden bool   `json:"x-kots-hidden,omitempty"`
+}
+
+// OpenAPIDocument is an OpenAPI v3 document that only carries component schemas.
+type OpenAPIDocument struct {
+	OpenAPI    string                 `json:"openapi"`
+	Info       OpenAPIInfo            `json:"info"`
+	Paths      map[string]interface{} `json:"paths"`
+	Components OpenAPIComponents      `json:"components"`
+}
+
+type OpenAPIInfo struct {
+	Title   string `json:"title"`
+	Version string `json:"version"`
+}
+
+type OpenAPIComponents struct {
+	Schemas map[string]*JSONSchema `json:"schemas"`
+}
+
+// ConfigToJSONSchema returns a JSON Schema that validates a ConfigValues document for the config.
+func ConfigToJSONSchema(config *kotsv1beta1.Config) (*JSONSchema, error) {
+	if config == nil {
+		return nil, errors.New("config is required")
+	}
+
+	valuesSchema := &JSONSchema{
+		Type:                 "object",
+		Properties:           map[string]*JSONSchema{},
+		AdditionalProperties: boolPtr(false),
+	}
+
+	for _, group := range config.Spec.Groups {
+		for _, item := range group.Items {
+			if item.Type == "label" || item.Type == "heading" {
+				continue
+			}
+
+			itemSchema := configItemToJSONSchema(group, item)
+			valuesSchema.Properties[item.Name] = itemSchema
+
+			if len(itemSchema.Required) > 0 {
+				valuesSchema.Required = append(valuesSchema.Required, item.Name)
+			}
+		}
+	}
+
+	schema := &JSONSchema{
+		Schema: jsonSchemaDraft,
+		Title:  config.Name,
+		Type:   "object",
+		Properties: map[string]*JSONSchema{
+			"apiVersion": {
+				Type: "string",
+				Enum: []string{"kots.io/v1beta1"},
+			},
+			"kind": {
+				Type: "string",
+				Enum: []string{"ConfigValues"},
+			},
+			"metadata": {
+				Type: "object",
+			},
+			"spec": {
+				Type:     "object",
+				Required: []string{"values"},
+				Properties: map[string]*JSONSchema{
+					"values": valuesSchema,
+				},
+			},
+		},
+		Required: []string{"apiVersion", "kind", "spec"},
+	}
+
+	return schema, nil
+}
+
+// ConfigToOpenAPI returns an OpenAPI v3 document with the ConfigValues schema for the config
+// as its only component.
+func ConfigToOpenAPI(config *kotsv1beta1.Config) (*OpenAPIDocument, error) {
+	schema, err := ConfigToJSONSchema(config)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create json schema")
+	}
+
+	// OpenAPI schemas don't declare a draft
+	schema.Schema = ""
+
+	document := &OpenAPIDocument{
+		OpenAPI: openAPIVersion,
+		Info: OpenAPIInfo{
+			Title:   config.Name,
+			Version: "v1beta1",
+		},
+		Paths: map[string]interface{}{},
+		Components: OpenAPIComponents{
+			Schemas: map[string]*JSONSchema{
+				"ConfigValues": schema,
+			},
+		},
+	}
+
+	return document, nil
+}
+
+// MarshalConfigSchema renders the schema for the config in the given format, either
+// "json-schema" or "openapi".
+func MarshalConfigSchema(config *kotsv1beta1.Config, format string) ([]byte, error) {
+	var schema interface{}
+	switch format {
+	case "", "json-schema":
+		s, err := ConfigToJSONSchema(config)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create json schema")
+		}
+		schema = s
+	case "openapi":
+		s, err := ConfigToOpenAPI(config)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create openapi document")
+		}
+		schema = s
+	default:
+		return nil, errors.Errorf("unknown schema format %q", format)
+	}
+
+	b, err := json.MarshalIndent(schema, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal schema")
+	}
+
+	return b, nil
+}
+
+func configItemToJSONSchema(group kotsv1beta1.ConfigGroup, item kotsv1beta1.ConfigItem) *JSONSchema {
+	valueSchema := &JSONSchema{
+		Title:       item.Title,
+		Description: item.HelpText,
+		Type:        "string",
+		ReadOnly:    item.ReadOnly || item.Type == template.ComputedItemType,
+	}
+
+	// defaults that are templates can't be represented in the schema
+	if item.Default.String() != "" && !isTemplate(item.Default.String()) {
+		valueSchema.Default = item.Default.String()
+	}
+
+	switch item.Type {
+	case "bool":
+		valueSchema.Enum = []string{"0", "1"}
+	case "select_one":
+		for _, child := range item.Items {
+			valueSchema.Enum = append(valueSchema.Enum, child.Name)
+		}
+	case "password":
+		valueSchema.Format = "password"
+		valueSchema.WriteOnly = true
+	}
+
+	// items with a when-condition may not be shown, so they can't be required here
+	isRequired := item.Required && item.When == "" && item.Default.String() == ""
+	if isRequired {
+		valueSchema.MinLength = intPtr(1)
+	}
+
+	itemSchema := &JSONSchema{
+		Title:       item.Title,
+		Description: item.HelpText,
+		Type:        "object",
+		Properties: map[string]*JSONSchema{
+			"value":   valueSchema,
+			"default": {Type: "string"},
+			"data":    {Type: "string"},
+		},
+		AdditionalProperties: boolPtr(false),
+		KotsGroup:            group.Name,
+		KotsType:             item.Type,
+		KotsWhen:             item.When,
+		KotsHidden:           item.Hidden,
+	}
+	if isRequired {
+		itemSchema.Required = []string{"value"}
+	}
+
+	return itemSchema
+}
+
+func isTemplate(text string) bool {
+	return templateDelimRegexp.MatchString(text)
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func intPtr(i int) *int {
+	return &i
+}
@@ -0,0 +1,90 @@
+package config
+
+import (
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/kotskinds/multitype"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func Test_ConfigToJSONSchema(t *testing.T) {
+	req := require.New(t)
+
+	config := &kotsv1beta1.Config{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: "test-app",
+		},
+		Spec: kotsv1beta1.ConfigSpec{
+			Groups: []kotsv1beta1.ConfigGroup{
+				{
+					Name: "database",
+					Items: []kotsv1beta1.ConfigItem{
+						{
+							Name:     "db_host",
+							Type:     "text",
+							Title:    "Database Host",
+							Required: true,
+						},
+						{
+							Name:    "db_port",
+							Type:    "text",
+							Default: multitype.FromString("5432"),
+						},
+						{
+							Name:     "db_password",
+							Type:     "password",
+							Required: true,
+							When:     `repl{{ ConfigOptionEquals "db_host" "external" }}`,
+						},
+						{
+							Name:    "db_ssl",
+							Type:    "bool",
+							Default: multitype.FromString(`repl{{ ConfigOption "db_port" }}`),
+						},
+						{
+							Name: "db_size",
+							Type: "select_one",
+							Items: []kotsv1beta1.ConfigChildItem{
+								{Name: "small"},
+								{Name: "large"},
+							},
+						},
+						{
+							Name:  "db_url",
+							Type:  "computed",
+							Value: multitype.FromString(`repl{{ ConfigOption "db_host" }}`),
+						},
+						{
+							Name: "db_label",
+							Type: "label",
+						},
+					},
+				},
+			},
+		},
+	}
+
+	schema, err := ConfigToJSONSchema(config)
+	req.NoError(err)
+
+	values := schema.Properties["spec"].Properties["values"]
+	req.Equal([]string{"db_host"}, values.Required)
+	req.NotContains(values.Properties, "db_label")
+
+	req.Equal("5432", values.Properties["db_port"].Properties["value"].Default)
+	req.Nil(values.Properties["db_ssl"].Properties["value"].Default)
+	req.Equal([]string{"0", "1"}, values.Properties["db_ssl"].Properties["value"].Enum)
+	req.Equal([]string{"small", "large"}, values.Properties["db_size"].Properties["value"].Enum)
+	req.Equal("password", values.Properties["db_password"].Properties["value"].Format)
+	req.Equal(config.Spec.Groups[0].Items[2].When, values.Properties["db_password"].KotsWhen)
+	req.True(values.Properties["db_url"].Properties["value"].ReadOnly)
+
+	openAPI, err := ConfigToOpenAPI(config)
+	req.NoError(err)
+	req.Equal("", openAPI.Components.Schemas["ConfigValues"].Schema)
+
+	_, err = MarshalConfigSchema(config, "xml")
+	req.Error(err)
+}
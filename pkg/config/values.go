@@ -0,0 +1,319 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/replicatedhq/kots/pkg/template"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// AppConfig holds the config spec and the user supplied config values of an app
+// that has been pulled to the local filesystem.
+type AppConfig struct {
+	AppDir       string
+	Config       *kotsv1beta1.Config
+	ConfigValues *kotsv1beta1.ConfigValues
+	Installation *kotsv1beta1.Installation
+	License      *kotsv1beta1.License
+
+	cipher *crypto.AESCipher
+}
+
+// LoadAppConfig reads the config spec from the upstream in appDir, along with the
+// config values, installation and license from upstream/userdata.
+func LoadAppConfig(appDir string) (*AppConfig, error) {
+	upstreamDir := filepath.Join(appDir, "upstream")
+	if _, err := os.Stat(upstreamDir); err != nil {
+		return nil, errors.Wrapf(err, "failed to find upstream in %s", appDir)
+	}
+
+	appConfig := AppConfig{
+		AppDir: appDir,
+	}
+
+	err := filepath.Walk(upstreamDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if info.IsDir() {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+
+		decode := scheme.Codecs.UniversalDeserializer().Decode
+		obj, gvk, err := decode(content, nil, nil)
+		if err != nil {
+			return nil
+		}
+
+		if gvk.Group != "kots.io" || gvk.Version != "v1beta1" {
+			return nil
+		}
+
+		switch gvk.Kind {
+		case "Config":
+			appConfig.Config = obj.(*kotsv1beta1.Config)
+		case "ConfigValues":
+			appConfig.ConfigValues = obj.(*kotsv1beta1.ConfigValues)
+		case "Installation":
+			appConfig.Installation = obj.(*kotsv1beta1.Installation)
+		case "License":
+			appConfig.License = obj.(*kotsv1beta1.License)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk upstream dir")
+	}
+
+	if appConfig.Config == nil {
+		return nil, errors.New("the application does not have a config")
+	}
+
+	if appConfig.ConfigValues == nil {
+		appConfig.ConfigValues = &kotsv1beta1.ConfigValues{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "kots.io/v1beta1",
+				Kind:       "ConfigValues",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: appConfig.Config.Name,
+			},
+		}
+	}
+	if appConfig.ConfigValues.Spec.Values == nil {
+		appConfig.ConfigValues.Spec.Values = map[string]kotsv1beta1.ConfigValue{}
+	}
+
+	if appConfig.Installation != nil && appConfig.Installation.Spec.EncryptionKey != "" {
+		cipher, err := crypto.AESCipherFromString(appConfig.Installation.Spec.EncryptionKey)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create cipher from installation")
+		}
+		appConfig.cipher = cipher
+	}
+
+	return &appConfig, nil
+}
+
+// GetValue returns the value of the named config item, falling back to the default.
+// Password values are returned decrypted.
+func (a *AppConfig) GetValue(name string) (string, error) {
+	item, err := a.findItem(name)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := a.ConfigValues.Spec.Values[name]
+	if !ok || value.Value == "" {
+		if ok && value.Default != "" {
+			return value.Default, nil
+		}
+		return item.Default.String(), nil
+	}
+
+	if item.Type == "password" {
+		decrypted, err := a.decrypt(value.Value)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to decrypt value of %s", name)
+		}
+		return decrypted, nil
+	}
+
+	return value.Value, nil
+}
+
+// SetValue validates value against the config item and stores it. Password values are
+// encrypted with the installation key.
+func (a *AppConfig) SetValue(name string, value string) error {
+	item, err := a.findItem(name)
+	if err != nil {
+		return err
+	}
+
+	if err := validateItemValue(item, value); err != nil {
+		return err
+	}
+
+	if item.Type == "password" {
+		encrypted, err := a.encrypt(value)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encrypt value of %s", name)
+		}
+		value = encrypted
+	}
+
+	configValue := a.ConfigValues.Spec.Values[name]
+	configValue.Value = value
+	a.ConfigValues.Spec.Values[name] = configValue
+
+	return nil
+}
+
+// UnsetValue removes the user supplied value of the config item, so that the default is used.
+func (a *AppConfig) UnsetValue(name string) error {
+	item, err := a.findItem(name)
+	if err != nil {
+		return err
+	}
+
+	if item.Required && item.Default.String() == "" {
+		return errors.Errorf("config item %s is required and has no default", name)
+	}
+
+	configValue, ok := a.ConfigValues.Spec.Values[name]
+	if !ok {
+		return nil
+	}
+	configValue.Value = ""
+	if configValue.Default == "" && configValue.Data == "" {
+		delete(a.ConfigValues.Spec.Values, name)
+	} else {
+		a.ConfigValues.Spec.Values[name] = configValue
+	}
+
+	return nil
+}
+
+// ExportValues returns a copy of the config values with password values decrypted, so that
+// they can be imported into an installation with a different encryption key.
+func (a *AppConfig) ExportValues() (*kotsv1beta1.ConfigValues, error) {
+	exported := a.ConfigValues.DeepCopy()
+
+	for name, value := range exported.Spec.Values {
+		item, err := a.findItem(name)
+		if err != nil || item.Type != "password" || value.Value == "" {
+			continue
+		}
+
+		decrypted, err := a.decrypt(value.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decrypt value of %s", name)
+		}
+		value.Value = decrypted
+		exported.Spec.Values[name] = value
+	}
+
+	return exported, nil
+}
+
+// ImportValues sets every value in values. Password values are expected in plain text, as
+// written by ExportValues. Nothing is changed if any value fails validation.
+func (a *AppConfig) ImportValues(values *kotsv1beta1.ConfigValues) error {
+	previousValues := a.ConfigValues.DeepCopy()
+
+	for name, value := range values.Spec.Values {
+		if value.Value == "" {
+			continue
+		}
+		if err := a.SetValue(name, value.Value); err != nil {
+			a.ConfigValues = previousValues
+			return errors.Wrapf(err, "failed to import value of %s", name)
+		}
+	}
+
+	return nil
+}
+
+// Save writes the config values to upstream/userdata/config.yaml.
+func (a *AppConfig) Save() error {
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	var b bytes.Buffer
+	if err := s.Encode(a.ConfigValues, &b); err != nil {
+		return errors.Wrap(err, "failed to marshal config values")
+	}
+
+	userdataDir := filepath.Join(a.AppDir, "upstream", "userdata")
+	if err := os.MkdirAll(userdataDir, 0755); err != nil {
+		return errors.Wrap(err, "failed to create userdata dir")
+	}
+
+	if err := ioutil.WriteFile(filepath.Join(userdataDir, "config.yaml"), b.Bytes(), 0644); err != nil {
+		return errors.Wrap(err, "failed to write config values")
+	}
+
+	return nil
+}
+
+func (a *AppConfig) findItem(name string) (*kotsv1beta1.ConfigItem, error) {
+	for _, group := range a.Config.Spec.Groups {
+		for _, item := range group.Items {
+			if item.Name == name {
+				return &item, nil
+			}
+		}
+	}
+
+	return nil, errors.Errorf("config item %s not found", name)
+}
+
+func (a *AppConfig) encrypt(value string) (string, error) {
+	if a.cipher == nil {
+		return "", errors.New("installation does not have an encryption key")
+	}
+
+	return base64.StdEncoding.EncodeToString(a.cipher.Encrypt([]byte(value))), nil
+}
+
+func (a *AppConfig) decrypt(value string) (string, error) {
+	if a.cipher == nil {
+		return "", errors.New("installation does not have an encryption key")
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to base64 decode")
+	}
+
+	decrypted, err := a.cipher.Decrypt(decoded)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to decrypt")
+	}
+
+	return string(decrypted), nil
+}
+
+func validateItemValue(item *kotsv1beta1.ConfigItem, value string) error {
+	if item.ReadOnly || item.Type == template.ComputedItemType || item.Type == "label" || item.Type == "heading" {
+		return errors.Errorf("config item %s cannot be set", item.Name)
+	}
+
+	if item.Required && value == "" {
+		return errors.Errorf("config item %s is required", item.Name)
+	}
+
+	switch item.Type {
+	case "bool":
+		if value != "0" && value != "1" {
+			return errors.Errorf("config item %s is a bool, value must be 0 or 1", item.Name)
+		}
+	case "select_one":
+		options := []string{}
+		for _, child := range item.Items {
+			if child.Name == value {
+				return nil
+			}
+			options = append(options, child.Name)
+		}
+		return errors.Errorf("config item %s must be one of %s", item.Name, strings.Join(options, ", "))
+	}
+
+	return nil
+}
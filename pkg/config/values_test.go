@@ -0,0 +1,102 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/crypto"
+	"github.com/stretchr/testify/require"
+)
+
+var testConfigSpec = `apiVersion: kots.io/v1beta1
+kind: Config
+metadata:
+  name: test-app
+spec:
+  groups:
+  - name: settings
+    title: Settings
+    items:
+    - name: hostname
+      type: text
+      default: example.com
+    - name: db_password
+      type: password
+    - name: enable_tls
+      type: bool
+      default: "0"
+    - name: size
+      type: select_one
+      default: small
+      items:
+      - name: small
+        title: Small
+      - name: large
+        title: Large
+`
+
+func writeTestApp(t *testing.T) string {
+	req := require.New(t)
+
+	appDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+
+	cipher, err := crypto.NewAESCipher()
+	req.NoError(err)
+
+	installation := `apiVersion: kots.io/v1beta1
+kind: Installation
+metadata:
+  name: test-app
+spec:
+  encryptionKey: ` + cipher.ToString() + `
+`
+
+	req.NoError(os.MkdirAll(filepath.Join(appDir, "upstream", "userdata"), 0755))
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "upstream", "config.yaml"), []byte(testConfigSpec), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "upstream", "userdata", "installation.yaml"), []byte(installation), 0644))
+
+	return appDir
+}
+
+func Test_AppConfigSetGet(t *testing.T) {
+	req := require.New(t)
+
+	appDir := writeTestApp(t)
+	defer os.RemoveAll(appDir)
+
+	appConfig, err := LoadAppConfig(appDir)
+	req.NoError(err)
+
+	value, err := appConfig.GetValue("hostname")
+	req.NoError(err)
+	req.Equal("example.com", value)
+
+	req.NoError(appConfig.SetValue("hostname", "kots.io"))
+	req.NoError(appConfig.SetValue("db_password", "secret"))
+	req.Error(appConfig.SetValue("enable_tls", "true"))
+	req.Error(appConfig.SetValue("size", "medium"))
+	req.Error(appConfig.SetValue("missing", "value"))
+	req.NoError(appConfig.Save())
+
+	reloaded, err := LoadAppConfig(appDir)
+	req.NoError(err)
+
+	req.NotEqual("secret", reloaded.ConfigValues.Spec.Values["db_password"].Value)
+
+	value, err = reloaded.GetValue("db_password")
+	req.NoError(err)
+	req.Equal("secret", value)
+
+	exported, err := reloaded.ExportValues()
+	req.NoError(err)
+	req.Equal("secret", exported.Spec.Values["db_password"].Value)
+	req.Equal("kots.io", exported.Spec.Values["hostname"].Value)
+
+	req.NoError(reloaded.UnsetValue("hostname"))
+	value, err = reloaded.GetValue("hostname")
+	req.NoError(err)
+	req.Equal("example.com", value)
+}
@@ -8,6 +8,8 @@ import (
 type Downstream struct {
 	Kustomization *kustomizetypes.Kustomization
 	Midstream     *midstream.Midstream
+	// Spec is the customization of this downstream, if one was given
+	Spec *Spec
 }
 
 func CreateDownstream(m *midstream.Midstream, name string) (*Downstream, error) {
@@ -0,0 +1,59 @@
+package downstream
+
+import (
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	"sigs.k8s.io/kustomize/v3/pkg/image"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+	"sigs.k8s.io/yaml"
+)
+
+// Spec customizes a downstream. It's read from a local file and merged into the
+// downstream kustomization on each pull.
+type Spec struct {
+	Namespace    string                   `json:"namespace,omitempty"`
+	CommonLabels map[string]string        `json:"commonLabels,omitempty"`
+	Images       []image.Image            `json:"images,omitempty"`
+	Replicas     []kustomizetypes.Replica `json:"replicas,omitempty"`
+	// Patches are strategic merge patch files, relative to the spec file. They are
+	// copied into the downstream.
+	Patches []string `json:"patches,omitempty"`
+
+	patchContents map[string][]byte
+}
+
+// LoadSpec reads a downstream spec and the patch files it references
+func LoadSpec(filename string) (*Spec, error) {
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read downstream spec")
+	}
+
+	spec := Spec{}
+	if err := yaml.Unmarshal(b, &spec); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal downstream spec")
+	}
+
+	spec.patchContents = map[string][]byte{}
+	for _, patch := range spec.Patches {
+		patchPath := patch
+		if !filepath.IsAbs(patchPath) {
+			patchPath = filepath.Join(filepath.Dir(filename), patch)
+		}
+
+		content, err := ioutil.ReadFile(patchPath)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read patch %s", patch)
+		}
+
+		name := filepath.Base(patch)
+		if _, ok := spec.patchContents[name]; ok {
+			return nil, errors.Errorf("more than one patch is named %s", name)
+		}
+		spec.patchContents[name] = content
+	}
+
+	return &spec, nil
+}
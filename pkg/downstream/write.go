@@ -1,14 +1,37 @@
 package downstream
 
 import (
+	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
+	"strings"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"sigs.k8s.io/kustomize/v3/pkg/image"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+	"sigs.k8s.io/yaml"
 )
 
+// appliedSpecFilename records the fields of the kustomization and the patches in the downstream that were
+// set from the spec, so that the ones that are dropped from the spec are removed on the next pull
+const appliedSpecFilename = "applied-spec.yaml"
+
+// specPatchPrefix is prepended to the names of the patches copied from the spec, so they don't replace the
+// patches that were added to the downstream by hand
+const specPatchPrefix = "spec-"
+
+// appliedSpec is what was set in the downstream from the spec on the last pull
+type appliedSpec struct {
+	Namespace    string   `json:"namespace,omitempty"`
+	CommonLabels []string `json:"commonLabels,omitempty"`
+	Images       []string `json:"images,omitempty"`
+	Replicas     []string `json:"replicas,omitempty"`
+	Patches      []string `json:"patches,omitempty"`
+}
+
 type WriteOptions struct {
 	DownstreamDir string
 	MidstreamDir  string
@@ -21,17 +44,28 @@ func (d *Downstream) WriteDownstream(options WriteOptions) error {
 	}
 
 	renderDir := options.DownstreamDir
+	fileRenderPath := path.Join(renderDir, "kustomization.yaml")
 
+	var existingKustomization *kustomizetypes.Kustomization
 	_, err = os.Stat(renderDir)
 	if err == nil {
 		// We intentionally don't support overwriting downstreams...  this is user-created content
 		// and the user should be intentional about removing it
-
 		// But it's also not an error
-		return nil
+		if d.Spec == nil {
+			return nil
+		}
+
+		// A spec is merged into the existing kustomization, keeping everything that was edited by hand
+		if _, err := os.Stat(fileRenderPath); err == nil {
+			k, err := k8sutil.ReadKustomizationFromFile(fileRenderPath)
+			if err != nil {
+				return errors.Wrap(err, "load existing kustomization")
+			}
+			existingKustomization = k
+		}
 	}
 
-	fileRenderPath := path.Join(renderDir, "kustomization.yaml")
 	dir, _ := path.Split(fileRenderPath)
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
 		if err := os.MkdirAll(dir, 0744); err != nil {
@@ -43,9 +77,259 @@ func (d *Downstream) WriteDownstream(options WriteOptions) error {
 		relativeMidstreamDir,
 	}
 
+	previous, err := readAppliedSpec(renderDir)
+	if err != nil {
+		return errors.Wrap(err, "failed to read applied spec")
+	}
+
+	if err := d.applySpec(renderDir, previous); err != nil {
+		return errors.Wrap(err, "failed to apply downstream spec")
+	}
+
+	if err := d.removeDroppedSpecPatches(renderDir, previous); err != nil {
+		return errors.Wrap(err, "failed to remove dropped spec patches")
+	}
+
+	d.mergeKustomization(existingKustomization, previous)
+
 	if err := k8sutil.WriteKustomizationToFile(d.Kustomization, fileRenderPath); err != nil {
 		return errors.Wrap(err, "failed to write kustomization to file")
 	}
 
+	if err := d.writeAppliedSpec(renderDir); err != nil {
+		return errors.Wrap(err, "failed to write applied spec")
+	}
+
+	return nil
+}
+
+// SetsNamespace returns true if the downstream in the dir will set the namespace of all of its objects, from
+// the spec or from its existing kustomization. A namespace in the kustomization that was set from a previous
+// spec and was dropped from the spec is removed when the downstream is written, so it doesn't count.
+func SetsNamespace(downstreamDir string, spec *Spec) (bool, error) {
+	if spec != nil && spec.Namespace != "" {
+		return true, nil
+	}
+
+	kustomizationFile := path.Join(downstreamDir, "kustomization.yaml")
+	if _, err := os.Stat(kustomizationFile); os.IsNotExist(err) {
+		return false, nil
+	}
+	k, err := k8sutil.ReadKustomizationFromFile(kustomizationFile)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read kustomization")
+	}
+	if k.Namespace == "" {
+		return false, nil
+	}
+	if spec == nil {
+		return true, nil
+	}
+
+	previous, err := readAppliedSpec(downstreamDir)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to read applied spec")
+	}
+	return k.Namespace != previous.Namespace, nil
+}
+
+func readAppliedSpec(renderDir string) (*appliedSpec, error) {
+	applied := appliedSpec{}
+
+	b, err := ioutil.ReadFile(path.Join(renderDir, appliedSpecFilename))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &applied, nil
+		}
+		return nil, errors.Wrap(err, "failed to read file")
+	}
+
+	if err := yaml.Unmarshal(b, &applied); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal")
+	}
+	return &applied, nil
+}
+
+func (d *Downstream) writeAppliedSpec(renderDir string) error {
+	if d.Spec == nil {
+		return nil
+	}
+
+	applied := appliedSpec{
+		Namespace:    d.Spec.Namespace,
+		CommonLabels: []string{},
+		Images:       []string{},
+		Replicas:     []string{},
+		Patches:      d.specPatchFilenames(),
+	}
+	for key := range d.Spec.CommonLabels {
+		applied.CommonLabels = append(applied.CommonLabels, key)
+	}
+	sort.Strings(applied.CommonLabels)
+	for _, specImage := range d.Spec.Images {
+		applied.Images = append(applied.Images, specImage.Name)
+	}
+	for _, replica := range d.Spec.Replicas {
+		applied.Replicas = append(applied.Replicas, replica.Name)
+	}
+
+	b, err := yaml.Marshal(applied)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal")
+	}
+	if err := ioutil.WriteFile(path.Join(renderDir, appliedSpecFilename), b, 0644); err != nil {
+		return errors.Wrap(err, "failed to write file")
+	}
+	return nil
+}
+
+// specPatchFilenames returns the sorted names of the files that the patches of the spec are written to
+func (d *Downstream) specPatchFilenames() []string {
+	filenames := []string{}
+	for name := range d.Spec.patchContents {
+		filenames = append(filenames, specPatchPrefix+name)
+	}
+	sort.Strings(filenames)
+	return filenames
+}
+
+// removeDroppedSpecPatches deletes the files of the patches that were copied from the previous spec and are
+// not in the spec anymore
+func (d *Downstream) removeDroppedSpecPatches(renderDir string, previous *appliedSpec) error {
+	if d.Spec == nil {
+		return nil
+	}
+
+	for _, filename := range k8sutil.FindNewStrings(previous.Patches, d.specPatchFilenames()) {
+		err := os.Remove(path.Join(renderDir, filename))
+		if err != nil && !os.IsNotExist(err) {
+			return errors.Wrapf(err, "failed to remove patch %s", filename)
+		}
+	}
+
+	return nil
+}
+
+// applySpec sets the fields of the spec in the kustomization and writes the patches of the spec. A patch
+// file that exists in the downstream and was not written from the spec is not overwritten.
+func (d *Downstream) applySpec(renderDir string, previous *appliedSpec) error {
+	if d.Spec == nil {
+		return nil
+	}
+
+	d.Kustomization.Namespace = d.Spec.Namespace
+	d.Kustomization.CommonLabels = d.Spec.CommonLabels
+	d.Kustomization.Images = d.Spec.Images
+	d.Kustomization.Replicas = d.Spec.Replicas
+
+	previousPatches := map[string]bool{}
+	for _, filename := range previous.Patches {
+		previousPatches[filename] = true
+	}
+
+	for _, filename := range d.specPatchFilenames() {
+		filePath := path.Join(renderDir, filename)
+		if _, err := os.Stat(filePath); err == nil && !previousPatches[filename] {
+			return errors.Errorf("patch %s was not written from the spec and would be overwritten", filename)
+		}
+
+		content := d.Spec.patchContents[strings.TrimPrefix(filename, specPatchPrefix)]
+		if err := ioutil.WriteFile(filePath, content, 0644); err != nil {
+			return errors.Wrapf(err, "failed to write patch %s", filename)
+		}
+		d.Kustomization.PatchesStrategicMerge = append(d.Kustomization.PatchesStrategicMerge, kustomizetypes.PatchStrategicMerge(filename))
+	}
+
 	return nil
 }
+
+// mergeKustomization starts from the existing kustomization so that fields edited by hand are kept,
+// removes the values that were set from the previous spec and were dropped from the spec, and sets the
+// values from the spec over it.
+func (d *Downstream) mergeKustomization(existing *kustomizetypes.Kustomization, previous *appliedSpec) {
+	if existing == nil {
+		return
+	}
+
+	merged := *existing
+
+	newBases := k8sutil.FindNewStrings(d.Kustomization.Bases, existing.Bases)
+	merged.Bases = append(existing.Bases, newBases...)
+
+	if d.Kustomization.Namespace != "" {
+		merged.Namespace = d.Kustomization.Namespace
+	} else if previous.Namespace != "" && existing.Namespace == previous.Namespace {
+		merged.Namespace = ""
+	}
+
+	droppedLabels := map[string]bool{}
+	for _, key := range previous.CommonLabels {
+		if _, ok := d.Kustomization.CommonLabels[key]; !ok {
+			droppedLabels[key] = true
+		}
+	}
+	labels := map[string]string{}
+	for k, v := range existing.CommonLabels {
+		if !droppedLabels[k] {
+			labels[k] = v
+		}
+	}
+	for k, v := range d.Kustomization.CommonLabels {
+		labels[k] = v
+	}
+	if len(labels) > 0 {
+		merged.CommonLabels = labels
+	} else {
+		merged.CommonLabels = nil
+	}
+
+	specImages := []string{}
+	for _, specImage := range d.Kustomization.Images {
+		specImages = append(specImages, specImage.Name)
+	}
+	droppedImages := k8sutil.FindNewStrings(previous.Images, specImages)
+	existingImages := []image.Image{}
+	for _, existingImage := range existing.Images {
+		if !containsString(droppedImages, existingImage.Name) {
+			existingImages = append(existingImages, existingImage)
+		}
+	}
+	filteredImages := k8sutil.RemoveExistingImages(d.Kustomization.Images, existingImages)
+	merged.Images = append(d.Kustomization.Images, filteredImages...)
+
+	specReplicas := []string{}
+	for _, replica := range d.Kustomization.Replicas {
+		specReplicas = append(specReplicas, replica.Name)
+	}
+	droppedReplicas := k8sutil.FindNewStrings(previous.Replicas, specReplicas)
+	existingReplicas := []kustomizetypes.Replica{}
+	for _, replica := range existing.Replicas {
+		if !containsString(droppedReplicas, replica.Name) {
+			existingReplicas = append(existingReplicas, replica)
+		}
+	}
+	filteredReplicas := k8sutil.RemoveExistingReplicas(d.Kustomization.Replicas, existingReplicas)
+	merged.Replicas = append(d.Kustomization.Replicas, filteredReplicas...)
+
+	droppedPatches := k8sutil.FindNewStrings(previous.Patches, d.specPatchFilenames())
+	patches := []kustomizetypes.PatchStrategicMerge{}
+	for _, patch := range existing.PatchesStrategicMerge {
+		if !containsString(droppedPatches, string(patch)) {
+			patches = append(patches, patch)
+		}
+	}
+
+	newPatches := k8sutil.FindNewPatches(d.Kustomization.PatchesStrategicMerge, patches)
+	merged.PatchesStrategicMerge = append(patches, newPatches...)
+
+	d.Kustomization = &merged
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,160 @@
+package downstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/kustomize/v3/pkg/image"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+)
+
+func Test_WriteDownstreamWithSpec(t *testing.T) {
+	req := require.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	specDir := filepath.Join(tmpDir, "specs")
+	err = os.MkdirAll(filepath.Join(specDir, "patches"), 0755)
+	req.NoError(err)
+	err = ioutil.WriteFile(filepath.Join(specDir, "patches", "resources.yaml"), []byte("apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\n"), 0644)
+	req.NoError(err)
+	err = ioutil.WriteFile(filepath.Join(specDir, "staging.yaml"), []byte(`namespace: staging
+commonLabels:
+  env: staging
+images:
+  - name: nginx
+    newTag: "1.17"
+replicas:
+  - name: web
+    count: 1
+patches:
+  - patches/resources.yaml
+`), 0644)
+	req.NoError(err)
+
+	writeOptions := WriteOptions{
+		DownstreamDir: filepath.Join(tmpDir, "app", "overlays", "downstreams", "staging"),
+		MidstreamDir:  filepath.Join(tmpDir, "app", "overlays", "midstream"),
+	}
+
+	spec, err := LoadSpec(filepath.Join(specDir, "staging.yaml"))
+	req.NoError(err)
+	d, err := CreateDownstream(nil, "staging")
+	req.NoError(err)
+	d.Spec = spec
+	err = d.WriteDownstream(writeOptions)
+	req.NoError(err)
+
+	kustomizationFile := filepath.Join(writeOptions.DownstreamDir, "kustomization.yaml")
+	k, err := k8sutil.ReadKustomizationFromFile(kustomizationFile)
+	req.NoError(err)
+	req.Equal("staging", k.Namespace)
+	req.Equal([]string{"../../midstream"}, k.Bases)
+	req.Equal([]kustomizetypes.PatchStrategicMerge{"spec-resources.yaml"}, k.PatchesStrategicMerge)
+	_, err = os.Stat(filepath.Join(writeOptions.DownstreamDir, "spec-resources.yaml"))
+	req.NoError(err)
+
+	// edits by hand are kept on the next pull
+	k.Resources = append(k.Resources, "extra.yaml")
+	k.CommonLabels["team"] = "web"
+	k.Images = append(k.Images, image.Image{Name: "redis", NewTag: "5"})
+	k.PatchesStrategicMerge = append(k.PatchesStrategicMerge, "hand-edited.yaml")
+	err = k8sutil.WriteKustomizationToFile(k, kustomizationFile)
+	req.NoError(err)
+
+	spec.Images = []image.Image{{Name: "nginx", NewTag: "1.18"}}
+	spec.Replicas = []kustomizetypes.Replica{{Name: "web", Count: 2}}
+	d, err = CreateDownstream(nil, "staging")
+	req.NoError(err)
+	d.Spec = spec
+	err = d.WriteDownstream(writeOptions)
+	req.NoError(err)
+
+	k, err = k8sutil.ReadKustomizationFromFile(kustomizationFile)
+	req.NoError(err)
+	req.Equal("staging", k.Namespace)
+	req.Equal([]string{"../../midstream"}, k.Bases)
+	req.Equal([]string{"extra.yaml"}, k.Resources)
+	req.Equal(map[string]string{"env": "staging", "team": "web"}, k.CommonLabels)
+	req.Equal([]image.Image{{Name: "nginx", NewTag: "1.18"}, {Name: "redis", NewTag: "5"}}, k.Images)
+	req.Equal([]kustomizetypes.Replica{{Name: "web", Count: 2}}, k.Replicas)
+	req.Equal([]kustomizetypes.PatchStrategicMerge{"hand-edited.yaml", "spec-resources.yaml"}, k.PatchesStrategicMerge)
+
+	// the fields and patches that are dropped from the spec are removed, and the ones added by hand are kept
+	err = ioutil.WriteFile(filepath.Join(specDir, "staging.yaml"), []byte("namespace: staging\n"), 0644)
+	req.NoError(err)
+	spec, err = LoadSpec(filepath.Join(specDir, "staging.yaml"))
+	req.NoError(err)
+	d, err = CreateDownstream(nil, "staging")
+	req.NoError(err)
+	d.Spec = spec
+	err = d.WriteDownstream(writeOptions)
+	req.NoError(err)
+
+	k, err = k8sutil.ReadKustomizationFromFile(kustomizationFile)
+	req.NoError(err)
+	req.Equal("staging", k.Namespace)
+	req.Equal(map[string]string{"team": "web"}, k.CommonLabels)
+	req.Equal([]image.Image{{Name: "redis", NewTag: "5"}}, k.Images)
+	req.Empty(k.Replicas)
+	req.Equal([]kustomizetypes.PatchStrategicMerge{"hand-edited.yaml"}, k.PatchesStrategicMerge)
+	_, err = os.Stat(filepath.Join(writeOptions.DownstreamDir, "spec-resources.yaml"))
+	req.True(os.IsNotExist(err))
+
+	setsNamespace, err := SetsNamespace(writeOptions.DownstreamDir, spec)
+	req.NoError(err)
+	req.True(setsNamespace)
+
+	// a namespace that is dropped from the spec is removed
+	err = ioutil.WriteFile(filepath.Join(specDir, "staging.yaml"), []byte("commonLabels:\n  env: staging\n"), 0644)
+	req.NoError(err)
+	spec, err = LoadSpec(filepath.Join(specDir, "staging.yaml"))
+	req.NoError(err)
+	setsNamespace, err = SetsNamespace(writeOptions.DownstreamDir, spec)
+	req.NoError(err)
+	req.False(setsNamespace)
+	d, err = CreateDownstream(nil, "staging")
+	req.NoError(err)
+	d.Spec = spec
+	err = d.WriteDownstream(writeOptions)
+	req.NoError(err)
+
+	k, err = k8sutil.ReadKustomizationFromFile(kustomizationFile)
+	req.NoError(err)
+	req.Equal("", k.Namespace)
+	req.Equal(map[string]string{"env": "staging", "team": "web"}, k.CommonLabels)
+
+	// a patch from the spec does not replace a patch with the same name that was not written from the spec
+	err = ioutil.WriteFile(filepath.Join(writeOptions.DownstreamDir, "spec-resources.yaml"), []byte("hand: written\n"), 0644)
+	req.NoError(err)
+	spec, err = LoadSpec(filepath.Join(specDir, "staging.yaml"))
+	req.NoError(err)
+	spec.Patches = []string{"patches/resources.yaml"}
+	spec.patchContents = map[string][]byte{"resources.yaml": []byte("apiVersion: apps/v1\n")}
+	d, err = CreateDownstream(nil, "staging")
+	req.NoError(err)
+	d.Spec = spec
+	err = d.WriteDownstream(writeOptions)
+	req.EqualError(err, "failed to apply downstream spec: patch spec-resources.yaml was not written from the spec and would be overwritten")
+	content, err := ioutil.ReadFile(filepath.Join(writeOptions.DownstreamDir, "spec-resources.yaml"))
+	req.NoError(err)
+	req.Equal("hand: written\n", string(content))
+	err = os.Remove(filepath.Join(writeOptions.DownstreamDir, "spec-resources.yaml"))
+	req.NoError(err)
+
+	// without a spec, an existing downstream is not changed
+	d, err = CreateDownstream(nil, "staging")
+	req.NoError(err)
+	err = d.WriteDownstream(writeOptions)
+	req.NoError(err)
+
+	unchanged, err := k8sutil.ReadKustomizationFromFile(kustomizationFile)
+	req.NoError(err)
+	req.Equal(k, unchanged)
+}
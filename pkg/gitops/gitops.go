@@ -0,0 +1,346 @@
+package gitops
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/otiai10/copy"
+	"github.com/pkg/errors"
+)
+
+const (
+	DefaultBranch      = "master"
+	DefaultAuthorName  = "kots"
+	DefaultAuthorEmail = "kots@replicated.com"
+)
+
+// Options configure the repo that rendered downstreams are committed to
+type Options struct {
+	RepoURI string
+	// Branch is the branch to commit to, DefaultBranch when empty
+	Branch string
+	// Path is the directory in the repo that the files are written to, the root of the repo when empty
+	Path string
+	// NewBranch, when set, is created from Branch and the change is committed and pushed to it instead,
+	// so that it can be reviewed before it's merged
+	NewBranch string
+
+	Auth        Auth
+	AuthorName  string
+	AuthorEmail string
+}
+
+// Auth has the credentials for the repo. SSH is used for repos with ssh uris and
+// username and password for repos with https uris.
+type Auth struct {
+	SSHPrivateKeyFile string
+	SSHKnownHostsFile string
+	HTTPSUsername     string
+	HTTPSPassword     string
+}
+
+// Change describes the version of the app that is committed
+type Change struct {
+	AppName      string
+	VersionLabel string
+	UpdateCursor string
+	ReleaseNotes string
+}
+
+// CommitMessage returns the message of the commit for the change
+func (c Change) CommitMessage() string {
+	subject := fmt.Sprintf("Update %s", c.AppName)
+	if c.VersionLabel != "" {
+		subject = fmt.Sprintf("Update %s to %s", c.AppName, c.VersionLabel)
+	}
+
+	lines := []string{subject, ""}
+	if c.VersionLabel != "" {
+		lines = append(lines, fmt.Sprintf("Version: %s", c.VersionLabel))
+	}
+	if c.UpdateCursor != "" {
+		lines = append(lines, fmt.Sprintf("Cursor: %s", c.UpdateCursor))
+	}
+	if notes := strings.TrimSpace(c.ReleaseNotes); notes != "" {
+		lines = append(lines, "", "Release notes:", notes)
+	}
+
+	return strings.TrimSpace(strings.Join(lines, "\n")) + "\n"
+}
+
+// CommitDirs replaces the content of the path in the repo with the dirs and pushes the change.
+// The keys of dirs are the destinations relative to the path, and the values are local directories.
+// The sha of the new commit is returned, or an empty string if there was nothing to commit.
+func CommitDirs(options Options, dirs map[string]string, change Change) (string, error) {
+	if options.RepoURI == "" {
+		return "", errors.New("repo uri is required")
+	}
+
+	tmpDir, err := ioutil.TempDir("", "kots-gitops")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	workDir := filepath.Join(tmpDir, "repo")
+	if err := os.Mkdir(workDir, 0700); err != nil {
+		return "", errors.Wrap(err, "failed to create repo dir")
+	}
+
+	g, err := newGitCmd(options, workDir, tmpDir)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to configure git")
+	}
+
+	if err := g.checkout(); err != nil {
+		return "", errors.Wrap(err, "failed to checkout repo")
+	}
+
+	if err := replacePath(workDir, options.Path, dirs); err != nil {
+		return "", errors.Wrap(err, "failed to write files")
+	}
+
+	if _, err := g.run("add", "--all"); err != nil {
+		return "", errors.Wrap(err, "failed to add files")
+	}
+	status, err := g.run("status", "--porcelain")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get status")
+	}
+	if strings.TrimSpace(status) == "" {
+		return "", nil
+	}
+
+	if _, err := g.run("commit", "--message", change.CommitMessage()); err != nil {
+		return "", errors.Wrap(err, "failed to commit")
+	}
+	if _, err := g.run("push", "origin", fmt.Sprintf("HEAD:refs/heads/%s", g.pushBranch())); err != nil {
+		return "", errors.Wrap(err, "failed to push")
+	}
+
+	sha, err := g.run("rev-parse", "HEAD")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get commit sha")
+	}
+	return strings.TrimSpace(sha), nil
+}
+
+// replacePath deletes everything in the path of the repo and copies the dirs into it
+func replacePath(workDir string, repoPath string, dirs map[string]string) error {
+	destDir := filepath.Join(workDir, repoPath)
+	if relPath, err := filepath.Rel(workDir, destDir); err != nil || strings.HasPrefix(relPath, "..") {
+		return errors.Errorf("path %s is outside of the repo", repoPath)
+	}
+
+	if destDir == workDir {
+		entries, err := ioutil.ReadDir(workDir)
+		if err != nil {
+			return errors.Wrap(err, "failed to read repo dir")
+		}
+		for _, entry := range entries {
+			if entry.Name() == ".git" {
+				continue
+			}
+			if err := os.RemoveAll(filepath.Join(workDir, entry.Name())); err != nil {
+				return errors.Wrapf(err, "failed to delete %s", entry.Name())
+			}
+		}
+	} else if err := os.RemoveAll(destDir); err != nil {
+		return errors.Wrapf(err, "failed to delete %s", repoPath)
+	}
+
+	for dest, src := range dirs {
+		if err := copy.Copy(src, filepath.Join(destDir, dest)); err != nil {
+			return errors.Wrapf(err, "failed to copy %s", src)
+		}
+	}
+
+	return nil
+}
+
+// askPassScript answers the username and password prompts of git from the environment, so that the https
+// credentials are not written to the remote uri or to disk
+const askPassScript = `#!/bin/sh
+case "$1" in
+Username*) printf '%s\n' "$KOTS_GITOPS_USERNAME" ;;
+*) printf '%s\n' "$KOTS_GITOPS_PASSWORD" ;;
+esac
+`
+
+type gitCmd struct {
+	options Options
+	dir     string
+	env     []string
+}
+
+// newGitCmd configures git to run in dir. Files that git needs for auth are written to tmpDir.
+func newGitCmd(options Options, dir string, tmpDir string) (*gitCmd, error) {
+	g := gitCmd{
+		options: options,
+		dir:     dir,
+		env:     append(os.Environ(), "GIT_TERMINAL_PROMPT=0"),
+	}
+
+	authorName := options.AuthorName
+	if authorName == "" {
+		authorName = DefaultAuthorName
+	}
+	authorEmail := options.AuthorEmail
+	if authorEmail == "" {
+		authorEmail = DefaultAuthorEmail
+	}
+	g.env = append(g.env,
+		fmt.Sprintf("GIT_AUTHOR_NAME=%s", authorName),
+		fmt.Sprintf("GIT_AUTHOR_EMAIL=%s", authorEmail),
+		fmt.Sprintf("GIT_COMMITTER_NAME=%s", authorName),
+		fmt.Sprintf("GIT_COMMITTER_EMAIL=%s", authorEmail),
+	)
+
+	if options.Auth.SSHPrivateKeyFile != "" {
+		// git runs the ssh command with the shell
+		sshCommand := fmt.Sprintf("ssh -i %s -o IdentitiesOnly=yes", shellQuote(options.Auth.SSHPrivateKeyFile))
+		if options.Auth.SSHKnownHostsFile != "" {
+			sshCommand = fmt.Sprintf("%s -o UserKnownHostsFile=%s", sshCommand, shellQuote(options.Auth.SSHKnownHostsFile))
+		}
+		g.env = append(g.env, fmt.Sprintf("GIT_SSH_COMMAND=%s", sshCommand))
+	}
+
+	if options.Auth.HTTPSUsername != "" {
+		u, err := url.Parse(options.RepoURI)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse repo uri")
+		}
+		if u.Scheme != "https" && u.Scheme != "http" {
+			return nil, errors.Errorf("username and password auth requires an https repo uri")
+		}
+
+		askPassFile := filepath.Join(tmpDir, "askpass.sh")
+		if err := ioutil.WriteFile(askPassFile, []byte(askPassScript), 0700); err != nil {
+			return nil, errors.Wrap(err, "failed to write askpass script")
+		}
+		g.env = append(g.env,
+			fmt.Sprintf("GIT_ASKPASS=%s", askPassFile),
+			fmt.Sprintf("KOTS_GITOPS_USERNAME=%s", options.Auth.HTTPSUsername),
+			fmt.Sprintf("KOTS_GITOPS_PASSWORD=%s", options.Auth.HTTPSPassword),
+		)
+	}
+
+	return &g, nil
+}
+
+// shellQuote quotes s as a single word for the shell
+func shellQuote(s string) string {
+	return "'" + strings.Replace(s, "'", `'\''`, -1) + "'"
+}
+
+func (g *gitCmd) branch() string {
+	if g.options.Branch == "" {
+		return DefaultBranch
+	}
+	return g.options.Branch
+}
+
+func (g *gitCmd) pushBranch() string {
+	if g.options.NewBranch != "" {
+		return g.options.NewBranch
+	}
+	return g.branch()
+}
+
+// checkout checks out the branch in the work dir. A branch that doesn't exist yet, such as in an
+// empty repo, is started with no commits.
+func (g *gitCmd) checkout() error {
+	if _, err := g.run("init"); err != nil {
+		return errors.Wrap(err, "failed to init")
+	}
+	if _, err := g.run("remote", "add", "origin", g.options.RepoURI); err != nil {
+		return errors.Wrap(err, "failed to add remote")
+	}
+
+	// a new branch that was already pushed, such as when the same version is pulled again, is checked out
+	// so that the push is a fast-forward
+	if g.options.NewBranch != "" {
+		exists, err := g.remoteBranchExists(g.options.NewBranch)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return g.checkoutRemoteBranch(g.options.NewBranch)
+		}
+	}
+
+	exists, err := g.remoteBranchExists(g.branch())
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		if _, err := g.run("checkout", "--orphan", g.branch()); err != nil {
+			return errors.Wrapf(err, "failed to create branch %s", g.branch())
+		}
+	} else if err := g.checkoutRemoteBranch(g.branch()); err != nil {
+		return err
+	}
+
+	if g.options.NewBranch != "" {
+		if _, err := g.run("checkout", "-b", g.options.NewBranch); err != nil {
+			return errors.Wrapf(err, "failed to create branch %s", g.options.NewBranch)
+		}
+	}
+
+	return nil
+}
+
+func (g *gitCmd) remoteBranchExists(branch string) (bool, error) {
+	heads, err := g.run("ls-remote", "--heads", "origin", branch)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to list remote branches")
+	}
+	return strings.TrimSpace(heads) != "", nil
+}
+
+func (g *gitCmd) checkoutRemoteBranch(branch string) error {
+	if _, err := g.run("fetch", "origin", branch); err != nil {
+		return errors.Wrapf(err, "failed to fetch branch %s", branch)
+	}
+	if _, err := g.run("checkout", "-b", branch, "FETCH_HEAD"); err != nil {
+		return errors.Wrapf(err, "failed to checkout branch %s", branch)
+	}
+	return nil
+}
+
+func (g *gitCmd) run(args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.dir
+	cmd.Env = g.env
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", errors.Wrapf(err, "git %s: %s", args[0], g.redact(strings.TrimSpace(stderr.String())))
+	}
+
+	return stdout.String(), nil
+}
+
+// redact removes the https password, and the forms of it that are escaped in uris, from git output
+func (g *gitCmd) redact(output string) string {
+	password := g.options.Auth.HTTPSPassword
+	if password == "" {
+		return output
+	}
+
+	escaped := strings.TrimPrefix(url.UserPassword("", password).String(), ":")
+	for _, p := range []string{password, escaped, url.QueryEscape(password), url.PathEscape(password)} {
+		output = strings.Replace(output, p, "***", -1)
+	}
+	return output
+}
@@ -0,0 +1,245 @@
+package helmrelease
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// DefaultHookTimeout is how long a hook job can run before the release fails
+const DefaultHookTimeout = 5 * time.Minute
+
+// Engine installs, upgrades and rolls back helm release units that were written to a base
+type Engine struct {
+	Clientset     kubernetes.Interface
+	DynamicClient dynamic.Interface
+	RESTMapper    meta.RESTMapper
+	// Namespace is used for releases that don't set a namespace
+	Namespace     string
+	RenderOptions base.RenderOptions
+	HookTimeout   time.Duration
+}
+
+// NewEngine creates an engine that discovers the resources of the cluster in the rest config
+func NewEngine(cfg *rest.Config, namespace string) (*Engine, error) {
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create clientset")
+	}
+
+	dynamicClient, err := dynamic.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create dynamic client")
+	}
+
+	discoveryClient, err := discovery.NewDiscoveryClientForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create discovery client")
+	}
+
+	engine := Engine{
+		Clientset:     clientset,
+		DynamicClient: dynamicClient,
+		RESTMapper:    restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(discoveryClient)),
+		Namespace:     namespace,
+		HookTimeout:   DefaultHookTimeout,
+	}
+	return &engine, nil
+}
+
+func (e *Engine) releaseNamespace(namespace string) string {
+	if namespace != "" {
+		return namespace
+	}
+	if e.Namespace != "" {
+		return e.Namespace
+	}
+	return "default"
+}
+
+// Install installs the release as revision 1. It fails if the release is already deployed.
+func (e *Engine) Install(helmRelease *base.HelmRelease) (*Release, error) {
+	namespace := e.releaseNamespace(helmRelease.Namespace)
+
+	history, err := e.History(helmRelease.Name, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get release history")
+	}
+	if deployedRelease(history) != nil {
+		return nil, errors.Errorf("release %s is already installed in namespace %s", helmRelease.Name, namespace)
+	}
+
+	release, err := e.renderRelease(helmRelease, namespace, nextRevision(history))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render release")
+	}
+	release.Description = "Install complete"
+
+	if err := e.deploy(release, nil, "pre-install", "post-install"); err != nil {
+		return nil, errors.Wrap(err, "failed to install release")
+	}
+
+	return release, nil
+}
+
+// Upgrade deploys the release as a new revision and removes the resources that the previous
+// revision created and this one doesn't. A release that isn't deployed yet is installed.
+func (e *Engine) Upgrade(helmRelease *base.HelmRelease) (*Release, error) {
+	namespace := e.releaseNamespace(helmRelease.Namespace)
+
+	history, err := e.History(helmRelease.Name, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get release history")
+	}
+	previous := deployedRelease(history)
+	if previous == nil {
+		return e.Install(helmRelease)
+	}
+
+	release, err := e.renderRelease(helmRelease, namespace, nextRevision(history))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render release")
+	}
+	release.Description = "Upgrade complete"
+
+	if err := e.deploy(release, previous, "pre-upgrade", "post-upgrade"); err != nil {
+		return nil, errors.Wrap(err, "failed to upgrade release")
+	}
+
+	return release, nil
+}
+
+// Rollback deploys the manifests of an earlier revision as a new revision. Revision 0 rolls
+// back to the revision before the one that is deployed.
+func (e *Engine) Rollback(name string, namespace string, revision int) (*Release, error) {
+	namespace = e.releaseNamespace(namespace)
+
+	history, err := e.History(name, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get release history")
+	}
+	previous := deployedRelease(history)
+	if previous == nil {
+		return nil, errors.Errorf("release %s is not deployed in namespace %s", name, namespace)
+	}
+
+	if revision == 0 {
+		revision = previous.Revision - 1
+	}
+
+	var target *Release
+	for _, r := range history {
+		if r.Revision == revision {
+			target = r
+		}
+	}
+	if target == nil {
+		return nil, errors.Errorf("release %s has no revision %d", name, revision)
+	}
+
+	release := *target
+	release.Revision = nextRevision(history)
+	release.Description = fmt.Sprintf("Rollback to %d", revision)
+
+	if err := e.deploy(&release, previous, "pre-rollback", "post-rollback"); err != nil {
+		return nil, errors.Wrap(err, "failed to roll back release")
+	}
+
+	return &release, nil
+}
+
+func nextRevision(history []*Release) int {
+	if len(history) == 0 {
+		return 1
+	}
+	return history[len(history)-1].Revision + 1
+}
+
+func (e *Engine) renderRelease(helmRelease *base.HelmRelease, namespace string, revision int) (*Release, error) {
+	renderRelease := *helmRelease
+	renderRelease.Namespace = namespace
+
+	rendered, err := base.RenderHelmRelease(&renderRelease, &e.RenderOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to render chart")
+	}
+
+	release := Release{
+		Name:         helmRelease.Name,
+		Namespace:    namespace,
+		Revision:     revision,
+		ChartName:    helmRelease.ChartName,
+		ChartVersion: helmRelease.ChartVersion,
+		Values:       helmRelease.Values,
+		Manifests:    []Manifest{},
+		Hooks:        []Manifest{},
+	}
+	for _, file := range rendered.Files {
+		release.Manifests = append(release.Manifests, Manifest{Path: file.Path, Content: string(file.Content)})
+	}
+	for _, file := range rendered.Hooks {
+		release.Hooks = append(release.Hooks, Manifest{Path: file.Path, Content: string(file.Content)})
+	}
+
+	return &release, nil
+}
+
+// deploy runs the pre hooks, applies the manifests of the release, removes the resources of the previous
+// revision that are no longer in the release and runs the post hooks. The release is recorded as failed
+// if any step fails.
+func (e *Engine) deploy(release *Release, previous *Release, prePhase string, postPhase string) error {
+	if err := e.deploySteps(release, previous, prePhase, postPhase); err != nil {
+		release.Status = StatusFailed
+		release.Description = err.Error()
+		if saveErr := e.saveRelease(release); saveErr != nil {
+			return errors.Wrapf(err, "failed to save failed release: %v", saveErr)
+		}
+		return err
+	}
+
+	if previous != nil {
+		previous.Status = StatusSuperseded
+		if err := e.saveRelease(previous); err != nil {
+			return errors.Wrap(err, "failed to save previous release")
+		}
+	}
+
+	release.Status = StatusDeployed
+	if err := e.saveRelease(release); err != nil {
+		return errors.Wrap(err, "failed to save release")
+	}
+
+	return nil
+}
+
+func (e *Engine) deploySteps(release *Release, previous *Release, prePhase string, postPhase string) error {
+	if err := e.runHooks(release, prePhase); err != nil {
+		return errors.Wrapf(err, "failed to run %s hooks", prePhase)
+	}
+
+	applied, err := e.applyManifests(release.Namespace, release.Manifests)
+	if err != nil {
+		return errors.Wrap(err, "failed to apply manifests")
+	}
+
+	if previous != nil {
+		if err := e.deleteRemovedResources(previous.Namespace, previous.Manifests, applied); err != nil {
+			return errors.Wrap(err, "failed to delete removed resources")
+		}
+	}
+
+	if err := e.runHooks(release, postPhase); err != nil {
+		return errors.Wrapf(err, "failed to run %s hooks", postPhase)
+	}
+
+	return nil
+}
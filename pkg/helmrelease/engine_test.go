@@ -0,0 +1,173 @@
+package helmrelease
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	dynamicfake "k8s.io/client-go/dynamic/fake"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+var testChartFiles = map[string]string{
+	"test/Chart.yaml": `apiVersion: v1
+name: test
+version: 0.1.0
+`,
+	"test/values.yaml": `message: hello
+extra: false
+`,
+	"test/templates/configmap.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}
+data:
+  message: {{ .Values.message | quote }}
+`,
+	"test/templates/extra.yaml": `{{ if .Values.extra }}
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-extra
+data:
+  revision: {{ .Release.Revision | quote }}
+{{ end }}
+`,
+	"test/templates/hook.yaml": `apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{ .Release.Name }}-hook
+  annotations:
+    "helm.sh/hook": pre-install,pre-upgrade
+data:
+  message: {{ .Values.message | quote }}
+`,
+}
+
+func testChartArchive(t *testing.T) []byte {
+	buf := bytes.NewBuffer(nil)
+	gzw := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gzw)
+	for name, content := range testChartFiles {
+		err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg})
+		require.NoError(t, err)
+		_, err = tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gzw.Close())
+	return buf.Bytes()
+}
+
+func testEngine() *Engine {
+	restMapper := meta.NewDefaultRESTMapper(nil)
+	restMapper.Add(schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, meta.RESTScopeNamespace)
+
+	return &Engine{
+		Clientset:     fake.NewSimpleClientset(),
+		DynamicClient: dynamicfake.NewSimpleDynamicClient(runtime.NewScheme()),
+		RESTMapper:    restMapper,
+		Namespace:     "test-ns",
+	}
+}
+
+func getConfigMapData(t *testing.T, e *Engine, name string) map[string]interface{} {
+	gvr := schema.GroupVersionResource{Version: "v1", Resource: "configmaps"}
+	obj, err := e.DynamicClient.Resource(gvr).Namespace("test-ns").Get(name, metav1.GetOptions{})
+	if err != nil {
+		return nil
+	}
+	data, _ := obj.Object["data"].(map[string]interface{})
+	return data
+}
+
+func releaseStatuses(t *testing.T, e *Engine) []string {
+	history, err := e.History("test", "")
+	require.NoError(t, err)
+
+	statuses := []string{}
+	for _, r := range history {
+		statuses = append(statuses, r.Status)
+	}
+	return statuses
+}
+
+func Test_EngineInstallUpgradeRollback(t *testing.T) {
+	req := require.New(t)
+
+	e := testEngine()
+	helmRelease := &base.HelmRelease{
+		Name:         "test",
+		ChartName:    "test",
+		ChartVersion: "0.1.0",
+		ChartArchive: testChartArchive(t),
+		Values: map[string]interface{}{
+			"message": "first",
+			"extra":   true,
+		},
+	}
+
+	release, err := e.Install(helmRelease)
+	req.NoError(err)
+	req.Equal(1, release.Revision)
+	req.Equal(StatusDeployed, release.Status)
+	req.Equal("test-ns", release.Namespace)
+	req.Len(release.Hooks, 1)
+	req.Equal(map[string]interface{}{"message": "first"}, getConfigMapData(t, e, "test"))
+	req.Equal(map[string]interface{}{"message": "first"}, getConfigMapData(t, e, "test-hook"))
+	req.NotNil(getConfigMapData(t, e, "test-extra"))
+
+	_, err = e.Install(helmRelease)
+	req.EqualError(err, "release test is already installed in namespace test-ns")
+
+	helmRelease.Values = map[string]interface{}{
+		"message": "second",
+		"extra":   false,
+	}
+	release, err = e.Upgrade(helmRelease)
+	req.NoError(err)
+	req.Equal(2, release.Revision)
+	req.Equal(map[string]interface{}{"message": "second"}, getConfigMapData(t, e, "test"))
+	req.Equal(map[string]interface{}{"message": "second"}, getConfigMapData(t, e, "test-hook"))
+	req.Nil(getConfigMapData(t, e, "test-extra"))
+	req.Equal([]string{StatusSuperseded, StatusDeployed}, releaseStatuses(t, e))
+
+	release, err = e.Rollback("test", "", 0)
+	req.NoError(err)
+	req.Equal(3, release.Revision)
+	req.Equal("Rollback to 1", release.Description)
+	req.Equal(map[string]interface{}{"message": "first"}, getConfigMapData(t, e, "test"))
+	req.NotNil(getConfigMapData(t, e, "test-extra"))
+	req.Equal([]string{StatusSuperseded, StatusSuperseded, StatusDeployed}, releaseStatuses(t, e))
+
+	_, err = e.Rollback("test", "", 7)
+	req.EqualError(err, "release test has no revision 7")
+}
+
+func Test_EngineUpgradeFailure(t *testing.T) {
+	req := require.New(t)
+
+	e := testEngine()
+	helmRelease := &base.HelmRelease{
+		Name:         "test",
+		ChartArchive: testChartArchive(t),
+		Values:       map[string]interface{}{"message": "first"},
+	}
+
+	_, err := e.Install(helmRelease)
+	req.NoError(err)
+
+	// a mapper without ConfigMaps can't apply the release
+	e.RESTMapper = meta.NewDefaultRESTMapper(nil)
+	_, err = e.Upgrade(helmRelease)
+	req.Error(err)
+
+	req.Equal([]string{StatusDeployed, StatusFailed}, releaseStatuses(t, e))
+}
@@ -0,0 +1,139 @@
+package helmrelease
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	StatusDeployed   = "deployed"
+	StatusSuperseded = "superseded"
+	StatusFailed     = "failed"
+
+	releaseSecretType     = "kots.io/helm-release"
+	releaseLabel          = "kots.io/helm-release"
+	releaseRevisionLabel  = "kots.io/helm-release-revision"
+	releaseStatusLabel    = "kots.io/helm-release-status"
+	releaseSecretDataKey  = "release"
+	releaseSecretNameBase = "kots-helm-release"
+)
+
+// Release is one revision of a helm release. Each revision is stored in a secret in the
+// namespace of the release, so that the resources it applied can be removed or restored later.
+type Release struct {
+	Name         string                 `json:"name"`
+	Namespace    string                 `json:"namespace"`
+	Revision     int                    `json:"revision"`
+	Status       string                 `json:"status"`
+	Description  string                 `json:"description"`
+	ChartName    string                 `json:"chartName"`
+	ChartVersion string                 `json:"chartVersion"`
+	Values       map[string]interface{} `json:"values"`
+	Manifests    []Manifest             `json:"manifests"`
+	Hooks        []Manifest             `json:"hooks"`
+	Updated      time.Time              `json:"updated"`
+}
+
+// Manifest is a single rendered file of the chart
+type Manifest struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
+}
+
+func releaseSecretName(name string, revision int) string {
+	return fmt.Sprintf("%s.%s.v%d", releaseSecretNameBase, name, revision)
+}
+
+// History returns all revisions of the release, oldest first
+func (e *Engine) History(name string, namespace string) ([]*Release, error) {
+	namespace = e.releaseNamespace(namespace)
+
+	secrets, err := e.Clientset.CoreV1().Secrets(namespace).List(metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", releaseLabel, name),
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list release secrets")
+	}
+
+	releases := []*Release{}
+	for _, secret := range secrets.Items {
+		release := Release{}
+		if err := json.Unmarshal(secret.Data[releaseSecretDataKey], &release); err != nil {
+			return nil, errors.Wrapf(err, "failed to unmarshal release in secret %s", secret.Name)
+		}
+		releases = append(releases, &release)
+	}
+
+	sort.Slice(releases, func(i, j int) bool {
+		return releases[i].Revision < releases[j].Revision
+	})
+
+	return releases, nil
+}
+
+// deployedRelease returns the revision that is currently deployed, or nil if there is none
+func deployedRelease(history []*Release) *Release {
+	for i := len(history) - 1; i >= 0; i-- {
+		if history[i].Status == StatusDeployed {
+			return history[i]
+		}
+	}
+	return nil
+}
+
+func (e *Engine) saveRelease(release *Release) error {
+	release.Updated = time.Now()
+
+	b, err := json.Marshal(release)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal release")
+	}
+
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      releaseSecretName(release.Name, release.Revision),
+			Namespace: release.Namespace,
+			Labels: map[string]string{
+				releaseLabel:         release.Name,
+				releaseRevisionLabel: strconv.Itoa(release.Revision),
+				releaseStatusLabel:   release.Status,
+			},
+		},
+		Type: releaseSecretType,
+		Data: map[string][]byte{
+			releaseSecretDataKey: b,
+		},
+	}
+
+	secrets := e.Clientset.CoreV1().Secrets(release.Namespace)
+	existing, err := secrets.Get(secret.Name, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get release secret")
+		}
+		if _, err := secrets.Create(secret); err != nil {
+			return errors.Wrap(err, "failed to create release secret")
+		}
+		return nil
+	}
+
+	existing.Labels = secret.Labels
+	existing.Data = secret.Data
+	if _, err := secrets.Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update release secret")
+	}
+
+	return nil
+}
@@ -0,0 +1,247 @@
+package helmrelease
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/base"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/dynamic"
+)
+
+const hookDeletePolicyAnnotation = "kots.io/hook-delete-policy"
+
+// decodeManifest returns the object in the manifest, or nil if the manifest is empty
+func decodeManifest(manifest Manifest) (*unstructured.Unstructured, error) {
+	b, err := yaml.YAMLToJSON([]byte(manifest.Content))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to convert %s to json", manifest.Path)
+	}
+	if len(b) == 0 || string(b) == "null" || string(b) == "{}" {
+		return nil, nil
+	}
+
+	obj := &unstructured.Unstructured{}
+	if err := obj.UnmarshalJSON(b); err != nil {
+		return nil, errors.Wrapf(err, "failed to decode %s", manifest.Path)
+	}
+
+	return obj, nil
+}
+
+// resourceClient returns the client for the kind of the object. Namespaced objects without a
+// namespace are put in the namespace of the release.
+func (e *Engine) resourceClient(obj *unstructured.Unstructured, namespace string) (dynamic.ResourceInterface, error) {
+	gvk := obj.GroupVersionKind()
+	mapping, err := e.RESTMapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get resource for %s", gvk)
+	}
+
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return e.DynamicClient.Resource(mapping.Resource), nil
+	}
+
+	if obj.GetNamespace() == "" {
+		obj.SetNamespace(namespace)
+	}
+	return e.DynamicClient.Resource(mapping.Resource).Namespace(obj.GetNamespace()), nil
+}
+
+func resourceKey(obj *unstructured.Unstructured) string {
+	return fmt.Sprintf("%s/%s/%s", obj.GroupVersionKind().GroupKind(), obj.GetNamespace(), obj.GetName())
+}
+
+// applyManifests creates or updates the object in each manifest and returns the keys of the applied objects
+func (e *Engine) applyManifests(namespace string, manifests []Manifest) (map[string]bool, error) {
+	applied := map[string]bool{}
+
+	for _, manifest := range manifests {
+		obj, err := decodeManifest(manifest)
+		if err != nil {
+			return nil, err
+		}
+		if obj == nil {
+			continue
+		}
+
+		client, err := e.resourceClient(obj, namespace)
+		if err != nil {
+			return nil, err
+		}
+
+		existing, err := client.Get(obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			if !kuberneteserrors.IsNotFound(err) {
+				return nil, errors.Wrapf(err, "failed to get %s", resourceKey(obj))
+			}
+			if _, err := client.Create(obj, metav1.CreateOptions{}); err != nil {
+				return nil, errors.Wrapf(err, "failed to create %s", resourceKey(obj))
+			}
+		} else {
+			obj.SetResourceVersion(existing.GetResourceVersion())
+			if _, err := client.Update(obj, metav1.UpdateOptions{}); err != nil {
+				return nil, errors.Wrapf(err, "failed to update %s", resourceKey(obj))
+			}
+		}
+
+		applied[resourceKey(obj)] = true
+	}
+
+	return applied, nil
+}
+
+// deleteRemovedResources deletes the objects in the manifests that were not applied
+func (e *Engine) deleteRemovedResources(namespace string, manifests []Manifest, applied map[string]bool) error {
+	for _, manifest := range manifests {
+		obj, err := decodeManifest(manifest)
+		if err != nil {
+			return err
+		}
+		if obj == nil {
+			continue
+		}
+
+		client, err := e.resourceClient(obj, namespace)
+		if err != nil {
+			return err
+		}
+		if applied[resourceKey(obj)] {
+			continue
+		}
+
+		if err := client.Delete(obj.GetName(), &metav1.DeleteOptions{}); err != nil && !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete %s", resourceKey(obj))
+		}
+	}
+
+	return nil
+}
+
+type hookManifest struct {
+	manifest Manifest
+	weight   int
+}
+
+// runHooks creates the hooks of the release in the phase, ordered by weight and then path. A previous
+// hook object with the same name is deleted first. Jobs must complete before the next hook is created.
+func (e *Engine) runHooks(release *Release, phase string) error {
+	hooks := []hookManifest{}
+	for _, manifest := range release.Hooks {
+		phases, weight, err := base.BaseFile{Path: manifest.Path, Content: []byte(manifest.Content)}.HookPhases()
+		if err != nil {
+			return errors.Wrap(err, "failed to get hook phases")
+		}
+		for _, p := range phases {
+			if p == phase {
+				hooks = append(hooks, hookManifest{manifest: manifest, weight: weight})
+			}
+		}
+	}
+
+	sort.SliceStable(hooks, func(i, j int) bool {
+		if hooks[i].weight != hooks[j].weight {
+			return hooks[i].weight < hooks[j].weight
+		}
+		return hooks[i].manifest.Path < hooks[j].manifest.Path
+	})
+
+	for _, hook := range hooks {
+		if err := e.runHook(release.Namespace, hook.manifest); err != nil {
+			return errors.Wrapf(err, "failed to run hook %s", hook.manifest.Path)
+		}
+	}
+
+	return nil
+}
+
+func (e *Engine) runHook(namespace string, manifest Manifest) error {
+	obj, err := decodeManifest(manifest)
+	if err != nil {
+		return err
+	}
+	if obj == nil {
+		return nil
+	}
+
+	client, err := e.resourceClient(obj, namespace)
+	if err != nil {
+		return err
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	deleteOptions := &metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	if err := client.Delete(obj.GetName(), deleteOptions); err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete previous %s", resourceKey(obj))
+	}
+	if _, err := client.Create(obj, metav1.CreateOptions{}); err != nil {
+		return errors.Wrapf(err, "failed to create %s", resourceKey(obj))
+	}
+
+	deletePolicies := strings.Split(obj.GetAnnotations()[hookDeletePolicyAnnotation], ",")
+
+	hookErr := e.waitForHook(client, obj)
+	if hasDeletePolicy(deletePolicies, "hook-succeeded") && hookErr == nil || hasDeletePolicy(deletePolicies, "hook-failed") && hookErr != nil {
+		if err := client.Delete(obj.GetName(), deleteOptions); err != nil && !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrapf(err, "failed to delete %s", resourceKey(obj))
+		}
+	}
+
+	return hookErr
+}
+
+func hasDeletePolicy(policies []string, policy string) bool {
+	for _, p := range policies {
+		if strings.TrimSpace(p) == policy {
+			return true
+		}
+	}
+	return false
+}
+
+// waitForHook waits for a hook job to complete. Other kinds are ready once they are created.
+func (e *Engine) waitForHook(client dynamic.ResourceInterface, obj *unstructured.Unstructured) error {
+	if obj.GetKind() != "Job" {
+		return nil
+	}
+
+	timeout := e.HookTimeout
+	if timeout == 0 {
+		timeout = DefaultHookTimeout
+	}
+
+	start := time.Now()
+	for {
+		job, err := client.Get(obj.GetName(), metav1.GetOptions{})
+		if err != nil {
+			return errors.Wrapf(err, "failed to get %s", resourceKey(obj))
+		}
+
+		conditions, _, _ := unstructured.NestedSlice(job.Object, "status", "conditions")
+		for _, c := range conditions {
+			condition, ok := c.(map[string]interface{})
+			if !ok || condition["status"] != "True" {
+				continue
+			}
+			switch condition["type"] {
+			case "Complete":
+				return nil
+			case "Failed":
+				return errors.Errorf("job %s failed: %v", obj.GetName(), condition["message"])
+			}
+		}
+
+		if time.Since(start) > timeout {
+			return errors.Errorf("timed out waiting for job %s", obj.GetName())
+		}
+		time.Sleep(time.Second)
+	}
+}
@@ -0,0 +1,185 @@
+package history
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/otiai10/copy"
+	"github.com/pkg/errors"
+)
+
+const (
+	// DirName is the dir in the app root that the versions are kept in
+	DirName = "history"
+	// DefaultKeepVersions is how many versions kots pull keeps when --keep-versions is not set
+	DefaultKeepVersions = 10
+
+	versionFileName = "version.json"
+)
+
+// dirsInVersion are the dirs of the app root that are saved with each version. The upstream dir
+// includes the userdata (config values, installation and license) of the version.
+var dirsInVersion = []string{"upstream", "base"}
+
+// Version is a version of the app that was pulled
+type Version struct {
+	Sequence     int64     `json:"sequence"`
+	UpdateCursor string    `json:"updateCursor,omitempty"`
+	VersionLabel string    `json:"versionLabel,omitempty"`
+	ReleaseNotes string    `json:"releaseNotes,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// Record saves the upstream and base in appDir as a new version, and removes the oldest versions so
+// that no more than keep versions are left. When the latest version has the same update cursor, it
+// is replaced instead, so that pulling the same release again doesn't grow the history. Nothing is
+// recorded when keep is 0, and nil is returned.
+func Record(appDir string, version Version, keep int) (*Version, error) {
+	if keep <= 0 {
+		return nil, nil
+	}
+
+	versions, err := List(appDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list versions")
+	}
+
+	version.Sequence = 0
+	if len(versions) > 0 {
+		latest := versions[len(versions)-1]
+		version.Sequence = latest.Sequence + 1
+		if version.UpdateCursor != "" && version.UpdateCursor == latest.UpdateCursor {
+			version.Sequence = latest.Sequence
+			versions = versions[:len(versions)-1]
+		}
+	}
+	if version.CreatedAt.IsZero() {
+		version.CreatedAt = time.Now().UTC()
+	}
+
+	versionDir := VersionDir(appDir, version.Sequence)
+	if err := os.RemoveAll(versionDir); err != nil {
+		return nil, errors.Wrap(err, "failed to remove previous version dir")
+	}
+	if err := os.MkdirAll(versionDir, 0755); err != nil {
+		return nil, errors.Wrap(err, "failed to create version dir")
+	}
+
+	for _, dir := range dirsInVersion {
+		src := filepath.Join(appDir, dir)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copy.Copy(src, filepath.Join(versionDir, dir)); err != nil {
+			return nil, errors.Wrapf(err, "failed to copy %s", dir)
+		}
+	}
+
+	b, err := json.MarshalIndent(version, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal version")
+	}
+	if err := ioutil.WriteFile(filepath.Join(versionDir, versionFileName), b, 0644); err != nil {
+		return nil, errors.Wrap(err, "failed to write version")
+	}
+
+	// versions has the previous versions without the one that was replaced
+	for len(versions)+1 > keep {
+		if err := os.RemoveAll(VersionDir(appDir, versions[0].Sequence)); err != nil {
+			return nil, errors.Wrapf(err, "failed to remove version %d", versions[0].Sequence)
+		}
+		versions = versions[1:]
+	}
+
+	return &version, nil
+}
+
+// List returns the versions that are kept in appDir, ordered by sequence
+func List(appDir string) ([]Version, error) {
+	entries, err := ioutil.ReadDir(filepath.Join(appDir, DirName))
+	if os.IsNotExist(err) {
+		return []Version{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read history dir")
+	}
+
+	versions := []Version{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := strconv.ParseInt(entry.Name(), 10, 64); err != nil {
+			continue
+		}
+
+		version, err := readVersion(filepath.Join(appDir, DirName, entry.Name()))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read version %s", entry.Name())
+		}
+		versions = append(versions, *version)
+	}
+
+	sort.Slice(versions, func(i, j int) bool {
+		return versions[i].Sequence < versions[j].Sequence
+	})
+	return versions, nil
+}
+
+// Get returns the version with the sequence, or an error if it's not kept in appDir
+func Get(appDir string, sequence int64) (*Version, error) {
+	versionDir := VersionDir(appDir, sequence)
+	if _, err := os.Stat(versionDir); os.IsNotExist(err) {
+		return nil, errors.Errorf("version %d not found", sequence)
+	}
+
+	return readVersion(versionDir)
+}
+
+// Restore replaces the upstream and base in appDir with the ones of the version
+func Restore(appDir string, sequence int64) error {
+	if _, err := Get(appDir, sequence); err != nil {
+		return err
+	}
+
+	versionDir := VersionDir(appDir, sequence)
+	for _, dir := range dirsInVersion {
+		dst := filepath.Join(appDir, dir)
+		if err := os.RemoveAll(dst); err != nil {
+			return errors.Wrapf(err, "failed to remove %s", dir)
+		}
+
+		src := filepath.Join(versionDir, dir)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copy.Copy(src, dst); err != nil {
+			return errors.Wrapf(err, "failed to copy %s", dir)
+		}
+	}
+
+	return nil
+}
+
+// VersionDir is the dir that the version with the sequence is kept in
+func VersionDir(appDir string, sequence int64) string {
+	return filepath.Join(appDir, DirName, strconv.FormatInt(sequence, 10))
+}
+
+func readVersion(versionDir string) (*Version, error) {
+	b, err := ioutil.ReadFile(filepath.Join(versionDir, versionFileName))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read version file")
+	}
+
+	version := Version{}
+	if err := json.Unmarshal(b, &version); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal version")
+	}
+	return &version, nil
+}
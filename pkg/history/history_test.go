@@ -0,0 +1,130 @@
+package history
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// writeRelease writes the upstream and base of a release with the cursor to the app dir
+func writeRelease(t *testing.T, appDir string, cursor string, allowRollback bool) {
+	req := require.New(t)
+
+	for _, dir := range []string{"upstream", "base"} {
+		req.NoError(os.RemoveAll(filepath.Join(appDir, dir)))
+	}
+	req.NoError(os.MkdirAll(filepath.Join(appDir, "upstream", "userdata"), 0755))
+	req.NoError(os.MkdirAll(filepath.Join(appDir, "base"), 0755))
+
+	application := fmt.Sprintf(`apiVersion: kots.io/v1beta1
+kind: Application
+metadata:
+  name: my-app
+spec:
+  title: My App
+  allowRollback: %t
+`, allowRollback)
+	installation := fmt.Sprintf(`apiVersion: kots.io/v1beta1
+kind: Installation
+metadata:
+  name: my-app
+spec:
+  updateCursor: "%s"
+`, cursor)
+	deployment := fmt.Sprintf(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web-%s
+`, cursor)
+
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "upstream", "application.yaml"), []byte(application), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "upstream", "userdata", "installation.yaml"), []byte(installation), 0644))
+	req.NoError(ioutil.WriteFile(filepath.Join(appDir, "base", "deployment.yaml"), []byte(deployment), 0644))
+}
+
+func Test_Record(t *testing.T) {
+	req := require.New(t)
+
+	appDir, err := ioutil.TempDir("", "kots-history")
+	req.NoError(err)
+	defer os.RemoveAll(appDir)
+
+	for _, cursor := range []string{"1", "2", "3", "4"} {
+		writeRelease(t, appDir, cursor, true)
+		_, err := Record(appDir, Version{UpdateCursor: cursor}, 3)
+		req.NoError(err)
+	}
+
+	// pulling the same release again replaces the latest version
+	version, err := Record(appDir, Version{UpdateCursor: "4", VersionLabel: "1.4.0"}, 3)
+	req.NoError(err)
+	req.Equal(int64(3), version.Sequence)
+
+	versions, err := List(appDir)
+	req.NoError(err)
+	req.Len(versions, 3)
+	for i, expected := range []string{"2", "3", "4"} {
+		req.Equal(int64(i+1), versions[i].Sequence)
+		req.Equal(expected, versions[i].UpdateCursor)
+	}
+	req.Equal("1.4.0", versions[2].VersionLabel)
+
+	_, err = Get(appDir, 0)
+	req.Error(err)
+
+	content, err := ioutil.ReadFile(filepath.Join(VersionDir(appDir, 1), "base", "deployment.yaml"))
+	req.NoError(err)
+	req.Contains(string(content), "web-2")
+
+	// keeping no versions disables the history
+	writeRelease(t, appDir, "5", true)
+	version, err = Record(appDir, Version{UpdateCursor: "5"}, 0)
+	req.NoError(err)
+	req.Nil(version)
+	versions, err = List(appDir)
+	req.NoError(err)
+	req.Len(versions, 3)
+}
+
+func Test_Rollback(t *testing.T) {
+	req := require.New(t)
+
+	appDir, err := ioutil.TempDir("", "kots-history")
+	req.NoError(err)
+	defer os.RemoveAll(appDir)
+
+	writeRelease(t, appDir, "1", true)
+	_, err = Record(appDir, Version{UpdateCursor: "1"}, DefaultKeepVersions)
+	req.NoError(err)
+
+	writeRelease(t, appDir, "2", false)
+	_, err = Record(appDir, Version{UpdateCursor: "2"}, DefaultKeepVersions)
+	req.NoError(err)
+
+	// the current release doesn't allow rollback
+	_, err = Rollback(appDir, 0)
+	req.Error(err)
+
+	writeRelease(t, appDir, "3", true)
+	_, err = Record(appDir, Version{UpdateCursor: "3"}, DefaultKeepVersions)
+	req.NoError(err)
+
+	_, err = Rollback(appDir, 2)
+	req.Error(err)
+
+	version, err := Rollback(appDir, 0)
+	req.NoError(err)
+	req.Equal("1", version.UpdateCursor)
+
+	kotsKinds, err := LoadKotsKinds(filepath.Join(appDir, "upstream"))
+	req.NoError(err)
+	req.Equal("1", kotsKinds.Installation.Spec.UpdateCursor)
+
+	content, err := ioutil.ReadFile(filepath.Join(appDir, "base", "deployment.yaml"))
+	req.NoError(err)
+	req.Contains(string(content), "web-1")
+}
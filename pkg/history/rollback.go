@@ -0,0 +1,96 @@
+package history
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func init() {
+	kotsscheme.AddToScheme(scheme.Scheme)
+}
+
+// KotsKinds are the kots objects in the upstream of a pulled app that are needed to render it
+type KotsKinds struct {
+	Application  *kotsv1beta1.Application
+	ConfigValues *kotsv1beta1.ConfigValues
+	Installation *kotsv1beta1.Installation
+	License      *kotsv1beta1.License
+}
+
+// LoadKotsKinds finds the application, config values, installation and license in the upstream dir
+func LoadKotsKinds(upstreamDir string) (*KotsKinds, error) {
+	kotsKinds := KotsKinds{}
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	err := filepath.Walk(upstreamDir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || !k8sdoc.IsYAMLFile(path) {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", path)
+		}
+
+		obj, gvk, err := decode(content, nil, nil)
+		if err != nil {
+			return nil
+		}
+		if gvk.Group != "kots.io" || gvk.Version != "v1beta1" {
+			return nil
+		}
+
+		switch gvk.Kind {
+		case "Application":
+			kotsKinds.Application = obj.(*kotsv1beta1.Application)
+		case "ConfigValues":
+			kotsKinds.ConfigValues = obj.(*kotsv1beta1.ConfigValues)
+		case "Installation":
+			kotsKinds.Installation = obj.(*kotsv1beta1.Installation)
+		case "License":
+			kotsKinds.License = obj.(*kotsv1beta1.License)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk upstream dir")
+	}
+
+	return &kotsKinds, nil
+}
+
+// Rollback restores the upstream and base of a previous version in appDir. It's refused when the
+// application in the current release doesn't allow rollback, or when the version is the current one.
+func Rollback(appDir string, sequence int64) (*Version, error) {
+	current, err := LoadKotsKinds(filepath.Join(appDir, "upstream"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load current release")
+	}
+	if current.Application == nil || !current.Application.Spec.AllowRollback {
+		return nil, errors.New("the current release does not allow rollback")
+	}
+
+	version, err := Get(appDir, sequence)
+	if err != nil {
+		return nil, err
+	}
+	if current.Installation != nil && version.UpdateCursor != "" && version.UpdateCursor == current.Installation.Spec.UpdateCursor {
+		return nil, errors.Errorf("version %d is the current version", sequence)
+	}
+
+	if err := Restore(appDir, sequence); err != nil {
+		return nil, errors.Wrapf(err, "failed to restore version %d", sequence)
+	}
+
+	return version, nil
+}
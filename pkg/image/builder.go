@@ -1,7 +1,6 @@
 package image
 
 import (
-	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -23,6 +22,7 @@ import (
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"gopkg.in/yaml.v2"
 	kustomizeimage "sigs.k8s.io/kustomize/v3/pkg/image"
@@ -44,7 +44,7 @@ type RegistryAuth struct {
 	Password string
 }
 
-func CopyImages(srcRegistry, destRegistry registry.RegistryOptions, appSlug string, log *logger.Logger, reportWriter io.Writer, upstreamDir string, dryRun, isAirgap bool) ([]kustomizeimage.Image, error) {
+func CopyImages(srcRegistry, destRegistry registry.RegistryOptions, appSlug string, log *logger.Logger, reportWriter io.Writer, upstreamDir string, dryRun, isAirgap, strict bool) ([]kustomizeimage.Image, error) {
 	savedImages := make(map[string]bool)
 	newImages := []kustomizeimage.Image{}
 
@@ -54,7 +54,7 @@ func CopyImages(srcRegistry, destRegistry registry.RegistryOptions, appSlug stri
 				return err
 			}
 
-			if info.IsDir() {
+			if info.IsDir() || !k8sdoc.IsYAMLFile(path) {
 				return nil
 			}
 
@@ -63,7 +63,7 @@ func CopyImages(srcRegistry, destRegistry registry.RegistryOptions, appSlug stri
 				return err
 			}
 
-			newImagesSubset, err := copyImagesBetweenRegistries(srcRegistry, destRegistry, appSlug, log, reportWriter, contents, dryRun, isAirgap, savedImages)
+			newImagesSubset, err := copyImagesBetweenRegistries(srcRegistry, destRegistry, appSlug, log, reportWriter, path, contents, dryRun, isAirgap, strict, savedImages)
 			if err != nil {
 				return errors.Wrapf(err, "failed to copy images mentioned in %s", path)
 			}
@@ -79,7 +79,7 @@ func CopyImages(srcRegistry, destRegistry registry.RegistryOptions, appSlug stri
 	return newImages, nil
 }
 
-func GetPrivateImages(upstreamDir string) ([]string, []*k8sdoc.Doc, error) {
+func GetPrivateImages(upstreamDir string, strict bool, log *logger.Logger) ([]string, []*k8sdoc.Doc, error) {
 	checkedImages := make(map[string]bool)
 	uniqueImages := make(map[string]bool)
 
@@ -91,7 +91,7 @@ func GetPrivateImages(upstreamDir string) ([]string, []*k8sdoc.Doc, error) {
 				return err
 			}
 
-			if info.IsDir() {
+			if info.IsDir() || !k8sdoc.IsYAMLFile(path) {
 				return nil
 			}
 
@@ -100,7 +100,7 @@ func GetPrivateImages(upstreamDir string) ([]string, []*k8sdoc.Doc, error) {
 				return err
 			}
 
-			return listImagesInFile(contents, func(images []string, doc *k8sdoc.Doc) error {
+			return listImagesInFile(path, contents, strict, log, func(images []string, doc *k8sdoc.Doc) error {
 				numPrivateImages := 0
 				for _, image := range images {
 					isPrivate := false
@@ -126,6 +126,9 @@ func GetPrivateImages(upstreamDir string) ([]string, []*k8sdoc.Doc, error) {
 					return nil
 				}
 
+				if err := setKustomizationNamespace(upstreamDir, path, doc); err != nil {
+					return errors.Wrap(err, "failed to set namespace")
+				}
 				objects = append(objects, doc)
 				return nil
 			})
@@ -143,7 +146,7 @@ func GetPrivateImages(upstreamDir string) ([]string, []*k8sdoc.Doc, error) {
 	return result, objects, nil
 }
 
-func GetObjectsWithImages(upstreamDir string) ([]*k8sdoc.Doc, error) {
+func GetObjectsWithImages(upstreamDir string, strict bool, log *logger.Logger) ([]*k8sdoc.Doc, error) {
 	objects := make([]*k8sdoc.Doc, 0)
 
 	err := filepath.Walk(upstreamDir,
@@ -152,7 +155,7 @@ func GetObjectsWithImages(upstreamDir string) ([]*k8sdoc.Doc, error) {
 				return err
 			}
 
-			if info.IsDir() {
+			if info.IsDir() || !k8sdoc.IsYAMLFile(path) {
 				return nil
 			}
 
@@ -161,10 +164,15 @@ func GetObjectsWithImages(upstreamDir string) ([]*k8sdoc.Doc, error) {
 				return err
 			}
 
-			return listImagesInFile(contents, func(images []string, doc *k8sdoc.Doc) error {
-				if len(images) > 0 {
-					objects = append(objects, doc)
+			return listImagesInFile(path, contents, strict, log, func(images []string, doc *k8sdoc.Doc) error {
+				if len(images) == 0 {
+					return nil
+				}
+
+				if err := setKustomizationNamespace(upstreamDir, path, doc); err != nil {
+					return errors.Wrap(err, "failed to set namespace")
 				}
+				objects = append(objects, doc)
 				return nil
 			})
 		})
@@ -176,9 +184,22 @@ func GetObjectsWithImages(upstreamDir string) ([]*k8sdoc.Doc, error) {
 	return objects, nil
 }
 
-func copyImagesBetweenRegistries(srcRegistry, destRegistry registry.RegistryOptions, appSlug string, log *logger.Logger, reportWriter io.Writer, fileData []byte, dryRun, isAirgap bool, savedImages map[string]bool) ([]kustomizeimage.Image, error) {
+// setKustomizationNamespace sets the namespace that the kustomizations in rootDir deploy the object in the file to,
+// so that patches for the object match it after the namespace is set by kustomize
+func setKustomizationNamespace(rootDir string, filename string, doc *k8sdoc.Doc) error {
+	namespace, err := k8sutil.KustomizationNamespace(rootDir, filepath.Dir(filename))
+	if err != nil {
+		return errors.Wrapf(err, "failed to get kustomization namespace for %s", filename)
+	}
+	if namespace != "" {
+		doc.Metadata.Namespace = namespace
+	}
+	return nil
+}
+
+func copyImagesBetweenRegistries(srcRegistry, destRegistry registry.RegistryOptions, appSlug string, log *logger.Logger, reportWriter io.Writer, filename string, fileData []byte, dryRun, isAirgap, strict bool, savedImages map[string]bool) ([]kustomizeimage.Image, error) {
 	newImages := []kustomizeimage.Image{}
-	err := listImagesInFile(fileData, func(images []string, doc *k8sdoc.Doc) error {
+	err := listImagesInFile(filename, fileData, strict, log, func(images []string, doc *k8sdoc.Doc) error {
 		for _, image := range images {
 			if _, saved := savedImages[image]; saved {
 				continue
@@ -207,11 +228,44 @@ func copyImagesBetweenRegistries(srcRegistry, destRegistry registry.RegistryOpti
 
 type processImagesFunc func([]string, *k8sdoc.Doc) error
 
-func listImagesInFile(contents []byte, handler processImagesFunc) error {
-	yamlDocs := bytes.Split(contents, []byte("\n---\n"))
+// documentParseError returns a k8sdoc.ParseError for a document that can't be parsed when strict is set.
+// Otherwise it logs a warning and returns nil, so the document is skipped like it was before parse errors
+// were reported.
+func documentParseError(filename string, index int, err error, strict bool, log *logger.Logger) error {
+	parseErr := &k8sdoc.ParseError{File: filename, Index: index, Err: err}
+	if strict {
+		return parseErr
+	}
+	log.ChildActionWithoutSpinner("Warning: skipping images in a document that can't be parsed: %v", parseErr)
+	return nil
+}
+
+// listImagesInFile calls the handler with the images of each object in the file. Documents that are not
+// objects are skipped. Documents that can't be parsed return a k8sdoc.ParseError when strict is set, and
+// are skipped with a warning otherwise.
+func listImagesInFile(filename string, contents []byte, strict bool, log *logger.Logger, handler processImagesFunc) error {
+	yamlDocs, err := k8sdoc.SplitYAML(contents)
+	if err != nil {
+		return errors.Wrapf(err, "failed to split %s", filename)
+	}
+
 	for _, yamlDoc := range yamlDocs {
+		var obj interface{}
+		if err := yaml.Unmarshal(yamlDoc.Content, &obj); err != nil {
+			if err := documentParseError(filename, yamlDoc.Index, err, strict, log); err != nil {
+				return err
+			}
+			continue
+		}
+		if _, ok := obj.(map[interface{}]interface{}); !ok {
+			continue
+		}
+
 		parsed := &k8sdoc.Doc{}
-		if err := yaml.Unmarshal(yamlDoc, parsed); err != nil {
+		if err := yaml.Unmarshal(yamlDoc.Content, parsed); err != nil {
+			if err := documentParseError(filename, yamlDoc.Index, err, strict, log); err != nil {
+				return err
+			}
 			continue
 		}
 
@@ -3,6 +3,7 @@ package image
 import (
 	"testing"
 
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
 	"github.com/stretchr/testify/require"
 )
 
@@ -65,3 +66,69 @@ func TestReleaseStartNum(t *testing.T) {
 		"docker-archive/docker.io/myorg/ubuntu/sha256/45b23dee08af5e43a7fea6c4cf9c25ccf269ee113168c19722f87876677c5cb2",
 		ref.pathInBundle("docker-archive"))
 }
+
+func Test_listImagesInFile(t *testing.T) {
+	tests := []struct {
+		name          string
+		content       string
+		strict        bool
+		expected      [][]string
+		expectedError string
+	}{
+		{
+			name: "documents with separators and comments",
+			content: `--- # first
+apiVersion: apps/v1
+kind: Deployment
+spec:
+  template:
+    spec:
+      containers:
+        - image: nginx:1
+---
+- not
+- an object
+---
+apiVersion: batch/v1
+kind: Job
+spec:
+  template:
+    spec:
+      initContainers:
+        - image: busybox
+`,
+			expected: [][]string{{"nginx:1"}, {"busybox"}},
+		},
+		{
+			name:     "parse error is skipped",
+			content:  "a: [b\n---\napiVersion: v1\nkind: Pod\nspec:\n  template:\n    spec:\n      containers:\n        - image: redis\n",
+			expected: [][]string{{"redis"}},
+		},
+		{
+			name:          "parse error when strict",
+			content:       "a: b\n---\na: [b\n",
+			strict:        true,
+			expectedError: "failed to parse document at index 1 in test.yaml: yaml: line 1: did not find expected ',' or ']'",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			actual := [][]string{}
+			err := listImagesInFile("test.yaml", []byte(test.content), test.strict, nil, func(images []string, doc *k8sdoc.Doc) error {
+				if len(images) > 0 {
+					actual = append(actual, images)
+				}
+				return nil
+			})
+			if test.expectedError != "" {
+				req.EqualError(err, test.expectedError)
+				return
+			}
+			req.NoError(err)
+			req.Equal(test.expected, actual)
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package k8sdoc
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// YAMLDocument is one document of a multi-document YAML stream
+type YAMLDocument struct {
+	// Index is the zero based position of the document among the non-empty documents in the stream
+	Index   int
+	Content []byte
+}
+
+// ParseError is returned when a document of a file can't be parsed
+type ParseError struct {
+	File  string
+	Index int
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("failed to parse document at index %d in %s: %v", e.Index, e.File, e.Err)
+}
+
+func (e *ParseError) Cause() error {
+	return e.Err
+}
+
+// YAMLDocumentReader reads the documents of a YAML stream one at a time. Documents are separated by
+// "---" lines, which can be followed by a comment or the start of the document, and are ended by "..."
+// lines. Line endings can be LF or CRLF. Documents that only have comments and whitespace are skipped.
+type YAMLDocumentReader struct {
+	reader *bufio.Reader
+	index  int
+	// next has the start of the document after a separator that was followed by content
+	next []byte
+	done bool
+}
+
+func NewYAMLDocumentReader(r io.Reader) *YAMLDocumentReader {
+	return &YAMLDocumentReader{
+		reader: bufio.NewReader(r),
+	}
+}
+
+// Read returns the next non-empty document. io.EOF is returned after the last document.
+func (d *YAMLDocumentReader) Read() (*YAMLDocument, error) {
+	for !d.done || d.next != nil {
+		content, err := d.readDocument()
+		if err != nil {
+			return nil, err
+		}
+		if isEmptyYAMLDocument(content) {
+			continue
+		}
+
+		doc := &YAMLDocument{
+			Index:   d.index,
+			Content: content,
+		}
+		d.index++
+		return doc, nil
+	}
+
+	return nil, io.EOF
+}
+
+func (d *YAMLDocumentReader) readDocument() ([]byte, error) {
+	buf := bytes.NewBuffer(d.next)
+	d.next = nil
+
+	for !d.done {
+		line, err := d.reader.ReadBytes('\n')
+		if err != nil {
+			if err != io.EOF {
+				return nil, errors.Wrap(err, "failed to read yaml")
+			}
+			d.done = true
+		}
+
+		trimmed := strings.TrimRight(string(line), "\r\n")
+		if isYAMLDocumentEnd(trimmed) {
+			return buf.Bytes(), nil
+		}
+		if rest, ok := yamlDocumentStart(trimmed); ok {
+			if rest != "" {
+				d.next = []byte(rest + "\n")
+			}
+			return buf.Bytes(), nil
+		}
+
+		buf.Write(line)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// yamlDocumentStart returns the content after a "---" separator, without comments
+func yamlDocumentStart(line string) (string, bool) {
+	if !strings.HasPrefix(line, "---") {
+		return "", false
+	}
+
+	rest := line[3:]
+	if rest == "" {
+		return "", true
+	}
+	if rest[0] != ' ' && rest[0] != '\t' {
+		return "", false
+	}
+
+	rest = strings.TrimSpace(rest)
+	if strings.HasPrefix(rest, "#") {
+		return "", true
+	}
+	return rest, true
+}
+
+func isYAMLDocumentEnd(line string) bool {
+	if !strings.HasPrefix(line, "...") {
+		return false
+	}
+
+	rest := strings.TrimSpace(line[3:])
+	return rest == "" || (strings.HasPrefix(rest, "#") && (line[3] == ' ' || line[3] == '\t'))
+}
+
+func isEmptyYAMLDocument(content []byte) bool {
+	for _, line := range strings.Split(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if trimmed != "" && !strings.HasPrefix(trimmed, "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// SplitYAML returns the non-empty documents in the content
+func SplitYAML(content []byte) ([]YAMLDocument, error) {
+	docs := []YAMLDocument{}
+
+	reader := NewYAMLDocumentReader(bytes.NewReader(content))
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			return docs, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		docs = append(docs, *doc)
+	}
+}
+
+// IsYAMLFile returns true if the file has a yaml or json extension
+func IsYAMLFile(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package k8sdoc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func Test_SplitYAML(t *testing.T) {
+	tests := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:     "single document",
+			content:  "a: b\n",
+			expected: []string{"a: b\n"},
+		},
+		{
+			name:     "separator at the start of the file",
+			content:  "---\na: b\n---\nc: d\n",
+			expected: []string{"a: b\n", "c: d\n"},
+		},
+		{
+			name:     "separator with a comment",
+			content:  "a: b\n--- # next\nc: d\n",
+			expected: []string{"a: b\n", "c: d\n"},
+		},
+		{
+			name:     "separator followed by content",
+			content:  "a: b\n--- c: d\ne: f\n",
+			expected: []string{"a: b\n", "c: d\ne: f\n"},
+		},
+		{
+			name:     "crlf line endings",
+			content:  "a: b\r\n---\r\nc: d\r\n",
+			expected: []string{"a: b\r\n", "c: d\r\n"},
+		},
+		{
+			name:     "document end markers",
+			content:  "a: b\n...\n---\nc: d\n...\n",
+			expected: []string{"a: b\n", "c: d\n"},
+		},
+		{
+			name:     "empty and comment only documents",
+			content:  "# header\n---\n\n---\na: b\n---\n# trailing\n",
+			expected: []string{"a: b\n"},
+		},
+		{
+			name:     "dashes that are not separators",
+			content:  "a: |\n  ---\n  text\nb: ---x\n",
+			expected: []string{"a: |\n  ---\n  text\nb: ---x\n"},
+		},
+		{
+			name:     "no trailing newline",
+			content:  "a: b\n---\nc: d",
+			expected: []string{"a: b\n", "c: d"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			docs, err := SplitYAML([]byte(test.content))
+			req.NoError(err)
+
+			actual := []string{}
+			for i, doc := range docs {
+				req.Equal(i, doc.Index)
+				actual = append(actual, string(doc.Content))
+			}
+			req.Equal(test.expected, actual)
+		})
+	}
+}
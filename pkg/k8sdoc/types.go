@@ -8,7 +8,8 @@ type Doc struct {
 }
 
 type Metadata struct {
-	Name string `yaml:"name"`
+	Name      string `yaml:"name"`
+	Namespace string `yaml:"namespace,omitempty"`
 }
 
 type Spec struct {
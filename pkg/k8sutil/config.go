@@ -19,10 +19,11 @@ func GetCurrentRules(deployOptions kotsadmtypes.DeployOptions, clientset *kubern
 		return nil, errors.Wrapf(err, "no SAR in ns %s", deployOptions.Namespace)
 	}
 
-	return convertToPolicyRule(response.Status), nil
+	return ConvertToPolicyRules(response.Status), nil
 }
 
-func convertToPolicyRule(status authorizationv1.SubjectRulesReviewStatus) []rbacv1.PolicyRule {
+// ConvertToPolicyRules converts the resource rules of a rules review to the rules of a role
+func ConvertToPolicyRules(status authorizationv1.SubjectRulesReviewStatus) []rbacv1.PolicyRule {
 	ret := []rbacv1.PolicyRule{}
 	// only include resource rules, not NonResourceRules, as those can't be part of a role
 	for _, resource := range status.ResourceRules {
@@ -2,6 +2,8 @@ package k8sutil
 
 import (
 	"io/ioutil"
+	"os"
+	"path/filepath"
 	"sort"
 	"strings"
 
@@ -38,8 +40,14 @@ func (s kustPatches) Less(i, j int) bool {
 }
 
 func WriteKustomizationToFile(kustomization *kustomizetypes.Kustomization, file string) error {
-	sort.Strings(kustomization.Bases)
 	sort.Strings(kustomization.Resources)
+
+	return WriteKustomizationToFileInOrder(kustomization, file)
+}
+
+// WriteKustomizationToFileInOrder keeps the order of the resources, which kustomize creates them in
+func WriteKustomizationToFileInOrder(kustomization *kustomizetypes.Kustomization, file string) error {
+	sort.Strings(kustomization.Bases)
 	sort.Sort(kustPatches(kustomization.PatchesStrategicMerge))
 
 	b, err := yaml.Marshal(kustomization)
@@ -53,3 +61,41 @@ func WriteKustomizationToFile(kustomization *kustomizetypes.Kustomization, file
 
 	return nil
 }
+
+// KustomizationNamespace returns the namespace that building the kustomization in rootDir sets on the
+// resources in dir, which is under rootDir. A namespace set in a kustomization closer to rootDir overrides
+// the namespaces of the bases it references. An empty string is returned if no kustomization sets one.
+func KustomizationNamespace(rootDir string, dir string) (string, error) {
+	relDir, err := filepath.Rel(rootDir, dir)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get relative path")
+	}
+
+	kustomizationDir := rootDir
+	parts := []string{"."}
+	if relDir != "." {
+		parts = append(parts, strings.Split(relDir, string(filepath.Separator))...)
+	}
+
+	for _, part := range parts {
+		kustomizationDir = filepath.Join(kustomizationDir, part)
+
+		filename := filepath.Join(kustomizationDir, "kustomization.yaml")
+		if _, err := os.Stat(filename); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return "", errors.Wrapf(err, "failed to stat %s", filename)
+		}
+
+		k, err := ReadKustomizationFromFile(filename)
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to read %s", filename)
+		}
+		if k.Namespace != "" {
+			return k.Namespace, nil
+		}
+	}
+
+	return "", nil
+}
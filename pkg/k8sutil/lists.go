@@ -1,11 +1,12 @@
-package midstream
+package k8sutil
 
 import (
 	"sigs.k8s.io/kustomize/v3/pkg/image"
 	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
 )
 
-func removeExistingImages(new []image.Image, existing []image.Image) []image.Image {
+// RemoveExistingImages returns the existing images that don't have the name of a new image
+func RemoveExistingImages(new []image.Image, existing []image.Image) []image.Image {
 	filteredImages := make([]image.Image, 0)
 	names := make(map[string]bool)
 
@@ -23,7 +24,27 @@ func removeExistingImages(new []image.Image, existing []image.Image) []image.Ima
 	return filteredImages
 }
 
-func findNewPatches(new []kustomizetypes.PatchStrategicMerge, existing []kustomizetypes.PatchStrategicMerge) []kustomizetypes.PatchStrategicMerge {
+// RemoveExistingReplicas returns the existing replicas that don't have the name of a new replica
+func RemoveExistingReplicas(new []kustomizetypes.Replica, existing []kustomizetypes.Replica) []kustomizetypes.Replica {
+	filteredReplicas := make([]kustomizetypes.Replica, 0)
+	names := make(map[string]bool)
+
+	for _, n := range new {
+		names[n.Name] = true
+	}
+
+	for _, e := range existing {
+		if _, exists := names[e.Name]; !exists {
+			names[e.Name] = true
+			filteredReplicas = append(filteredReplicas, e)
+		}
+	}
+
+	return filteredReplicas
+}
+
+// FindNewPatches returns the new patches that are not in the existing patches
+func FindNewPatches(new []kustomizetypes.PatchStrategicMerge, existing []kustomizetypes.PatchStrategicMerge) []kustomizetypes.PatchStrategicMerge {
 	newPatches := make([]kustomizetypes.PatchStrategicMerge, 0)
 	names := make(map[string]bool)
 
@@ -41,7 +62,8 @@ func findNewPatches(new []kustomizetypes.PatchStrategicMerge, existing []kustomi
 	return newPatches
 }
 
-func findNewStrings(new []string, existing []string) []string {
+// FindNewStrings returns the new strings that are not in the existing strings
+func FindNewStrings(new []string, existing []string) []string {
 	newStrings := make([]string, 0)
 	names := make(map[string]bool)
 
@@ -1,4 +1,4 @@
-package midstream
+package k8sutil
 
 import (
 	"testing"
@@ -6,7 +6,7 @@ import (
 	"github.com/stretchr/testify/assert"
 )
 
-func Test_findNewStrings(t *testing.T) {
+func Test_FindNewStrings(t *testing.T) {
 	tests := []struct {
 		existingList []string
 		newList      []string
@@ -30,7 +30,7 @@ func Test_findNewStrings(t *testing.T) {
 	}
 
 	for _, test := range tests {
-		diff := findNewStrings(test.newList, test.existingList)
+		diff := FindNewStrings(test.newList, test.existingList)
 		assert.Equal(t, test.expected, diff)
 	}
 }
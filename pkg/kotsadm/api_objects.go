@@ -162,6 +162,9 @@ func updateApiDeployment(deployment *appsv1.Deployment, deployOptions types.Depl
 	}
 	deployment.Spec.Template.Spec.Containers[containerIdx].Env = mergedEnvs
 
+	// scheduling and resources (added with the settings file)
+	updateComponentSettings(&deployment.Spec.Template.Spec, "kotsadm-api", deployOptions.PreviousSettings.API, deployOptions.Settings.API)
+
 	return nil
 }
 
@@ -173,6 +176,11 @@ func apiDeployment(deployOptions types.DeployOptions) *appsv1.Deployment {
 		}
 	}
 
+	s3Endpoint, s3Bucket := "http://kotsadm-minio:9000", "kotsadm"
+	if deployOptions.ExternalS3Endpoint != "" {
+		s3Endpoint, s3Bucket = deployOptions.ExternalS3Endpoint, deployOptions.ExternalS3Bucket
+	}
+
 	deployment := &appsv1.Deployment{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "apps/v1",
@@ -247,15 +255,15 @@ func apiDeployment(deployOptions types.DeployOptions) *appsv1.Deployment {
 								},
 								{
 									Name:  "SHIP_API_ADVERTISE_ENDPOINT",
-									Value: "http://localhost:8800",
+									Value: advertiseEndpoint(deployOptions),
 								},
 								{
 									Name:  "S3_ENDPOINT",
-									Value: "http://kotsadm-minio:9000",
+									Value: s3Endpoint,
 								},
 								{
 									Name:  "S3_BUCKET_NAME",
-									Value: "kotsadm",
+									Value: s3Bucket,
 								},
 								{
 									Name: "API_ENCRYPTION_KEY",
@@ -332,6 +340,8 @@ func apiDeployment(deployOptions types.DeployOptions) *appsv1.Deployment {
 		},
 	}
 
+	applyComponentSettings(&deployment.Spec.Template.Spec, "kotsadm-api", deployOptions.Settings.API)
+
 	return deployment
 }
 
@@ -0,0 +1,219 @@
+package kotsadm
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/kotsadm/types"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// self-signed certificates that expire within this time are renewed
+var selfSignedCertRenewBefore = time.Hour * 24 * 30
+
+func getIngressYAML(deployOptions types.DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	if !deployOptions.Ingress.Enabled {
+		return docs, nil
+	}
+
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	secret, err := desiredTLSSecret(deployOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build tls secret")
+	}
+	var tlsBuffer bytes.Buffer
+	if err := s.Encode(secret, &tlsBuffer); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal tls secret")
+	}
+	docs["secret-tls.yaml"] = tlsBuffer.Bytes()
+
+	var ingress bytes.Buffer
+	if err := s.Encode(kotsadmIngress(deployOptions), &ingress); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal kotsadm ingress")
+	}
+	docs["kotsadm-ingress.yaml"] = ingress.Bytes()
+
+	return docs, nil
+}
+
+func ensureIngress(deployOptions types.DeployOptions, clientset kubernetes.Interface) error {
+	if !deployOptions.Ingress.Enabled {
+		return nil
+	}
+
+	if err := ensureTLSSecret(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure tls secret")
+	}
+
+	desired := kotsadmIngress(deployOptions)
+	existing, err := clientset.NetworkingV1beta1().Ingresses(deployOptions.Namespace).Get("kotsadm", metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing ingress")
+		}
+
+		_, err := clientset.NetworkingV1beta1().Ingresses(deployOptions.Namespace).Create(desired)
+		if err != nil {
+			return errors.Wrap(err, "failed to create ingress")
+		}
+
+		return nil
+	}
+
+	existing.Annotations = desired.Annotations
+	existing.Labels = desired.Labels
+	existing.Spec = desired.Spec
+	if _, err := clientset.NetworkingV1beta1().Ingresses(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update ingress")
+	}
+
+	return nil
+}
+
+// ensureTLSSecret creates the tls secret of the ingress. An existing secret is replaced by a provided
+// certificate, and a self-signed certificate is renewed when it expires soon or is for a different host.
+func ensureTLSSecret(deployOptions types.DeployOptions, clientset kubernetes.Interface) error {
+	existing, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Get(tlsSecretName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get existing tls secret")
+		}
+
+		secret, err := desiredTLSSecret(deployOptions)
+		if err != nil {
+			return errors.Wrap(err, "failed to build tls secret")
+		}
+		_, err = clientset.CoreV1().Secrets(deployOptions.Namespace).Create(secret)
+		if err != nil {
+			return errors.Wrap(err, "failed to create tls secret")
+		}
+
+		return nil
+	}
+
+	if len(deployOptions.Ingress.TLSCert) == 0 {
+		if existing.Annotations[selfSignedCertAnnotation] != "true" {
+			return nil
+		}
+		if !selfSignedCertNeedsRenewal(existing.Data[corev1.TLSCertKey], deployOptions.Ingress.Host) {
+			return nil
+		}
+	}
+
+	secret, err := desiredTLSSecret(deployOptions)
+	if err != nil {
+		return errors.Wrap(err, "failed to build tls secret")
+	}
+	existing.Annotations = secret.Annotations
+	existing.Data = secret.Data
+	if _, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update tls secret")
+	}
+
+	return nil
+}
+
+// desiredTLSSecret is the secret with the provided certificate, or with a new self-signed certificate
+func desiredTLSSecret(deployOptions types.DeployOptions) (*corev1.Secret, error) {
+	if len(deployOptions.Ingress.TLSCert) > 0 {
+		return tlsSecret(deployOptions.Namespace, deployOptions.Ingress.TLSCert, deployOptions.Ingress.TLSKey, false), nil
+	}
+
+	cert, key, err := generateSelfSignedCert(deployOptions.Ingress.Host)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to generate self-signed certificate")
+	}
+
+	return tlsSecret(deployOptions.Namespace, cert, key, true), nil
+}
+
+func selfSignedCertNeedsRenewal(certPEM []byte, host string) bool {
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return true
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return true
+	}
+
+	if time.Now().Add(selfSignedCertRenewBefore).After(cert.NotAfter) {
+		return true
+	}
+
+	return cert.VerifyHostname(host) != nil
+}
+
+// getIngressOptions reads the ingress options from the ingress in the cluster, or returns nil if there is none.
+// The certificate is not read, the existing tls secret is kept on upgrade.
+func getIngressOptions(namespace string, clientset kubernetes.Interface) (*types.IngressOptions, error) {
+	ingress, err := clientset.NetworkingV1beta1().Ingresses(namespace).Get("kotsadm", metav1.GetOptions{})
+	if err != nil {
+		if kuberneteserrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to get ingress")
+	}
+
+	ingressOptions := types.IngressOptions{
+		Enabled: true,
+	}
+	if len(ingress.Spec.Rules) > 0 {
+		ingressOptions.Host = ingress.Spec.Rules[0].Host
+	}
+	for k, v := range ingress.Annotations {
+		if k == ingressClassAnnotation {
+			ingressOptions.IngressClass = v
+			continue
+		}
+		if ingressOptions.Annotations == nil {
+			ingressOptions.Annotations = map[string]string{}
+		}
+		ingressOptions.Annotations[k] = v
+	}
+
+	return &ingressOptions, nil
+}
+
+// advertiseEndpoint is the url that the admin console is accessed on
+func advertiseEndpoint(deployOptions types.DeployOptions) string {
+	if deployOptions.Ingress.Enabled {
+		return fmt.Sprintf("https://%s", deployOptions.Ingress.Host)
+	}
+	return "http://localhost:8800"
+}
+
+func validateIngress(deployOptions types.DeployOptions) error {
+	ingressOptions := deployOptions.Ingress
+	if !ingressOptions.Enabled {
+		if ingressOptions.Host != "" || len(ingressOptions.TLSCert) > 0 {
+			return errors.New("ingress options require the ingress to be enabled")
+		}
+		return nil
+	}
+
+	if ingressOptions.Host == "" {
+		return errors.New("an ingress requires a host")
+	}
+
+	if len(ingressOptions.TLSCert) == 0 && len(ingressOptions.TLSKey) == 0 {
+		return nil
+	}
+	if _, err := tls.X509KeyPair(ingressOptions.TLSCert, ingressOptions.TLSKey); err != nil {
+		return errors.Wrap(err, "failed to load tls certificate and key")
+	}
+
+	return nil
+}
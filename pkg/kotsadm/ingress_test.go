@@ -0,0 +1,215 @@
+package kotsadm
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/kotsadm/types"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1beta1 "k8s.io/api/networking/v1beta1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func Test_YAMLWithIngress(t *testing.T) {
+	providedCert, providedKey, err := generateSelfSignedCert("provided.example.com")
+	require.NoError(t, err)
+
+	tests := []struct {
+		name             string
+		ingressOptions   types.IngressOptions
+		expectIngress    bool
+		expectSelfSigned bool
+		expectAdvertise  string
+		expectErr        bool
+	}{
+		{
+			name:            "no ingress",
+			expectAdvertise: "http://localhost:8800",
+		},
+		{
+			name: "self-signed",
+			ingressOptions: types.IngressOptions{
+				Enabled:      true,
+				Host:         "kotsadm.example.com",
+				IngressClass: "nginx",
+				Annotations:  map[string]string{"nginx.ingress.kubernetes.io/proxy-body-size": "100m"},
+			},
+			expectIngress:    true,
+			expectSelfSigned: true,
+			expectAdvertise:  "https://kotsadm.example.com",
+		},
+		{
+			name: "provided certificate",
+			ingressOptions: types.IngressOptions{
+				Enabled: true,
+				Host:    "provided.example.com",
+				TLSCert: providedCert,
+				TLSKey:  providedKey,
+			},
+			expectIngress:   true,
+			expectAdvertise: "https://provided.example.com",
+		},
+		{
+			name: "no host",
+			ingressOptions: types.IngressOptions{
+				Enabled: true,
+			},
+			expectErr: true,
+		},
+		{
+			name: "certificate without a key",
+			ingressOptions: types.IngressOptions{
+				Enabled: true,
+				Host:    "provided.example.com",
+				TLSCert: providedCert,
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			docs, err := YAML(types.DeployOptions{Namespace: "default", Ingress: test.ingressOptions})
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+
+			decode := scheme.Codecs.UniversalDeserializer().Decode
+
+			obj, _, err := decode(docs["api-deployment.yaml"], nil, nil)
+			req.NoError(err)
+			advertise := ""
+			for _, env := range obj.(*appsv1.Deployment).Spec.Template.Spec.Containers[0].Env {
+				if env.Name == "SHIP_API_ADVERTISE_ENDPOINT" {
+					advertise = env.Value
+				}
+			}
+			req.Equal(test.expectAdvertise, advertise)
+
+			_, ok := docs["kotsadm-ingress.yaml"]
+			req.Equal(test.expectIngress, ok)
+			if !test.expectIngress {
+				return
+			}
+
+			obj, _, err = decode(docs["kotsadm-ingress.yaml"], nil, nil)
+			req.NoError(err)
+			ingress := obj.(*networkingv1beta1.Ingress)
+			req.Equal(test.ingressOptions.Host, ingress.Spec.Rules[0].Host)
+			req.Equal(tlsSecretName, ingress.Spec.TLS[0].SecretName)
+			if test.ingressOptions.IngressClass != "" {
+				req.Equal(test.ingressOptions.IngressClass, ingress.Annotations[ingressClassAnnotation])
+			}
+			for k, v := range test.ingressOptions.Annotations {
+				req.Equal(v, ingress.Annotations[k])
+			}
+
+			obj, _, err = decode(docs["secret-tls.yaml"], nil, nil)
+			req.NoError(err)
+			secret := obj.(*corev1.Secret)
+			req.Equal(corev1.SecretTypeTLS, secret.Type)
+			req.False(selfSignedCertNeedsRenewal(secret.Data[corev1.TLSCertKey], test.ingressOptions.Host))
+			req.Equal(test.expectSelfSigned, secret.Annotations[selfSignedCertAnnotation] == "true")
+			if !test.expectSelfSigned {
+				req.Equal(providedCert, secret.Data[corev1.TLSCertKey])
+			}
+		})
+	}
+}
+
+func Test_ensureIngress(t *testing.T) {
+	req := require.New(t)
+
+	clientset := fake.NewSimpleClientset()
+
+	deployOptions := types.DeployOptions{
+		Namespace: "test",
+		Ingress: types.IngressOptions{
+			Enabled:      true,
+			Host:         "kotsadm.example.com",
+			IngressClass: "nginx",
+		},
+	}
+	req.NoError(ensureIngress(deployOptions, clientset))
+
+	secret, err := clientset.CoreV1().Secrets("test").Get(tlsSecretName, metav1.GetOptions{})
+	req.NoError(err)
+	firstCert := secret.Data[corev1.TLSCertKey]
+
+	// the options are read back on upgrade, and the certificate is kept while it is valid for the host
+	ingressOptions, err := getIngressOptions("test", clientset)
+	req.NoError(err)
+	req.Equal(deployOptions.Ingress, *ingressOptions)
+
+	req.NoError(ensureIngress(deployOptions, clientset))
+	secret, err = clientset.CoreV1().Secrets("test").Get(tlsSecretName, metav1.GetOptions{})
+	req.NoError(err)
+	req.Equal(firstCert, secret.Data[corev1.TLSCertKey])
+
+	// a new host renews the self-signed certificate
+	deployOptions.Ingress.Host = "admin.example.com"
+	req.NoError(ensureIngress(deployOptions, clientset))
+	secret, err = clientset.CoreV1().Secrets("test").Get(tlsSecretName, metav1.GetOptions{})
+	req.NoError(err)
+	req.NotEqual(firstCert, secret.Data[corev1.TLSCertKey])
+	req.False(selfSignedCertNeedsRenewal(secret.Data[corev1.TLSCertKey], "admin.example.com"))
+
+	ingress, err := clientset.NetworkingV1beta1().Ingresses("test").Get("kotsadm", metav1.GetOptions{})
+	req.NoError(err)
+	req.Equal("admin.example.com", ingress.Spec.Rules[0].Host)
+}
+
+func Test_generateSelfSignedCert(t *testing.T) {
+	tests := []struct {
+		name        string
+		host        string
+		dnsNames    []string
+		ipAddresses []string
+	}{
+		{
+			name:     "hostname",
+			host:     "kotsadm.example.com",
+			dnsNames: []string{"kotsadm.example.com"},
+		},
+		{
+			name:        "ip address",
+			host:        "10.0.0.10",
+			ipAddresses: []string{"10.0.0.10"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			certPEM, _, err := generateSelfSignedCert(test.host)
+			req.NoError(err)
+
+			block, _ := pem.Decode(certPEM)
+			req.NotNil(block)
+			cert, err := x509.ParseCertificate(block.Bytes)
+			req.NoError(err)
+
+			req.Equal(test.dnsNames, cert.DNSNames)
+			ipAddresses := []string{}
+			for _, ip := range cert.IPAddresses {
+				ipAddresses = append(ipAddresses, ip.String())
+			}
+			if test.ipAddresses == nil {
+				req.Empty(ipAddresses)
+			} else {
+				req.Equal(test.ipAddresses, ipAddresses)
+			}
+			req.False(selfSignedCertNeedsRenewal(certPEM, test.host))
+		})
+	}
+}
@@ -219,6 +219,8 @@ func kotsadmDeployment(deployOptions types.DeployOptions) *appsv1.Deployment {
 		},
 	}
 
+	applyComponentSettings(&deployment.Spec.Template.Spec, "kotsadm", deployOptions.Settings.Kotsadm)
+
 	return deployment
 }
 
@@ -1,6 +1,8 @@
 package kotsadm
 
 import (
+	"strings"
+
 	"github.com/google/uuid"
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/kotsadm/types"
@@ -14,6 +16,13 @@ import (
 
 // YAML will return a map containing the YAML needed to run the admin console
 func YAML(deployOptions types.DeployOptions) (map[string][]byte, error) {
+	if err := validateExternalDatastores(deployOptions); err != nil {
+		return nil, err
+	}
+	if err := validateIngress(deployOptions); err != nil {
+		return nil, err
+	}
+
 	docs := map[string][]byte{}
 
 	if deployOptions.ApplicationMetadata != nil {
@@ -26,20 +35,24 @@ func YAML(deployOptions types.DeployOptions) (map[string][]byte, error) {
 		}
 	}
 
-	minioDocs, err := getMinioYAML(deployOptions)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get minio yaml")
-	}
-	for n, v := range minioDocs {
-		docs[n] = v
+	if deployOptions.ExternalS3Endpoint == "" {
+		minioDocs, err := getMinioYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get minio yaml")
+		}
+		for n, v := range minioDocs {
+			docs[n] = v
+		}
 	}
 
-	postgresDocs, err := getPostgresYAML(deployOptions)
-	if err != nil {
-		return nil, errors.Wrap(err, "failed to get postgres yaml")
-	}
-	for n, v := range postgresDocs {
-		docs[n] = v
+	if deployOptions.ExternalPostgresURI == "" {
+		postgresDocs, err := getPostgresYAML(deployOptions)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get postgres yaml")
+		}
+		for n, v := range postgresDocs {
+			docs[n] = v
+		}
 	}
 
 	migrationDocs, err := getMigrationsYAML(deployOptions)
@@ -59,6 +72,14 @@ func YAML(deployOptions types.DeployOptions) (map[string][]byte, error) {
 		docs[n] = v
 	}
 
+	settingsDocs, err := getSettingsYAML(deployOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get settings yaml")
+	}
+	for n, v := range settingsDocs {
+		docs[n] = v
+	}
+
 	// api
 	apiDocs, err := getApiYAML(deployOptions)
 	if err != nil {
@@ -77,6 +98,15 @@ func YAML(deployOptions types.DeployOptions) (map[string][]byte, error) {
 		docs[n] = v
 	}
 
+	// ingress
+	ingressDocs, err := getIngressYAML(deployOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get ingress yaml")
+	}
+	for n, v := range ingressDocs {
+		docs[n] = v
+	}
+
 	// operator
 	operatorDocs, err := getOperatorYAML(deployOptions)
 	if err != nil {
@@ -113,6 +143,15 @@ func Upgrade(upgradeOptions types.UpgradeOptions) error {
 	if err != nil {
 		return errors.Wrap(err, "failed to read deploy options")
 	}
+	if upgradeOptions.Settings != nil {
+		deployOptions.Settings = *upgradeOptions.Settings
+	}
+	if upgradeOptions.Ingress != nil {
+		deployOptions.Ingress = *upgradeOptions.Ingress
+		if err := validateIngress(*deployOptions); err != nil {
+			return err
+		}
+	}
 
 	if err := ensureKotsadm(*deployOptions, clientset, log); err != nil {
 		return errors.Wrap(err, "failed to upgrade admin console")
@@ -126,6 +165,13 @@ func Upgrade(upgradeOptions types.UpgradeOptions) error {
 }
 
 func Deploy(deployOptions types.DeployOptions) error {
+	if err := validateExternalDatastores(deployOptions); err != nil {
+		return err
+	}
+	if err := validateIngress(deployOptions); err != nil {
+		return err
+	}
+
 	cfg, err := config.GetConfig()
 	if err != nil {
 		return errors.Wrap(err, "failed to get cluster config")
@@ -138,6 +184,17 @@ func Deploy(deployOptions types.DeployOptions) error {
 
 	log := logger.NewLogger()
 
+	if deployOptions.MinimalRBAC {
+		report, err := checkMinimalRBAC(clientset, deployOptions)
+		if err != nil {
+			return errors.Wrap(err, "failed to check minimal rbac")
+		}
+		// when the review is incomplete, the missing permissions may be granted by another authorizer
+		if len(report.Missing) > 0 && !report.Incomplete {
+			return errors.Errorf("the roles of minimal rbac can't be granted without these permissions: %s", strings.Join(report.Missing, "; "))
+		}
+	}
+
 	namespace := &corev1.Namespace{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "v1",
@@ -199,12 +256,32 @@ func removeUnusedKotsadmComponents(deployOptions types.DeployOptions, clientset
 }
 
 func ensureKotsadm(deployOptions types.DeployOptions, clientset *kubernetes.Clientset, log *logger.Logger) error {
-	if err := ensureMinio(deployOptions, clientset); err != nil {
-		return errors.Wrap(err, "failed to ensure minio")
+	previousSettings, err := ensureSettings(deployOptions, clientset)
+	if err != nil {
+		return errors.Wrap(err, "failed to ensure settings")
+	}
+	if previousSettings != nil {
+		deployOptions.PreviousSettings = *previousSettings
 	}
 
-	if err := ensurePostgres(deployOptions, clientset); err != nil {
-		return errors.Wrap(err, "failed to ensure postgres")
+	if deployOptions.ExternalS3Endpoint == "" {
+		if err := ensureMinio(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure minio")
+		}
+	} else {
+		if err := ensureS3Secret(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure external s3 secret")
+		}
+	}
+
+	if deployOptions.ExternalPostgresURI == "" {
+		if err := ensurePostgres(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure postgres")
+		}
+	} else {
+		if err := ensurePostgresSecret(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure external postgres secret")
+		}
 	}
 
 	if err := runSchemaHeroMigrations(deployOptions, clientset); err != nil {
@@ -219,6 +296,10 @@ func ensureKotsadm(deployOptions types.DeployOptions, clientset *kubernetes.Clie
 		return errors.Wrap(err, "failed to ensure kotsadm exists")
 	}
 
+	if err := ensureIngress(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure ingress")
+	}
+
 	if err := ensureAPI(&deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure api exists")
 	}
@@ -285,6 +366,13 @@ func readDeployOptionsFromCluster(namespace string, kubeconfig string, clientset
 		if ok {
 			deployOptions.S3SecretKey = string(secretyKey)
 		}
+
+		// only the secret of an external object store has an endpoint
+		endpoint, ok := s3Secret.Data["endpoint"]
+		if ok {
+			deployOptions.ExternalS3Endpoint = string(endpoint)
+			deployOptions.ExternalS3Bucket = string(s3Secret.Data["bucket"])
+		}
 	}
 	if deployOptions.S3AccessKey == "" {
 		deployOptions.S3AccessKey = uuid.New().String()
@@ -314,6 +402,9 @@ func readDeployOptionsFromCluster(namespace string, kubeconfig string, clientset
 		password, ok := pgSecret.Data["password"]
 		if ok {
 			deployOptions.PostgresPassword = string(password)
+		} else {
+			// the secret of an external database only has the uri
+			deployOptions.ExternalPostgresURI = string(pgSecret.Data["uri"])
 		}
 	}
 
@@ -338,5 +429,52 @@ func readDeployOptionsFromCluster(namespace string, kubeconfig string, clientset
 		deployOptions.AutoCreateClusterToken = autocreateClusterToken
 	}
 
+	// scheduling and resource settings, empty if the admin console was installed without them
+	settings, err := getSettings(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get settings")
+	}
+	if settings != nil {
+		deployOptions.Settings = *settings
+	}
+
+	// minimal rbac, so that the operator doesn't get a cluster role on upgrade
+	appNamespaces, err := getMinimalRBACNamespaces(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get minimal rbac namespaces")
+	}
+	if appNamespaces != nil {
+		deployOptions.MinimalRBAC = true
+		deployOptions.AppNamespaces = appNamespaces
+	}
+
+	// ingress, so that the advertised url is kept
+	ingressOptions, err := getIngressOptions(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get ingress options")
+	}
+	if ingressOptions != nil {
+		deployOptions.Ingress = *ingressOptions
+	}
+
 	return &deployOptions, nil
 }
+
+// validateExternalDatastores checks that an external object store has a bucket and credentials
+func validateExternalDatastores(deployOptions types.DeployOptions) error {
+	if deployOptions.ExternalS3Endpoint == "" {
+		if deployOptions.ExternalS3Bucket != "" {
+			return errors.New("an external s3 bucket requires an external s3 endpoint")
+		}
+		return nil
+	}
+
+	if deployOptions.ExternalS3Bucket == "" {
+		return errors.New("an external s3 endpoint requires a bucket")
+	}
+	if deployOptions.S3AccessKey == "" || deployOptions.S3SecretKey == "" {
+		return errors.New("an external s3 endpoint requires an access key and a secret key")
+	}
+
+	return nil
+}
@@ -0,0 +1,393 @@
+package kotsadm
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/k8sutil"
+	"github.com/replicatedhq/kots/pkg/kotsadm/types"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// MinimalRBACReport describes an install with minimal rbac
+type MinimalRBACReport struct {
+	Namespaces  []string // the namespaces that the operator can deploy to
+	Missing     []string // permissions that the installer doesn't have, and so can't grant
+	Incomplete  bool     // true if the cluster could not list all of the permissions of the installer
+	Limitations []string // what the application can't do with minimal rbac
+	ClusterRBAC []string // cluster rbac of an earlier install that binds the operator, and is removed
+}
+
+// roleManagementRules are needed by the installer to create the roles and bindings in each namespace
+var roleManagementRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{"rbac.authorization.k8s.io"},
+		Resources: []string{"roles", "rolebindings"},
+		Verbs:     metav1.Verbs{"get", "create", "update"},
+	},
+}
+
+// clusterRBACRemovalRules are needed by the installer to remove the cluster rbac of the operator from an
+// earlier install without minimal rbac
+var clusterRBACRemovalRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{"rbac.authorization.k8s.io"},
+		Resources: []string{"clusterroles", "clusterrolebindings"},
+		Verbs:     metav1.Verbs{"get", "update", "delete"},
+	},
+}
+
+// CheckMinimalRBAC checks that the current user can create the roles of an install with minimal rbac
+func CheckMinimalRBAC(deployOptions types.DeployOptions) (*MinimalRBACReport, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	return checkMinimalRBAC(clientset, deployOptions)
+}
+
+// checkMinimalRBAC reviews the rules of the current user in each namespace. Kubernetes only allows a user
+// to grant the permissions that it has, so every permission of the roles must be held by the installer.
+func checkMinimalRBAC(clientset kubernetes.Interface, deployOptions types.DeployOptions) (*MinimalRBACReport, error) {
+	namespaces := minimalRBACNamespaces(deployOptions)
+	report := MinimalRBACReport{
+		Namespaces:  namespaces,
+		Missing:     []string{},
+		Limitations: minimalRBACLimitations(namespaces),
+		ClusterRBAC: []string{},
+	}
+
+	clusterRoleBinding, err := getOperatorClusterRoleBinding(deployOptions.Namespace, clientset)
+	if kuberneteserrors.IsForbidden(err) {
+		report.Limitations = append(report.Limitations, "the cluster rbac of an earlier install could not be checked, and is not removed if there is any")
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to get operator cluster role binding")
+	} else if clusterRoleBinding != nil {
+		report.ClusterRBAC = append(report.ClusterRBAC, fmt.Sprintf("ClusterRoleBinding %s", clusterRoleBinding.Name))
+		if len(clusterRoleBinding.Subjects) == 1 {
+			report.ClusterRBAC = append(report.ClusterRBAC, fmt.Sprintf("ClusterRole %s", clusterRoleBinding.RoleRef.Name))
+		}
+	}
+
+	for _, namespace := range namespaces {
+		review, err := clientset.AuthorizationV1().SelfSubjectRulesReviews().Create(&authorizationv1.SelfSubjectRulesReview{
+			Spec: authorizationv1.SelfSubjectRulesReviewSpec{
+				Namespace: namespace,
+			},
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to review rules in namespace %s", namespace)
+		}
+		if review.Status.Incomplete {
+			report.Incomplete = true
+		}
+
+		required := append([]rbacv1.PolicyRule{}, roleManagementRules...)
+		required = append(required, minimalOperatorRules...)
+		if namespace == deployOptions.Namespace {
+			required = append(required, kotsadmRole(namespace).Rules...)
+			required = append(required, apiRole(namespace).Rules...)
+			if clusterRoleBinding != nil {
+				required = append(required, clusterRBACRemovalRules...)
+			}
+		}
+
+		have := k8sutil.ConvertToPolicyRules(review.Status)
+		for _, missing := range missingPermissions(have, required) {
+			report.Missing = append(report.Missing, fmt.Sprintf("%s in namespace %s", missing, namespace))
+		}
+	}
+
+	return &report, nil
+}
+
+// minimalRBACNamespaces is the admin console namespace followed by the other app namespaces
+func minimalRBACNamespaces(deployOptions types.DeployOptions) []string {
+	namespaces := []string{deployOptions.Namespace}
+	seen := map[string]bool{deployOptions.Namespace: true}
+	for _, namespace := range deployOptions.AppNamespaces {
+		if namespace == "" || seen[namespace] {
+			continue
+		}
+		seen[namespace] = true
+		namespaces = append(namespaces, namespace)
+	}
+	return namespaces
+}
+
+func minimalRBACLimitations(namespaces []string) []string {
+	kinds := []string{}
+	for _, rule := range minimalOperatorRules {
+		if len(rule.Verbs) != len(manageVerbs) {
+			continue
+		}
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				kinds = append(kinds, groupResource(group, resource))
+			}
+		}
+	}
+	sort.Strings(kinds)
+
+	return []string{
+		fmt.Sprintf("resources can only be deployed to the namespaces %s", strings.Join(namespaces, ", ")),
+		fmt.Sprintf("only these resources can be deployed: %s", strings.Join(kinds, ", ")),
+		"cluster scoped resources, such as CustomResourceDefinitions, ClusterRoles, Namespaces and StorageClasses, can't be deployed",
+		"custom resources, and Roles and RoleBindings, can't be deployed",
+	}
+}
+
+// missingPermissions lists the verbs on resources in the wanted rules that none of the rules that the user has allow
+func missingPermissions(have []rbacv1.PolicyRule, want []rbacv1.PolicyRule) []string {
+	missing := []string{}
+	seen := map[string]bool{}
+	for _, rule := range want {
+		for _, group := range rule.APIGroups {
+			for _, resource := range rule.Resources {
+				for _, verb := range rule.Verbs {
+					if rulesAllow(have, group, resource, verb, rule.ResourceNames) {
+						continue
+					}
+
+					permission := fmt.Sprintf("%s %s", verb, groupResource(group, resource))
+					if len(rule.ResourceNames) > 0 {
+						permission = fmt.Sprintf("%s (%s)", permission, strings.Join(rule.ResourceNames, ", "))
+					}
+					if !seen[permission] {
+						seen[permission] = true
+						missing = append(missing, permission)
+					}
+				}
+			}
+		}
+	}
+	return missing
+}
+
+func rulesAllow(rules []rbacv1.PolicyRule, group string, resource string, verb string, resourceNames []string) bool {
+	for _, rule := range rules {
+		if !matches(rule.APIGroups, group) || !matches(rule.Resources, resource) || !matches(rule.Verbs, verb) {
+			continue
+		}
+
+		// a rule that is restricted to names only allows those names
+		if len(rule.ResourceNames) > 0 {
+			if len(resourceNames) == 0 {
+				continue
+			}
+			allowed := true
+			for _, name := range resourceNames {
+				if !matches(rule.ResourceNames, name) {
+					allowed = false
+				}
+			}
+			if !allowed {
+				continue
+			}
+		}
+
+		return true
+	}
+	return false
+}
+
+func matches(values []string, value string) bool {
+	for _, v := range values {
+		if v == value || v == rbacv1.ResourceAll {
+			return true
+		}
+	}
+	return false
+}
+
+func groupResource(group string, resource string) string {
+	if group == "" {
+		return resource
+	}
+	return fmt.Sprintf("%s.%s", resource, group)
+}
+
+// ensureMinimalOperatorRBAC creates the roles and bindings of the operator in each app namespace. Roles that
+// already exist are updated, and the operator is unbound from the cluster role of an earlier install, so that
+// broader permissions from an earlier install are removed.
+func ensureMinimalOperatorRBAC(deployOptions types.DeployOptions, clientset kubernetes.Interface) error {
+	if err := ensureAppNamespaces(deployOptions, clientset); err != nil {
+		return errors.Wrap(err, "failed to ensure app namespaces")
+	}
+
+	for _, namespace := range minimalRBACNamespaces(deployOptions) {
+		role := operatorMinimalRole(namespace)
+		existingRole, err := clientset.RbacV1().Roles(namespace).Get(role.Name, metav1.GetOptions{})
+		if err != nil {
+			if !kuberneteserrors.IsNotFound(err) {
+				return errors.Wrapf(err, "failed to get role in namespace %s", namespace)
+			}
+			if _, err := clientset.RbacV1().Roles(namespace).Create(role); err != nil {
+				if kuberneteserrors.IsNotFound(err) {
+					return errors.Errorf("namespace %s does not exist, it must be created before installing with minimal rbac", namespace)
+				}
+				return errors.Wrapf(err, "failed to create role in namespace %s", namespace)
+			}
+		} else {
+			existingRole.Rules = role.Rules
+			if _, err := clientset.RbacV1().Roles(namespace).Update(existingRole); err != nil {
+				return errors.Wrapf(err, "failed to update role in namespace %s", namespace)
+			}
+		}
+
+		_, err = clientset.RbacV1().RoleBindings(namespace).Create(operatorAppRoleBinding(namespace, deployOptions.Namespace))
+		if err != nil && !kuberneteserrors.IsAlreadyExists(err) {
+			return errors.Wrapf(err, "failed to create rolebinding in namespace %s", namespace)
+		}
+	}
+
+	if err := unbindOperatorClusterRBAC(deployOptions.Namespace, clientset); err != nil {
+		return errors.Wrap(err, "failed to remove operator cluster rbac")
+	}
+
+	desired := rbacConfigMap(deployOptions)
+	existing, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Get(rbacConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return errors.Wrap(err, "failed to get rbac config map")
+		}
+		if _, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Create(desired); err != nil {
+			return errors.Wrap(err, "failed to create rbac config map")
+		}
+		return nil
+	}
+
+	existing.Data = desired.Data
+	if _, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Update(existing); err != nil {
+		return errors.Wrap(err, "failed to update rbac config map")
+	}
+
+	return nil
+}
+
+// ensureAppNamespaces creates the app namespaces that don't exist. A user that can't create a namespace may still
+// have access to it, so a namespace is only reported as missing when it's known not to exist.
+func ensureAppNamespaces(deployOptions types.DeployOptions, clientset kubernetes.Interface) error {
+	for _, namespace := range minimalRBACNamespaces(deployOptions)[1:] {
+		_, err := clientset.CoreV1().Namespaces().Create(&corev1.Namespace{
+			TypeMeta: metav1.TypeMeta{
+				APIVersion: "v1",
+				Kind:       "Namespace",
+			},
+			ObjectMeta: metav1.ObjectMeta{
+				Name: namespace,
+			},
+		})
+		if err == nil || kuberneteserrors.IsAlreadyExists(err) {
+			continue
+		}
+
+		createErr := err
+		_, err = clientset.CoreV1().Namespaces().Get(namespace, metav1.GetOptions{})
+		if kuberneteserrors.IsNotFound(err) {
+			return errors.Wrapf(createErr, "namespace %s does not exist and could not be created", namespace)
+		}
+	}
+
+	return nil
+}
+
+// getOperatorClusterRoleBinding returns the cluster role binding of an earlier install without minimal rbac, if
+// it binds the operator in the namespace. The error is not wrapped, so that callers can check if it's forbidden.
+func getOperatorClusterRoleBinding(namespace string, clientset kubernetes.Interface) (*rbacv1.ClusterRoleBinding, error) {
+	clusterRoleBinding, err := clientset.RbacV1().ClusterRoleBindings().Get(operatorClusterRoleBinding(namespace).Name, metav1.GetOptions{})
+	if err != nil {
+		if kuberneteserrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	for _, subject := range clusterRoleBinding.Subjects {
+		if isOperatorSubject(subject, namespace) {
+			return clusterRoleBinding, nil
+		}
+	}
+	return nil, nil
+}
+
+func isOperatorSubject(subject rbacv1.Subject, namespace string) bool {
+	return subject.Kind == "ServiceAccount" && subject.Name == "kotsadm-operator" && subject.Namespace == namespace
+}
+
+// unbindOperatorClusterRBAC removes the operator in the namespace from the cluster role binding of an earlier
+// install. The binding is shared by the admin consoles in other namespaces, so the binding and its cluster role
+// are only deleted when no other operator is bound. When the user can't read cluster rbac, nothing is removed
+// and checkMinimalRBAC reports it as a limitation.
+func unbindOperatorClusterRBAC(namespace string, clientset kubernetes.Interface) error {
+	clusterRoleBinding, err := getOperatorClusterRoleBinding(namespace, clientset)
+	if kuberneteserrors.IsForbidden(err) {
+		return nil
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster role binding")
+	}
+	if clusterRoleBinding == nil {
+		return nil
+	}
+
+	subjects := []rbacv1.Subject{}
+	for _, subject := range clusterRoleBinding.Subjects {
+		if !isOperatorSubject(subject, namespace) {
+			subjects = append(subjects, subject)
+		}
+	}
+
+	if len(subjects) > 0 {
+		clusterRoleBinding.Subjects = subjects
+		if _, err := clientset.RbacV1().ClusterRoleBindings().Update(clusterRoleBinding); err != nil {
+			return errors.Wrapf(err, "failed to remove the operator from cluster role binding %s", clusterRoleBinding.Name)
+		}
+		return nil
+	}
+
+	err = clientset.RbacV1().ClusterRoleBindings().Delete(clusterRoleBinding.Name, &metav1.DeleteOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete cluster role binding %s", clusterRoleBinding.Name)
+	}
+	err = clientset.RbacV1().ClusterRoles().Delete(clusterRoleBinding.RoleRef.Name, &metav1.DeleteOptions{})
+	if err != nil && !kuberneteserrors.IsNotFound(err) {
+		return errors.Wrapf(err, "failed to delete cluster role %s", clusterRoleBinding.RoleRef.Name)
+	}
+
+	return nil
+}
+
+// getMinimalRBACNamespaces returns the app namespaces of an install with minimal rbac, or nil if the admin
+// console in the namespace was not installed with minimal rbac
+func getMinimalRBACNamespaces(namespace string, clientset kubernetes.Interface) ([]string, error) {
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(rbacConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if kuberneteserrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to get rbac config map")
+	}
+
+	namespaces := []string{}
+	for _, appNamespace := range strings.Split(configMap.Data["appNamespaces"], ",") {
+		if appNamespace != "" {
+			namespaces = append(namespaces, appNamespace)
+		}
+	}
+	return namespaces, nil
+}
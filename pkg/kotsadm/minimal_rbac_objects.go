@@ -0,0 +1,83 @@
+package kotsadm
+
+import (
+	"strings"
+
+	"github.com/replicatedhq/kots/pkg/kotsadm/types"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const rbacConfigMapName = "kotsadm-rbac"
+
+var manageVerbs = metav1.Verbs{"get", "list", "watch", "create", "update", "patch", "delete"}
+
+// minimalOperatorRules are the permissions of the operator in each app namespace with minimal rbac.
+// They cover the workloads, networking and storage claims of an application, but not rbac or custom resources.
+var minimalOperatorRules = []rbacv1.PolicyRule{
+	{
+		APIGroups: []string{""},
+		Resources: []string{"configmaps", "secrets", "services", "serviceaccounts", "persistentvolumeclaims", "pods"},
+		Verbs:     manageVerbs,
+	},
+	{
+		APIGroups: []string{""},
+		Resources: []string{"pods/log", "endpoints", "events"},
+		Verbs:     metav1.Verbs{"get", "list", "watch"},
+	},
+	{
+		APIGroups: []string{"apps"},
+		Resources: []string{"deployments", "statefulsets", "daemonsets", "replicasets"},
+		Verbs:     manageVerbs,
+	},
+	{
+		APIGroups: []string{"batch"},
+		Resources: []string{"jobs", "cronjobs"},
+		Verbs:     manageVerbs,
+	},
+	{
+		APIGroups: []string{"extensions", "networking.k8s.io"},
+		Resources: []string{"ingresses", "networkpolicies"},
+		Verbs:     manageVerbs,
+	},
+	{
+		APIGroups: []string{"policy"},
+		Resources: []string{"poddisruptionbudgets"},
+		Verbs:     manageVerbs,
+	},
+	{
+		APIGroups: []string{"autoscaling"},
+		Resources: []string{"horizontalpodautoscalers"},
+		Verbs:     manageVerbs,
+	},
+}
+
+func operatorMinimalRole(namespace string) *rbacv1.Role {
+	role := operatorRole(namespace)
+	role.Rules = minimalOperatorRules
+
+	return role
+}
+
+func rbacConfigMap(deployOptions types.DeployOptions) *corev1.ConfigMap {
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      rbacConfigMapName,
+			Namespace: deployOptions.Namespace,
+			Labels: map[string]string{
+				types.KotsadmKey: types.KotsadmLabelValue,
+			},
+		},
+		Data: map[string]string{
+			"mode":          "minimal",
+			"appNamespaces": strings.Join(deployOptions.AppNamespaces, ","),
+		},
+	}
+
+	return configMap
+}
@@ -0,0 +1,218 @@
+package kotsadm
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/kotsadm/types"
+	"github.com/stretchr/testify/require"
+	authorizationv1 "k8s.io/api/authorization/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func Test_checkMinimalRBAC(t *testing.T) {
+	tests := []struct {
+		name          string
+		resourceRules []authorizationv1.ResourceRule
+		incomplete    bool
+		expectMissing []string
+	}{
+		{
+			name: "admin",
+			resourceRules: []authorizationv1.ResourceRule{
+				{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+			},
+			expectMissing: []string{},
+		},
+		{
+			name: "no apps or named secrets",
+			resourceRules: []authorizationv1.ResourceRule{
+				{APIGroups: []string{"", "batch", "extensions", "networking.k8s.io", "policy", "autoscaling", "rbac.authorization.k8s.io"}, Resources: []string{"*"}, Verbs: []string{"*"}},
+				{APIGroups: []string{"apps"}, Resources: []string{"deployments", "statefulsets", "daemonsets", "replicasets"}, Verbs: []string{"get", "list", "watch", "create", "update", "patch"}},
+			},
+			incomplete: true,
+			expectMissing: []string{
+				"delete deployments.apps in namespace test",
+				"delete statefulsets.apps in namespace test",
+				"delete daemonsets.apps in namespace test",
+				"delete replicasets.apps in namespace test",
+				"delete deployments.apps in namespace app",
+				"delete statefulsets.apps in namespace app",
+				"delete daemonsets.apps in namespace app",
+				"delete replicasets.apps in namespace app",
+			},
+		},
+		{
+			name: "secrets restricted by name",
+			resourceRules: []authorizationv1.ResourceRule{
+				{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"*"}, ResourceNames: []string{"kotsadm-encryption"}},
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			clientset := fake.NewSimpleClientset()
+			clientset.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+				review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectRulesReview)
+				review.Status = authorizationv1.SubjectRulesReviewStatus{
+					ResourceRules: test.resourceRules,
+					Incomplete:    test.incomplete,
+				}
+				return true, review, nil
+			})
+
+			report, err := checkMinimalRBAC(clientset, types.DeployOptions{
+				Namespace:     "test",
+				MinimalRBAC:   true,
+				AppNamespaces: []string{"app", "test"},
+			})
+			req.NoError(err)
+			req.Equal([]string{"test", "app"}, report.Namespaces)
+			req.Equal(test.incomplete, report.Incomplete)
+			req.NotEmpty(report.Limitations)
+			if test.expectMissing != nil {
+				req.Equal(test.expectMissing, report.Missing)
+			} else {
+				req.Contains(report.Missing, "update secrets (kotsadm-encryption, kotsadm-gitops, kotsadm-password, kotsadm-authstring) in namespace test")
+				req.Contains(report.Missing, "create roles.rbac.authorization.k8s.io in namespace app")
+			}
+		})
+	}
+}
+
+func Test_ensureMinimalOperatorRBAC(t *testing.T) {
+	req := require.New(t)
+
+	// a role and the cluster rbac from an install without minimal rbac, and the binding is shared
+	// with the operator of another admin console
+	clusterRole := operatorClusterRole("")
+	clusterRoleBinding := operatorClusterRoleBinding("")
+	clusterRoleBinding.Subjects = []rbacv1.Subject{
+		{Kind: "ServiceAccount", Name: "kotsadm-operator", Namespace: "test"},
+		{Kind: "ServiceAccount", Name: "kotsadm-operator", Namespace: "other"},
+	}
+	clientset := fake.NewSimpleClientset(operatorRole("test"), clusterRole, clusterRoleBinding)
+	clientset.PrependReactor("create", "selfsubjectrulesreviews", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		review := action.(k8stesting.CreateAction).GetObject().(*authorizationv1.SelfSubjectRulesReview)
+		review.Status = authorizationv1.SubjectRulesReviewStatus{
+			ResourceRules: []authorizationv1.ResourceRule{
+				{APIGroups: []string{"*"}, Resources: []string{"*"}, Verbs: []string{"get", "list", "watch", "create", "update", "patch"}},
+			},
+		}
+		return true, review, nil
+	})
+
+	deployOptions := types.DeployOptions{
+		Namespace:     "test",
+		MinimalRBAC:   true,
+		AppNamespaces: []string{"app"},
+	}
+
+	report, err := checkMinimalRBAC(clientset, deployOptions)
+	req.NoError(err)
+	req.Equal([]string{"ClusterRoleBinding kotsadm-operator-rolebinding"}, report.ClusterRBAC)
+	req.Contains(report.Missing, "delete clusterrolebindings.rbac.authorization.k8s.io in namespace test")
+	req.NotContains(report.Missing, "delete clusterrolebindings.rbac.authorization.k8s.io in namespace app")
+
+	req.NoError(ensureMinimalOperatorRBAC(deployOptions, clientset))
+	req.NoError(ensureMinimalOperatorRBAC(deployOptions, clientset))
+
+	_, err = clientset.CoreV1().Namespaces().Get("app", metav1.GetOptions{})
+	req.NoError(err)
+
+	// the other operator stays bound to the cluster role
+	existingBinding, err := clientset.RbacV1().ClusterRoleBindings().Get("kotsadm-operator-rolebinding", metav1.GetOptions{})
+	req.NoError(err)
+	req.Equal([]rbacv1.Subject{{Kind: "ServiceAccount", Name: "kotsadm-operator", Namespace: "other"}}, existingBinding.Subjects)
+	_, err = clientset.RbacV1().ClusterRoles().Get("kotsadm-operator-role", metav1.GetOptions{})
+	req.NoError(err)
+
+	// the binding and cluster role are deleted with the last operator
+	otherOptions := types.DeployOptions{Namespace: "other", MinimalRBAC: true}
+	report, err = checkMinimalRBAC(clientset, otherOptions)
+	req.NoError(err)
+	req.Equal([]string{"ClusterRoleBinding kotsadm-operator-rolebinding", "ClusterRole kotsadm-operator-role"}, report.ClusterRBAC)
+
+	req.NoError(ensureMinimalOperatorRBAC(otherOptions, clientset))
+	_, err = clientset.RbacV1().ClusterRoleBindings().Get("kotsadm-operator-rolebinding", metav1.GetOptions{})
+	req.True(kuberneteserrors.IsNotFound(err))
+	_, err = clientset.RbacV1().ClusterRoles().Get("kotsadm-operator-role", metav1.GetOptions{})
+	req.True(kuberneteserrors.IsNotFound(err))
+
+	for _, namespace := range []string{"test", "app"} {
+		role, err := clientset.RbacV1().Roles(namespace).Get("kotsadm-operator-role", metav1.GetOptions{})
+		req.NoError(err)
+		req.Equal(minimalOperatorRules, role.Rules)
+
+		roleBinding, err := clientset.RbacV1().RoleBindings(namespace).Get("kotsadm-operator-rolebinding", metav1.GetOptions{})
+		req.NoError(err)
+		req.Equal([]rbacv1.Subject{{Kind: "ServiceAccount", Name: "kotsadm-operator", Namespace: "test"}}, roleBinding.Subjects)
+	}
+
+	// the mode is read back on upgrade, and the roles in the app namespaces are uninstalled
+	appNamespaces, err := getMinimalRBACNamespaces("test", clientset)
+	req.NoError(err)
+	req.Equal([]string{"app"}, appNamespaces)
+
+	resources, err := PlanUninstall(clientset, types.UninstallOptions{Namespace: "test"})
+	req.NoError(err)
+	req.Contains(resources, UninstallResource{Kind: "RoleBinding", Namespace: "app", Name: "kotsadm-operator-rolebinding", Action: UninstallDelete})
+	req.Contains(resources, UninstallResource{Kind: "Role", Namespace: "app", Name: "kotsadm-operator-role", Action: UninstallDelete})
+
+	appNamespaces, err = getMinimalRBACNamespaces("not-installed", clientset)
+	req.NoError(err)
+	req.Nil(appNamespaces)
+}
+
+func Test_YAMLWithMinimalRBAC(t *testing.T) {
+	req := require.New(t)
+
+	docs, err := YAML(types.DeployOptions{
+		Namespace:     "test",
+		MinimalRBAC:   true,
+		AppNamespaces: []string{"app"},
+	})
+	req.NoError(err)
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+	for _, name := range []string{"operator-role.yaml", "operator-role-app.yaml"} {
+		obj, _, err := decode(docs[name], nil, nil)
+		req.NoError(err)
+		req.Equal(minimalOperatorRules, obj.(*rbacv1.Role).Rules)
+	}
+
+	obj, _, err := decode(docs["operator-rolebinding-app.yaml"], nil, nil)
+	req.NoError(err)
+	roleBinding := obj.(*rbacv1.RoleBinding)
+	req.Equal("app", roleBinding.Namespace)
+	req.Equal("test", roleBinding.Subjects[0].Namespace)
+
+	_, ok := docs["rbac-configmap.yaml"]
+	req.True(ok)
+}
+
+func Test_ensureAppNamespaces(t *testing.T) {
+	req := require.New(t)
+
+	clientset := fake.NewSimpleClientset(&corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "existing"}})
+	clientset.PrependReactor("create", "namespaces", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, kuberneteserrors.NewForbidden(corev1.Resource("namespaces"), "", errors.New("not allowed"))
+	})
+
+	err := ensureAppNamespaces(types.DeployOptions{Namespace: "test", AppNamespaces: []string{"existing"}}, clientset)
+	req.NoError(err)
+
+	err = ensureAppNamespaces(types.DeployOptions{Namespace: "test", AppNamespaces: []string{"existing", "missing"}}, clientset)
+	req.Error(err)
+	req.Contains(err.Error(), "namespace missing does not exist and could not be created")
+}
@@ -32,7 +32,7 @@ func getMinioYAML(deployOptions types.DeployOptions) (map[string][]byte, error)
 }
 
 func ensureMinio(deployOptions types.DeployOptions, clientset *kubernetes.Clientset) error {
-	if err := ensureS3Secret(deployOptions.Namespace, clientset); err != nil {
+	if err := ensureS3Secret(deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure minio secret")
 	}
 
@@ -48,7 +48,7 @@ func ensureMinio(deployOptions types.DeployOptions, clientset *kubernetes.Client
 }
 
 func ensureMinioStatefulset(deployOptions types.DeployOptions, clientset *kubernetes.Clientset) error {
-	_, err := clientset.AppsV1().StatefulSets(deployOptions.Namespace).Get("kotsadm-minio", metav1.GetOptions{})
+	existingStatefulset, err := clientset.AppsV1().StatefulSets(deployOptions.Namespace).Get("kotsadm-minio", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get existing statefulset")
@@ -58,6 +58,16 @@ func ensureMinioStatefulset(deployOptions types.DeployOptions, clientset *kubern
 		if err != nil {
 			return errors.Wrap(err, "failed to create minio statefulset")
 		}
+
+		return nil
+	}
+
+	// the volume claim templates can't be changed, so the storage settings are only used on create
+	updateComponentSettings(&existingStatefulset.Spec.Template.Spec, "kotsadm-minio", deployOptions.PreviousSettings.Minio, deployOptions.Settings.Minio)
+
+	_, err = clientset.AppsV1().StatefulSets(deployOptions.Namespace).Update(existingStatefulset)
+	if err != nil {
+		return errors.Wrap(err, "failed to update minio statefulset")
 	}
 
 	return nil
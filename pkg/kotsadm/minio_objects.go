@@ -14,7 +14,7 @@ import (
 )
 
 func minioStatefulset(deployOptions types.DeployOptions) *appsv1.StatefulSet {
-	size := resource.MustParse("4Gi")
+	size := storageSize(deployOptions.Settings.Minio, "4Gi")
 
 	if deployOptions.LimitRange != nil {
 		var allowedMax *resource.Quantity
@@ -104,6 +104,7 @@ func minioStatefulset(deployOptions types.DeployOptions) *appsv1.StatefulSet {
 						},
 					},
 					Spec: corev1.PersistentVolumeClaimSpec{
+						StorageClassName: storageClassName(deployOptions.Settings.Minio),
 						AccessModes: []corev1.PersistentVolumeAccessMode{
 							corev1.ReadWriteOnce,
 						},
@@ -230,6 +231,8 @@ func minioStatefulset(deployOptions types.DeployOptions) *appsv1.StatefulSet {
 		},
 	}
 
+	applyComponentSettings(&statefulset.Spec.Template.Spec, "kotsadm-minio", deployOptions.Settings.Minio)
+
 	return statefulset
 }
 
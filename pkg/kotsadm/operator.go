@@ -26,17 +26,44 @@ func getOperatorYAML(deployOptions types.DeployOptions) (map[string][]byte, erro
 	docs := map[string][]byte{}
 	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
 
-	var role bytes.Buffer
-	if err := s.Encode(operatorRole(deployOptions.Namespace), &role); err != nil {
-		return nil, errors.Wrap(err, "failed to marshal operator role")
-	}
-	docs["operator-role.yaml"] = role.Bytes()
+	if deployOptions.MinimalRBAC {
+		for _, namespace := range minimalRBACNamespaces(deployOptions) {
+			suffix := ""
+			if namespace != deployOptions.Namespace {
+				suffix = "-" + namespace
+			}
+
+			var role bytes.Buffer
+			if err := s.Encode(operatorMinimalRole(namespace), &role); err != nil {
+				return nil, errors.Wrapf(err, "failed to marshal operator role in namespace %s", namespace)
+			}
+			docs["operator-role"+suffix+".yaml"] = role.Bytes()
+
+			var roleBinding bytes.Buffer
+			if err := s.Encode(operatorAppRoleBinding(namespace, deployOptions.Namespace), &roleBinding); err != nil {
+				return nil, errors.Wrapf(err, "failed to marshal operator role binding in namespace %s", namespace)
+			}
+			docs["operator-rolebinding"+suffix+".yaml"] = roleBinding.Bytes()
+		}
+
+		var rbac bytes.Buffer
+		if err := s.Encode(rbacConfigMap(deployOptions), &rbac); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal rbac config map")
+		}
+		docs["rbac-configmap.yaml"] = rbac.Bytes()
+	} else {
+		var role bytes.Buffer
+		if err := s.Encode(operatorRole(deployOptions.Namespace), &role); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal operator role")
+		}
+		docs["operator-role.yaml"] = role.Bytes()
 
-	var roleBinding bytes.Buffer
-	if err := s.Encode(operatorRoleBinding(deployOptions.Namespace), &roleBinding); err != nil {
-		return nil, errors.Wrap(err, "failed to marshal operator role binding")
+		var roleBinding bytes.Buffer
+		if err := s.Encode(operatorRoleBinding(deployOptions.Namespace), &roleBinding); err != nil {
+			return nil, errors.Wrap(err, "failed to marshal operator role binding")
+		}
+		docs["operator-rolebinding.yaml"] = roleBinding.Bytes()
 	}
-	docs["operator-rolebinding.yaml"] = roleBinding.Bytes()
 
 	var serviceAccount bytes.Buffer
 	if err := s.Encode(operatorServiceAccount(deployOptions.Namespace), &serviceAccount); err != nil {
@@ -54,13 +81,23 @@ func getOperatorYAML(deployOptions types.DeployOptions) (map[string][]byte, erro
 }
 
 func ensureOperator(deployOptions types.DeployOptions, clientset *kubernetes.Clientset) error {
-	rules, err := k8sutil.GetCurrentRules(deployOptions, clientset)
-	if err != nil {
-		return errors.Wrap(err, "failed to get current rules")
-	}
+	if deployOptions.MinimalRBAC {
+		if err := ensureMinimalOperatorRBAC(deployOptions, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure minimal operator rbac")
+		}
 
-	if err := ensureOperatorRBAC(deployOptions.Namespace, clientset, rules); err != nil {
-		return errors.Wrap(err, "failed to ensure operator rbac")
+		if err := ensureOperatorServiceAccount(deployOptions.Namespace, clientset); err != nil {
+			return errors.Wrap(err, "failed to ensure operator service account")
+		}
+	} else {
+		rules, err := k8sutil.GetCurrentRules(deployOptions, clientset)
+		if err != nil {
+			return errors.Wrap(err, "failed to get current rules")
+		}
+
+		if err := ensureOperatorRBAC(deployOptions.Namespace, clientset, rules); err != nil {
+			return errors.Wrap(err, "failed to ensure operator rbac")
+		}
 	}
 
 	if err := ensureOperatorDeployment(deployOptions, clientset); err != nil {
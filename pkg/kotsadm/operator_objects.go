@@ -93,6 +93,11 @@ func operatorRole(namespace string) *rbacv1.Role {
 }
 
 func operatorRoleBinding(namespace string) *rbacv1.RoleBinding {
+	return operatorAppRoleBinding(namespace, namespace)
+}
+
+// operatorAppRoleBinding binds the operator in namespace to its role in appNamespace
+func operatorAppRoleBinding(appNamespace string, namespace string) *rbacv1.RoleBinding {
 	roleBinding := &rbacv1.RoleBinding{
 		TypeMeta: metav1.TypeMeta{
 			APIVersion: "rbac.authorization.k8s.io/v1",
@@ -100,7 +105,7 @@ func operatorRoleBinding(namespace string) *rbacv1.RoleBinding {
 		},
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      "kotsadm-operator-rolebinding",
-			Namespace: namespace,
+			Namespace: appNamespace,
 			Labels: map[string]string{
 				types.KotsadmKey: types.KotsadmLabelValue,
 			},
@@ -197,6 +202,9 @@ func updateOperatorDeployment(deployment *appsv1.Deployment, deployOptions types
 	}
 	deployment.Spec.Template.Spec.Containers[containerIdx].Env = mergedEnvs
 
+	// scheduling and resources (added with the settings file)
+	updateComponentSettings(&deployment.Spec.Template.Spec, "kotsadm-operator", deployOptions.PreviousSettings.Operator, deployOptions.Settings.Operator)
+
 	return nil
 }
 
@@ -267,5 +275,7 @@ func operatorDeployment(deployOptions types.DeployOptions) *appsv1.Deployment {
 		},
 	}
 
+	applyComponentSettings(&deployment.Spec.Template.Spec, "kotsadm-operator", deployOptions.Settings.Operator)
+
 	return deployment
 }
@@ -52,7 +52,7 @@ func ensurePostgres(deployOptions types.DeployOptions, clientset *kubernetes.Cli
 }
 
 func ensurePostgresStatefulset(deployOptions types.DeployOptions, clientset *kubernetes.Clientset) error {
-	_, err := clientset.AppsV1().StatefulSets(deployOptions.Namespace).Get("kotsadm-postgres", metav1.GetOptions{})
+	existingStatefulset, err := clientset.AppsV1().StatefulSets(deployOptions.Namespace).Get("kotsadm-postgres", metav1.GetOptions{})
 	if err != nil {
 		if !kuberneteserrors.IsNotFound(err) {
 			return errors.Wrap(err, "failed to get existing statefulset")
@@ -62,6 +62,16 @@ func ensurePostgresStatefulset(deployOptions types.DeployOptions, clientset *kub
 		if err != nil {
 			return errors.Wrap(err, "failed to create postgres statefulset")
 		}
+
+		return nil
+	}
+
+	// the volume claim templates can't be changed, so the storage settings are only used on create
+	updateComponentSettings(&existingStatefulset.Spec.Template.Spec, "kotsadm-postgres", deployOptions.PreviousSettings.Postgres, deployOptions.Settings.Postgres)
+
+	_, err = clientset.AppsV1().StatefulSets(deployOptions.Namespace).Update(existingStatefulset)
+	if err != nil {
+		return errors.Wrap(err, "failed to update postgres statefulset")
 	}
 
 	return nil
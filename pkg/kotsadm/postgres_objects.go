@@ -13,7 +13,7 @@ import (
 )
 
 func postgresStatefulset(deployOptions types.DeployOptions) *appsv1.StatefulSet {
-	size := resource.MustParse("1Gi")
+	size := storageSize(deployOptions.Settings.Postgres, "1Gi")
 
 	if deployOptions.LimitRange != nil {
 		var allowedMax *resource.Quantity
@@ -76,6 +76,7 @@ func postgresStatefulset(deployOptions types.DeployOptions) *appsv1.StatefulSet
 						},
 					},
 					Spec: corev1.PersistentVolumeClaimSpec{
+						StorageClassName: storageClassName(deployOptions.Settings.Postgres),
 						AccessModes: []corev1.PersistentVolumeAccessMode{
 							corev1.ReadWriteOnce,
 						},
@@ -185,6 +186,8 @@ func postgresStatefulset(deployOptions types.DeployOptions) *appsv1.StatefulSet
 		},
 	}
 
+	applyComponentSettings(&statefulset.Spec.Template.Spec, "kotsadm-postgres", deployOptions.Settings.Postgres)
+
 	return statefulset
 }
 
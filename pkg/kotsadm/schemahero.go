@@ -31,14 +31,16 @@ func runSchemaHeroMigrations(deployOptions types.DeployOptions, clientset *kuber
 	// we don't deploy the operator because that would require too high of
 	// a priv. so we just deploy database migrations here, at deployment time
 
-	// find a ready postgres container
-	log := logger.NewLogger()
-	log.ChildActionWithSpinner("Waiting for datastore to be ready")
-	_, err := waitForHealthyPostgres(deployOptions.Namespace, clientset)
-	if err != nil {
-		return errors.Wrap(err, "failed to find healthy postgres pod")
+	// find a ready postgres container, unless the database is external
+	if deployOptions.ExternalPostgresURI == "" {
+		log := logger.NewLogger()
+		log.ChildActionWithSpinner("Waiting for datastore to be ready")
+		_, err := waitForHealthyPostgres(deployOptions.Namespace, clientset)
+		if err != nil {
+			return errors.Wrap(err, "failed to find healthy postgres pod")
+		}
+		log.FinishChildSpinner()
 	}
-	log.FinishChildSpinner()
 
 	// Deploy the migration pod with an informer attached to clean it up
 	if err := createSchemaHeroPod(deployOptions, clientset); err != nil {
@@ -28,8 +28,12 @@ func getSecretsYAML(deployOptions *types.DeployOptions) (map[string][]byte, erro
 	}
 	docs["secret-jwt.yaml"] = jwt.Bytes()
 
+	postgresSecret := pgSecret(deployOptions.Namespace, deployOptions.PostgresPassword)
+	if deployOptions.ExternalPostgresURI != "" {
+		postgresSecret = externalPgSecret(deployOptions.Namespace, deployOptions.ExternalPostgresURI)
+	}
 	var pg bytes.Buffer
-	if err := s.Encode(pgSecret(deployOptions.Namespace, deployOptions.PostgresPassword), &pg); err != nil {
+	if err := s.Encode(postgresSecret, &pg); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal pg secret")
 	}
 	docs["secret-pg.yaml"] = pg.Bytes()
@@ -67,7 +71,11 @@ func getSecretsYAML(deployOptions *types.DeployOptions) (map[string][]byte, erro
 	if deployOptions.S3AccessKey == "" {
 		deployOptions.S3AccessKey = uuid.New().String()
 	}
-	if err := s.Encode(s3Secret(deployOptions.Namespace, deployOptions.S3AccessKey, deployOptions.S3SecretKey), &s3); err != nil {
+	objectStoreSecret := s3Secret(deployOptions.Namespace, deployOptions.S3AccessKey, deployOptions.S3SecretKey)
+	if deployOptions.ExternalS3Endpoint != "" {
+		objectStoreSecret = externalS3Secret(*deployOptions)
+	}
+	if err := s.Encode(objectStoreSecret, &s3); err != nil {
 		return nil, errors.Wrap(err, "failed to marshal s3 secret")
 	}
 	docs["secret-s3.yaml"] = s3.Bytes()
@@ -90,7 +98,7 @@ func ensureSecrets(deployOptions *types.DeployOptions, clientset *kubernetes.Cli
 		}
 	}
 
-	if err := ensureS3Secret(deployOptions.Namespace, clientset); err != nil {
+	if err := ensureS3Secret(*deployOptions, clientset); err != nil {
 		return errors.Wrap(err, "failed to ensure s3 secret")
 	}
 
@@ -114,12 +122,17 @@ func getS3Secret(namespace string, clientset *kubernetes.Clientset) (*corev1.Sec
 	return s3Secret, nil
 }
 
-func ensureS3Secret(namespace string, clientset *kubernetes.Clientset) error {
+func ensureS3Secret(deployOptions types.DeployOptions, clientset *kubernetes.Clientset) error {
+	namespace := deployOptions.Namespace
 	existingS3Secret, err := getS3Secret(namespace, clientset)
 	if err != nil {
 		return errors.Wrap(err, "failed to check for existing s3 secret")
 	}
 
+	if deployOptions.ExternalS3Endpoint != "" {
+		return ensureExternalSecret(externalS3Secret(deployOptions), existingS3Secret, clientset)
+	}
+
 	if existingS3Secret == nil {
 		_, err := clientset.CoreV1().Secrets(namespace).Create(s3Secret(namespace, uuid.New().String(), uuid.New().String()))
 		if err != nil {
@@ -178,6 +191,10 @@ func ensurePostgresSecret(deployOptions types.DeployOptions, clientset *kubernet
 		return errors.Wrap(err, "failed to check for existing postgres secret")
 	}
 
+	if deployOptions.ExternalPostgresURI != "" {
+		return ensureExternalSecret(externalPgSecret(deployOptions.Namespace, deployOptions.ExternalPostgresURI), existingPgSecret, clientset)
+	}
+
 	if existingPgSecret == nil {
 		_, err := clientset.CoreV1().Secrets(deployOptions.Namespace).Create(pgSecret(deployOptions.Namespace, deployOptions.PostgresPassword))
 		if err != nil {
@@ -188,6 +205,24 @@ func ensurePostgresSecret(deployOptions types.DeployOptions, clientset *kubernet
 	return nil
 }
 
+// ensureExternalSecret creates the secret of an external datastore, or replaces the data of the existing
+// secret so that changed settings, or a move from the bundled datastore, are applied
+func ensureExternalSecret(secret *corev1.Secret, existingSecret *corev1.Secret, clientset kubernetes.Interface) error {
+	if existingSecret == nil {
+		if _, err := clientset.CoreV1().Secrets(secret.Namespace).Create(secret); err != nil {
+			return errors.Wrapf(err, "failed to create %s secret", secret.Name)
+		}
+		return nil
+	}
+
+	existingSecret.Data = secret.Data
+	if _, err := clientset.CoreV1().Secrets(secret.Namespace).Update(existingSecret); err != nil {
+		return errors.Wrapf(err, "failed to update %s secret", secret.Name)
+	}
+
+	return nil
+}
+
 func getSharedPasswordSecret(namespace string, clientset *kubernetes.Clientset) (*corev1.Secret, error) {
 	sharedPasswordSecret, err := clientset.CoreV1().Secrets(namespace).Get("kotsadm-password", metav1.GetOptions{})
 	if err != nil {
@@ -60,6 +60,29 @@ func pgSecret(namespace string, password string) *corev1.Secret {
 	return secret
 }
 
+// externalPgSecret has the uri of an external database. It doesn't have a password, which is how
+// an upgrade knows that postgres is external.
+func externalPgSecret(namespace string, uri string) *corev1.Secret {
+	secret := &corev1.Secret{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "Secret",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "kotsadm-postgres",
+			Namespace: namespace,
+			Labels: map[string]string{
+				types.KotsadmKey: types.KotsadmLabelValue,
+			},
+		},
+		Data: map[string][]byte{
+			"uri": []byte(uri),
+		},
+	}
+
+	return secret
+}
+
 func sharedPasswordSecret(namespace string, bcryptPassword string) *corev1.Secret {
 	secret := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
@@ -103,6 +126,16 @@ func s3Secret(namespace string, accessKey string, secretKey string) *corev1.Secr
 	return secret
 }
 
+// externalS3Secret has the endpoint, bucket and credentials of an external object store. It keeps the
+// name of the minio secret so that the api reads the credentials from the same secret.
+func externalS3Secret(deployOptions types.DeployOptions) *corev1.Secret {
+	secret := s3Secret(deployOptions.Namespace, deployOptions.S3AccessKey, deployOptions.S3SecretKey)
+	secret.Data["endpoint"] = []byte(deployOptions.ExternalS3Endpoint)
+	secret.Data["bucket"] = []byte(deployOptions.ExternalS3Bucket)
+
+	return secret
+}
+
 func apiEncryptionKeySecret(namespace string, key string) *corev1.Secret {
 	secret := &corev1.Secret{
 		TypeMeta: metav1.TypeMeta{
@@ -0,0 +1,206 @@
+package kotsadm
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/kotsadm/types"
+	corev1 "k8s.io/api/core/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime/serializer/json"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	settingsConfigMapName = "kotsadm-settings"
+	settingsKey           = "settings.yaml"
+)
+
+// ParseSettings parses an admin console settings file
+func ParseSettings(data []byte) (*types.AdminConsoleSettings, error) {
+	settings := types.AdminConsoleSettings{}
+	if err := yaml.UnmarshalStrict(data, &settings); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal settings")
+	}
+
+	components := map[string]types.ComponentSettings{
+		"kotsadm":  settings.Kotsadm,
+		"api":      settings.API,
+		"operator": settings.Operator,
+	}
+	for name, component := range components {
+		if component.Storage != nil {
+			return nil, errors.Errorf("%s does not have storage", name)
+		}
+	}
+
+	return &settings, nil
+}
+
+func getSettingsYAML(deployOptions types.DeployOptions) (map[string][]byte, error) {
+	docs := map[string][]byte{}
+	s := json.NewYAMLSerializer(json.DefaultMetaFactory, scheme.Scheme, scheme.Scheme)
+
+	configMap, err := settingsConfigMap(deployOptions.Namespace, deployOptions.Settings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build settings config map")
+	}
+
+	var settings bytes.Buffer
+	if err := s.Encode(configMap, &settings); err != nil {
+		return nil, errors.Wrap(err, "failed to marshal settings config map")
+	}
+	docs["settings-configmap.yaml"] = settings.Bytes()
+
+	return docs, nil
+}
+
+func settingsConfigMap(namespace string, settings types.AdminConsoleSettings) (*corev1.ConfigMap, error) {
+	data, err := yaml.Marshal(settings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal settings")
+	}
+
+	configMap := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{
+			APIVersion: "v1",
+			Kind:       "ConfigMap",
+		},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      settingsConfigMapName,
+			Namespace: namespace,
+			Labels: map[string]string{
+				types.KotsadmKey: types.KotsadmLabelValue,
+			},
+		},
+		Data: map[string]string{
+			settingsKey: string(data),
+		},
+	}
+
+	return configMap, nil
+}
+
+// ensureSettings stores the settings in the cluster, so they are used again on upgrade. The settings that
+// were stored before are returned, or nil if there were none.
+func ensureSettings(deployOptions types.DeployOptions, clientset kubernetes.Interface) (*types.AdminConsoleSettings, error) {
+	desired, err := settingsConfigMap(deployOptions.Namespace, deployOptions.Settings)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build settings config map")
+	}
+
+	existing, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Get(settingsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if !kuberneteserrors.IsNotFound(err) {
+			return nil, errors.Wrap(err, "failed to get existing settings config map")
+		}
+
+		_, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Create(desired)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create settings config map")
+		}
+
+		return nil, nil
+	}
+
+	previous, err := ParseSettings([]byte(existing.Data[settingsKey]))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse stored settings")
+	}
+
+	existing.Data = desired.Data
+	if _, err := clientset.CoreV1().ConfigMaps(deployOptions.Namespace).Update(existing); err != nil {
+		return nil, errors.Wrap(err, "failed to update settings config map")
+	}
+
+	return previous, nil
+}
+
+// getSettings returns the settings that are stored in the cluster, or nil if there are none
+func getSettings(namespace string, clientset kubernetes.Interface) (*types.AdminConsoleSettings, error) {
+	configMap, err := clientset.CoreV1().ConfigMaps(namespace).Get(settingsConfigMapName, metav1.GetOptions{})
+	if err != nil {
+		if kuberneteserrors.IsNotFound(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to get settings config map")
+	}
+
+	settings, err := ParseSettings([]byte(configMap.Data[settingsKey]))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse stored settings")
+	}
+
+	return settings, nil
+}
+
+// applyComponentSettings sets the scheduling of the pod, and the resources of the named container
+func applyComponentSettings(podSpec *corev1.PodSpec, containerName string, settings types.ComponentSettings) {
+	if settings.NodeSelector != nil {
+		podSpec.NodeSelector = settings.NodeSelector
+	}
+	if settings.Tolerations != nil {
+		podSpec.Tolerations = settings.Tolerations
+	}
+	if settings.Affinity != nil {
+		podSpec.Affinity = settings.Affinity
+	}
+	if settings.PriorityClassName != "" {
+		podSpec.PriorityClassName = settings.PriorityClassName
+	}
+
+	if settings.Resources == nil {
+		return
+	}
+	for idx, c := range podSpec.Containers {
+		if c.Name == containerName {
+			podSpec.Containers[idx].Resources = *settings.Resources
+		}
+	}
+}
+
+// updateComponentSettings applies the settings to the pod of a component that already exists. The fields
+// that were set by the previous settings and are not in the settings anymore are cleared, and other fields
+// that are not in the settings are kept.
+func updateComponentSettings(podSpec *corev1.PodSpec, containerName string, previous types.ComponentSettings, settings types.ComponentSettings) {
+	if previous.NodeSelector != nil && settings.NodeSelector == nil {
+		podSpec.NodeSelector = nil
+	}
+	if previous.Tolerations != nil && settings.Tolerations == nil {
+		podSpec.Tolerations = nil
+	}
+	if previous.Affinity != nil && settings.Affinity == nil {
+		podSpec.Affinity = nil
+	}
+	if previous.PriorityClassName != "" && settings.PriorityClassName == "" {
+		podSpec.PriorityClassName = ""
+	}
+	if previous.Resources != nil && settings.Resources == nil {
+		for idx, c := range podSpec.Containers {
+			if c.Name == containerName {
+				podSpec.Containers[idx].Resources = corev1.ResourceRequirements{}
+			}
+		}
+	}
+
+	applyComponentSettings(podSpec, containerName, settings)
+}
+
+// storageSize is the size of the volume of a statefulset, before the limit range is applied
+func storageSize(settings types.ComponentSettings, defaultSize string) resource.Quantity {
+	if settings.Storage != nil && settings.Storage.Size != nil {
+		return *settings.Storage.Size
+	}
+	return resource.MustParse(defaultSize)
+}
+
+func storageClassName(settings types.ComponentSettings) *string {
+	if settings.Storage == nil || settings.Storage.StorageClassName == "" {
+		return nil
+	}
+	return &settings.Storage.StorageClassName
+}
@@ -0,0 +1,199 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/kotsadm/types"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func Test_ParseSettings(t *testing.T) {
+	tests := []struct {
+		name      string
+		settings  string
+		expectErr bool
+	}{
+		{
+			name: "scheduling, resources and storage",
+			settings: `api:
+  nodeSelector:
+    disktype: ssd
+  resources:
+    limits:
+      memory: 512Mi
+postgres:
+  priorityClassName: high
+  storage:
+    storageClassName: fast
+    size: 10Gi`,
+		},
+		{
+			name: "storage on a deployment",
+			settings: `kotsadm:
+  storage:
+    size: 10Gi`,
+			expectErr: true,
+		},
+		{
+			name: "unknown field",
+			settings: `api:
+  nodeSelecter:
+    disktype: ssd`,
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			_, err := ParseSettings([]byte(test.settings))
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+		})
+	}
+}
+
+func Test_YAMLWithSettings(t *testing.T) {
+	req := require.New(t)
+
+	settings, err := ParseSettings([]byte(`api:
+  nodeSelector:
+    disktype: ssd
+  tolerations:
+  - key: dedicated
+    operator: Equal
+    value: kotsadm
+    effect: NoSchedule
+  resources:
+    limits:
+      memory: 512Mi
+postgres:
+  priorityClassName: high
+  storage:
+    storageClassName: fast
+    size: 10Gi`))
+	req.NoError(err)
+
+	docs, err := YAML(types.DeployOptions{Namespace: "default", Settings: *settings})
+	req.NoError(err)
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+
+	obj, _, err := decode(docs["api-deployment.yaml"], nil, nil)
+	req.NoError(err)
+	podSpec := obj.(*appsv1.Deployment).Spec.Template.Spec
+	req.Equal(map[string]string{"disktype": "ssd"}, podSpec.NodeSelector)
+	req.Len(podSpec.Tolerations, 1)
+	req.Equal(resource.MustParse("512Mi"), podSpec.Containers[0].Resources.Limits[corev1.ResourceMemory])
+
+	obj, _, err = decode(docs["postgres-statefulset.yaml"], nil, nil)
+	req.NoError(err)
+	statefulset := obj.(*appsv1.StatefulSet)
+	req.Equal("high", statefulset.Spec.Template.Spec.PriorityClassName)
+	claim := statefulset.Spec.VolumeClaimTemplates[0]
+	req.Equal("fast", *claim.Spec.StorageClassName)
+	req.Equal(resource.MustParse("10Gi"), claim.Spec.Resources.Requests[corev1.ResourceStorage])
+
+	obj, _, err = decode(docs["minio-statefulset.yaml"], nil, nil)
+	req.NoError(err)
+	claim = obj.(*appsv1.StatefulSet).Spec.VolumeClaimTemplates[0]
+	req.Nil(claim.Spec.StorageClassName)
+	req.Equal(resource.MustParse("4Gi"), claim.Spec.Resources.Requests[corev1.ResourceStorage])
+
+	obj, _, err = decode(docs["settings-configmap.yaml"], nil, nil)
+	req.NoError(err)
+	stored, err := ParseSettings([]byte(obj.(*corev1.ConfigMap).Data[settingsKey]))
+	req.NoError(err)
+	req.Equal(settings.API.NodeSelector, stored.API.NodeSelector)
+}
+
+func Test_ensureSettings(t *testing.T) {
+	req := require.New(t)
+
+	clientset := fake.NewSimpleClientset()
+
+	settings, err := getSettings("test", clientset)
+	req.NoError(err)
+	req.Nil(settings)
+
+	deployOptions := types.DeployOptions{Namespace: "test"}
+	deployOptions.Settings.Operator.PriorityClassName = "high"
+	previous, err := ensureSettings(deployOptions, clientset)
+	req.NoError(err)
+	req.Nil(previous)
+
+	deployOptions.Settings.Operator.PriorityClassName = "low"
+	previous, err = ensureSettings(deployOptions, clientset)
+	req.NoError(err)
+	req.Equal("high", previous.Operator.PriorityClassName)
+
+	settings, err = getSettings("test", clientset)
+	req.NoError(err)
+	req.Equal("low", settings.Operator.PriorityClassName)
+}
+
+func Test_applyComponentSettings(t *testing.T) {
+	req := require.New(t)
+
+	podSpec := corev1.PodSpec{
+		NodeSelector: map[string]string{"existing": "true"},
+		Containers: []corev1.Container{
+			{Name: "sidecar"},
+			{Name: "kotsadm-api"},
+		},
+	}
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+	}
+
+	applyComponentSettings(&podSpec, "kotsadm-api", types.ComponentSettings{
+		Resources:         &resources,
+		PriorityClassName: "high",
+	})
+
+	// fields that are not in the settings are kept
+	req.Equal(map[string]string{"existing": "true"}, podSpec.NodeSelector)
+	req.Equal("high", podSpec.PriorityClassName)
+	req.Empty(podSpec.Containers[0].Resources.Requests)
+	req.Equal(resources, podSpec.Containers[1].Resources)
+}
+
+func Test_updateComponentSettings(t *testing.T) {
+	req := require.New(t)
+
+	resources := corev1.ResourceRequirements{
+		Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")},
+	}
+	podSpec := corev1.PodSpec{
+		NodeSelector:      map[string]string{"disktype": "ssd"},
+		Tolerations:       []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+		PriorityClassName: "edited-by-hand",
+		Containers: []corev1.Container{
+			{Name: "kotsadm-api", Resources: resources},
+		},
+	}
+
+	previous := types.ComponentSettings{
+		NodeSelector: map[string]string{"disktype": "ssd"},
+		Tolerations:  []corev1.Toleration{{Key: "dedicated", Operator: corev1.TolerationOpExists}},
+		Resources:    &resources,
+	}
+	updateComponentSettings(&podSpec, "kotsadm-api", previous, types.ComponentSettings{
+		NodeSelector: map[string]string{"disktype": "hdd"},
+	})
+
+	// the fields that were removed from the settings are cleared, and fields that were not set by them are kept
+	req.Equal(map[string]string{"disktype": "hdd"}, podSpec.NodeSelector)
+	req.Nil(podSpec.Tolerations)
+	req.Equal("edited-by-hand", podSpec.PriorityClassName)
+	req.Equal(corev1.ResourceRequirements{}, podSpec.Containers[0].Resources)
+}
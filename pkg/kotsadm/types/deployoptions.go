@@ -23,5 +23,13 @@ type DeployOptions struct {
 	Hostname               string
 	ApplicationMetadata    []byte
 	LimitRange             *corev1.LimitRange
-	IsOpenShift            bool // true if the application is being deployed to an OpenShift cluster
+	IsOpenShift            bool                 // true if the application is being deployed to an OpenShift cluster
+	ExternalPostgresURI    string               // when set, this database is used instead of deploying postgres
+	ExternalS3Endpoint     string               // when set, this object store is used instead of deploying minio, with the s3 access and secret keys
+	ExternalS3Bucket       string               // the bucket in the external object store
+	Settings               AdminConsoleSettings // scheduling and resources of the admin console components
+	PreviousSettings       AdminConsoleSettings // the settings that were stored in the cluster before, so the fields that were removed from them are cleared on upgrade
+	Ingress                IngressOptions       // when enabled, the admin console is exposed with an ingress instead of only with port forwarding
+	MinimalRBAC            bool                 // when true, only namespaced roles with a fixed set of permissions are created for the operator
+	AppNamespaces          []string             // the namespaces that the application is deployed to in addition to Namespace, with MinimalRBAC
 }
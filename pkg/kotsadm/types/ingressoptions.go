@@ -0,0 +1,10 @@
+package types
+
+type IngressOptions struct {
+	Enabled      bool
+	Host         string
+	IngressClass string            // set as the kubernetes.io/ingress.class annotation
+	Annotations  map[string]string // added to the ingress, for the configuration of the ingress controller
+	TLSCert      []byte            // PEM encoded certificate, a self-signed certificate is generated when this and TLSKey are empty
+	TLSKey       []byte            // PEM encoded private key of TLSCert
+}
@@ -0,0 +1,34 @@
+package types
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// AdminConsoleSettings are the scheduling and resource settings of the admin console components
+type AdminConsoleSettings struct {
+	Kotsadm  ComponentSettings `json:"kotsadm,omitempty"`
+	API      ComponentSettings `json:"api,omitempty"`
+	Operator ComponentSettings `json:"operator,omitempty"`
+	Postgres ComponentSettings `json:"postgres,omitempty"`
+	Minio    ComponentSettings `json:"minio,omitempty"`
+}
+
+// ComponentSettings are applied to the pod of a component. Fields that are not set leave the
+// defaults, or the values that are already in the cluster, in place. Fields that were set by the
+// previous settings and are not set anymore are cleared on upgrade.
+type ComponentSettings struct {
+	Resources         *corev1.ResourceRequirements `json:"resources,omitempty"`
+	NodeSelector      map[string]string            `json:"nodeSelector,omitempty"`
+	Tolerations       []corev1.Toleration          `json:"tolerations,omitempty"`
+	Affinity          *corev1.Affinity             `json:"affinity,omitempty"`
+	PriorityClassName string                       `json:"priorityClassName,omitempty"`
+	Storage           *StorageSettings             `json:"storage,omitempty"`
+}
+
+// StorageSettings are the settings of the volume of a statefulset. They are only used when
+// the statefulset is created, because the volume claims can't be changed after that.
+type StorageSettings struct {
+	StorageClassName string             `json:"storageClassName,omitempty"`
+	Size             *resource.Quantity `json:"size,omitempty"`
+}
@@ -0,0 +1,9 @@
+package types
+
+type UninstallOptions struct {
+	Namespace       string
+	KeepPVCs        bool
+	KeepSecrets     bool
+	DeleteNamespace bool
+	DryRun          bool
+}
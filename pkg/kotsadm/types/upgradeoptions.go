@@ -3,4 +3,6 @@ package types
 type UpgradeOptions struct {
 	Namespace  string
 	Kubeconfig string
+	Settings   *AdminConsoleSettings // when set, replaces the settings that are stored in the cluster
+	Ingress    *IngressOptions       // when set, replaces the ingress that is read from the cluster
 }
@@ -0,0 +1,479 @@
+package kotsadm
+
+import (
+	"fmt"
+
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/kotsadm/types"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"sigs.k8s.io/controller-runtime/pkg/client/config"
+)
+
+// UninstallAction is what uninstalling the admin console does with a resource
+type UninstallAction string
+
+const (
+	UninstallDelete UninstallAction = "delete"
+	UninstallKeep   UninstallAction = "keep"
+	// UninstallUnbind removes the subjects in the namespace from a cluster role binding that
+	// also binds service accounts in other namespaces
+	UninstallUnbind UninstallAction = "unbind"
+)
+
+// UninstallResource is a resource of the admin console and what uninstall does with it
+type UninstallResource struct {
+	Kind      string
+	Namespace string
+	Name      string
+	Action    UninstallAction
+}
+
+func (r UninstallResource) String() string {
+	if r.Namespace == "" {
+		return fmt.Sprintf("%s/%s", r.Kind, r.Name)
+	}
+	return fmt.Sprintf("%s/%s/%s", r.Namespace, r.Kind, r.Name)
+}
+
+// namespacedKind lists and deletes the resources of a kind that are labelled as part of the admin console
+type namespacedKind struct {
+	kind   string
+	list   func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error)
+	delete func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error
+}
+
+// uninstallKinds are the namespaced kinds that the admin console creates, in the order they are deleted.
+// The operator is deleted first so that it doesn't recreate anything.
+var uninstallKinds = []namespacedKind{
+	{
+		kind: "Deployment",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.AppsV1().Deployments(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return operatorFirst(names), nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.AppsV1().Deployments(namespace).Delete(name, opts)
+		},
+	},
+	{
+		kind: "StatefulSet",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.AppsV1().StatefulSets(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.AppsV1().StatefulSets(namespace).Delete(name, opts)
+		},
+	},
+	{
+		kind: "Pod",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.CoreV1().Pods(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				// pods of the deployments and statefulsets are deleted with them
+				if len(item.OwnerReferences) > 0 {
+					continue
+				}
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.CoreV1().Pods(namespace).Delete(name, opts)
+		},
+	},
+	{
+		kind: "Service",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.CoreV1().Services(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.CoreV1().Services(namespace).Delete(name, opts)
+		},
+	},
+	{
+		kind: "Ingress",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.NetworkingV1beta1().Ingresses(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.NetworkingV1beta1().Ingresses(namespace).Delete(name, opts)
+		},
+	},
+	{
+		kind: "ConfigMap",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.CoreV1().ConfigMaps(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.CoreV1().ConfigMaps(namespace).Delete(name, opts)
+		},
+	},
+	{
+		kind: "Secret",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.CoreV1().Secrets(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.CoreV1().Secrets(namespace).Delete(name, opts)
+		},
+	},
+	{
+		kind: "PersistentVolumeClaim",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.CoreV1().PersistentVolumeClaims(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(name, opts)
+		},
+	},
+	{
+		kind: "RoleBinding",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.RbacV1().RoleBindings(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.RbacV1().RoleBindings(namespace).Delete(name, opts)
+		},
+	},
+	{
+		kind: "Role",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.RbacV1().Roles(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.RbacV1().Roles(namespace).Delete(name, opts)
+		},
+	},
+	{
+		kind: "ServiceAccount",
+		list: func(clientset kubernetes.Interface, namespace string, opts metav1.ListOptions) ([]string, error) {
+			list, err := clientset.CoreV1().ServiceAccounts(namespace).List(opts)
+			if err != nil {
+				return nil, err
+			}
+			names := []string{}
+			for _, item := range list.Items {
+				names = append(names, item.Name)
+			}
+			return names, nil
+		},
+		delete: func(clientset kubernetes.Interface, namespace string, name string, opts *metav1.DeleteOptions) error {
+			return clientset.CoreV1().ServiceAccounts(namespace).Delete(name, opts)
+		},
+	},
+}
+
+// operatorFirst moves the operator deployment to the front of the names
+func operatorFirst(names []string) []string {
+	sorted := []string{}
+	for _, name := range names {
+		if name == "kotsadm-operator" {
+			sorted = append([]string{name}, sorted...)
+		} else {
+			sorted = append(sorted, name)
+		}
+	}
+	return sorted
+}
+
+// Uninstall removes the admin console from the namespace, and returns the resources that were deleted,
+// kept or unbound. With a dry run, nothing is changed.
+func Uninstall(uninstallOptions types.UninstallOptions) ([]UninstallResource, error) {
+	cfg, err := config.GetConfig()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get cluster config")
+	}
+
+	clientset, err := kubernetes.NewForConfig(cfg)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create kubernetes clientset")
+	}
+
+	return uninstall(clientset, uninstallOptions)
+}
+
+func uninstall(clientset kubernetes.Interface, uninstallOptions types.UninstallOptions) ([]UninstallResource, error) {
+	resources, err := PlanUninstall(clientset, uninstallOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find admin console resources")
+	}
+
+	if uninstallOptions.DryRun {
+		return resources, nil
+	}
+
+	propagation := metav1.DeletePropagationBackground
+	deleteOptions := &metav1.DeleteOptions{PropagationPolicy: &propagation}
+
+	for _, resource := range resources {
+		var err error
+		switch {
+		case resource.Action == UninstallKeep:
+			continue
+		case resource.Action == UninstallUnbind:
+			err = unbindClusterRoleBinding(clientset, resource.Name, uninstallOptions.Namespace)
+		case resource.Kind == "ClusterRoleBinding":
+			err = clientset.RbacV1().ClusterRoleBindings().Delete(resource.Name, deleteOptions)
+		case resource.Kind == "ClusterRole":
+			err = clientset.RbacV1().ClusterRoles().Delete(resource.Name, deleteOptions)
+		case resource.Kind == "Namespace":
+			err = clientset.CoreV1().Namespaces().Delete(resource.Name, deleteOptions)
+		default:
+			for _, kind := range uninstallKinds {
+				if kind.kind == resource.Kind {
+					err = kind.delete(clientset, resource.Namespace, resource.Name, deleteOptions)
+					break
+				}
+			}
+		}
+		if err != nil && !kuberneteserrors.IsNotFound(err) {
+			return nil, errors.Wrapf(err, "failed to %s %s", resource.Action, resource)
+		}
+	}
+
+	return resources, nil
+}
+
+// PlanUninstall finds the resources that are labelled as part of the admin console in the namespace, and the
+// cluster roles and bindings of its operator, in the order they are deleted
+func PlanUninstall(clientset kubernetes.Interface, uninstallOptions types.UninstallOptions) ([]UninstallResource, error) {
+	if uninstallOptions.DeleteNamespace && (uninstallOptions.KeepPVCs || uninstallOptions.KeepSecrets) {
+		return nil, errors.New("the namespace can't be deleted when keeping persistent volume claims or secrets")
+	}
+
+	namespace := uninstallOptions.Namespace
+	listOptions := metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("%s=%s", types.KotsadmKey, types.KotsadmLabelValue),
+	}
+
+	resources := []UninstallResource{}
+	for _, kind := range uninstallKinds {
+		names, err := kind.list(clientset, namespace, listOptions)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to list %s", kind.kind)
+		}
+
+		action := UninstallDelete
+		if (kind.kind == "PersistentVolumeClaim" && uninstallOptions.KeepPVCs) || (kind.kind == "Secret" && uninstallOptions.KeepSecrets) {
+			action = UninstallKeep
+		}
+
+		for _, name := range names {
+			resources = append(resources, UninstallResource{
+				Kind:      kind.kind,
+				Namespace: namespace,
+				Name:      name,
+				Action:    action,
+			})
+		}
+	}
+
+	// with minimal rbac, the operator also has roles in the other app namespaces
+	appNamespaces, err := getMinimalRBACNamespaces(namespace, clientset)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find minimal rbac namespaces")
+	}
+	for _, appNamespace := range appNamespaces {
+		if appNamespace == namespace {
+			continue
+		}
+		for _, kind := range uninstallKinds {
+			if kind.kind != "RoleBinding" && kind.kind != "Role" {
+				continue
+			}
+			names, err := kind.list(clientset, appNamespace, listOptions)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to list %s in namespace %s", kind.kind, appNamespace)
+			}
+			for _, name := range names {
+				resources = append(resources, UninstallResource{
+					Kind:      kind.kind,
+					Namespace: appNamespace,
+					Name:      name,
+					Action:    UninstallDelete,
+				})
+			}
+		}
+	}
+
+	clusterResources, err := planClusterRBAC(clientset, namespace, listOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find cluster rbac")
+	}
+	resources = append(resources, clusterResources...)
+
+	if uninstallOptions.DeleteNamespace {
+		resources = append(resources, UninstallResource{
+			Kind:   "Namespace",
+			Name:   namespace,
+			Action: UninstallDelete,
+		})
+	}
+
+	return resources, nil
+}
+
+// planClusterRBAC finds the cluster role bindings of the admin console that bind service accounts in the
+// namespace. Bindings that also bind other namespaces are unbound, others are deleted along with the
+// cluster roles that are not bound anymore. When the cluster roles can't be listed, the admin console
+// was installed with namespace scoped rbac and there's nothing to do.
+func planClusterRBAC(clientset kubernetes.Interface, namespace string, listOptions metav1.ListOptions) ([]UninstallResource, error) {
+	clusterRoleBindings, err := clientset.RbacV1().ClusterRoleBindings().List(metav1.ListOptions{})
+	if kuberneteserrors.IsForbidden(err) {
+		return []UninstallResource{}, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list cluster role bindings")
+	}
+
+	resources := []UninstallResource{}
+	boundClusterRoles := map[string]bool{}
+	// only cluster roles that were bound by a deleted binding are deleted
+	deletedBindingRoles := map[string]bool{}
+	for _, clusterRoleBinding := range clusterRoleBindings.Items {
+		if clusterRoleBinding.Labels[types.KotsadmKey] != types.KotsadmLabelValue {
+			if clusterRoleBinding.RoleRef.Kind == "ClusterRole" {
+				boundClusterRoles[clusterRoleBinding.RoleRef.Name] = true
+			}
+			continue
+		}
+
+		remaining := subjectsNotInNamespace(clusterRoleBinding.Subjects, namespace)
+		if len(remaining) == len(clusterRoleBinding.Subjects) {
+			boundClusterRoles[clusterRoleBinding.RoleRef.Name] = true
+			continue
+		}
+
+		action := UninstallDelete
+		if len(remaining) > 0 {
+			action = UninstallUnbind
+			boundClusterRoles[clusterRoleBinding.RoleRef.Name] = true
+		} else {
+			deletedBindingRoles[clusterRoleBinding.RoleRef.Name] = true
+		}
+		resources = append(resources, UninstallResource{
+			Kind:   "ClusterRoleBinding",
+			Name:   clusterRoleBinding.Name,
+			Action: action,
+		})
+	}
+
+	clusterRoles, err := clientset.RbacV1().ClusterRoles().List(listOptions)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list cluster roles")
+	}
+	for _, clusterRole := range clusterRoles.Items {
+		if !deletedBindingRoles[clusterRole.Name] || boundClusterRoles[clusterRole.Name] {
+			continue
+		}
+		resources = append(resources, UninstallResource{
+			Kind:   "ClusterRole",
+			Name:   clusterRole.Name,
+			Action: UninstallDelete,
+		})
+	}
+
+	return resources, nil
+}
+
+func unbindClusterRoleBinding(clientset kubernetes.Interface, name string, namespace string) error {
+	clusterRoleBinding, err := clientset.RbacV1().ClusterRoleBindings().Get(name, metav1.GetOptions{})
+	if err != nil {
+		return errors.Wrap(err, "failed to get cluster role binding")
+	}
+
+	clusterRoleBinding.Subjects = subjectsNotInNamespace(clusterRoleBinding.Subjects, namespace)
+	if _, err := clientset.RbacV1().ClusterRoleBindings().Update(clusterRoleBinding); err != nil {
+		return errors.Wrap(err, "failed to update cluster role binding")
+	}
+
+	return nil
+}
+
+func subjectsNotInNamespace(subjects []rbacv1.Subject, namespace string) []rbacv1.Subject {
+	remaining := []rbacv1.Subject{}
+	for _, subject := range subjects {
+		if subject.Namespace != namespace {
+			remaining = append(remaining, subject)
+		}
+	}
+	return remaining
+}
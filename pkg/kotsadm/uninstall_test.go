@@ -0,0 +1,154 @@
+package kotsadm
+
+import (
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/kotsadm/types"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	kuberneteserrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func kotsadmObjectMeta(name string, namespace string) metav1.ObjectMeta {
+	return metav1.ObjectMeta{
+		Name:      name,
+		Namespace: namespace,
+		Labels: map[string]string{
+			types.KotsadmKey: types.KotsadmLabelValue,
+		},
+	}
+}
+
+func uninstallTestObjects(otherNamespaces ...string) []runtime.Object {
+	// the api server doesn't keep the namespace of cluster scoped objects
+	clusterRole := operatorClusterRole("test")
+	clusterRole.Namespace = ""
+	clusterRoleBinding := operatorClusterRoleBinding("test")
+	clusterRoleBinding.Namespace = ""
+	for _, namespace := range otherNamespaces {
+		clusterRoleBinding.Subjects = append(clusterRoleBinding.Subjects, rbacv1.Subject{
+			Kind:      "ServiceAccount",
+			Name:      "kotsadm-operator",
+			Namespace: namespace,
+		})
+	}
+
+	return []runtime.Object{
+		&appsv1.Deployment{ObjectMeta: kotsadmObjectMeta("kotsadm-api", "test")},
+		&appsv1.Deployment{ObjectMeta: kotsadmObjectMeta("kotsadm-operator", "test")},
+		&appsv1.Deployment{ObjectMeta: metav1.ObjectMeta{Name: "not-kotsadm", Namespace: "test"}},
+		&appsv1.StatefulSet{ObjectMeta: kotsadmObjectMeta("kotsadm-postgres", "test")},
+		&corev1.Secret{ObjectMeta: kotsadmObjectMeta("kotsadm-password", "test")},
+		&corev1.PersistentVolumeClaim{ObjectMeta: kotsadmObjectMeta("kotsadm-postgres-kotsadm-postgres-0", "test")},
+		&corev1.ServiceAccount{ObjectMeta: kotsadmObjectMeta("kotsadm-operator", "test")},
+		clusterRole,
+		clusterRoleBinding,
+	}
+}
+
+func Test_PlanUninstall(t *testing.T) {
+	tests := []struct {
+		name             string
+		objects          []runtime.Object
+		uninstallOptions types.UninstallOptions
+		expected         []UninstallResource
+		expectErr        bool
+	}{
+		{
+			name:    "everything",
+			objects: uninstallTestObjects(),
+			uninstallOptions: types.UninstallOptions{
+				Namespace:       "test",
+				DeleteNamespace: true,
+			},
+			expected: []UninstallResource{
+				{Kind: "Deployment", Namespace: "test", Name: "kotsadm-operator", Action: UninstallDelete},
+				{Kind: "Deployment", Namespace: "test", Name: "kotsadm-api", Action: UninstallDelete},
+				{Kind: "StatefulSet", Namespace: "test", Name: "kotsadm-postgres", Action: UninstallDelete},
+				{Kind: "Secret", Namespace: "test", Name: "kotsadm-password", Action: UninstallDelete},
+				{Kind: "PersistentVolumeClaim", Namespace: "test", Name: "kotsadm-postgres-kotsadm-postgres-0", Action: UninstallDelete},
+				{Kind: "ServiceAccount", Namespace: "test", Name: "kotsadm-operator", Action: UninstallDelete},
+				{Kind: "ClusterRoleBinding", Name: "kotsadm-operator-rolebinding", Action: UninstallDelete},
+				{Kind: "ClusterRole", Name: "kotsadm-operator-role", Action: UninstallDelete},
+				{Kind: "Namespace", Name: "test", Action: UninstallDelete},
+			},
+		},
+		{
+			name:    "keep pvcs and secrets, shared cluster role binding",
+			objects: uninstallTestObjects("other"),
+			uninstallOptions: types.UninstallOptions{
+				Namespace:   "test",
+				KeepPVCs:    true,
+				KeepSecrets: true,
+			},
+			expected: []UninstallResource{
+				{Kind: "Deployment", Namespace: "test", Name: "kotsadm-operator", Action: UninstallDelete},
+				{Kind: "Deployment", Namespace: "test", Name: "kotsadm-api", Action: UninstallDelete},
+				{Kind: "StatefulSet", Namespace: "test", Name: "kotsadm-postgres", Action: UninstallDelete},
+				{Kind: "Secret", Namespace: "test", Name: "kotsadm-password", Action: UninstallKeep},
+				{Kind: "PersistentVolumeClaim", Namespace: "test", Name: "kotsadm-postgres-kotsadm-postgres-0", Action: UninstallKeep},
+				{Kind: "ServiceAccount", Namespace: "test", Name: "kotsadm-operator", Action: UninstallDelete},
+				{Kind: "ClusterRoleBinding", Name: "kotsadm-operator-rolebinding", Action: UninstallUnbind},
+			},
+		},
+		{
+			name:    "delete namespace and keep pvcs",
+			objects: uninstallTestObjects(),
+			uninstallOptions: types.UninstallOptions{
+				Namespace:       "test",
+				KeepPVCs:        true,
+				DeleteNamespace: true,
+			},
+			expectErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			clientset := fake.NewSimpleClientset(test.objects...)
+			resources, err := PlanUninstall(clientset, test.uninstallOptions)
+			if test.expectErr {
+				req.Error(err)
+				return
+			}
+			req.NoError(err)
+			req.Equal(test.expected, resources)
+		})
+	}
+}
+
+func Test_uninstall(t *testing.T) {
+	req := require.New(t)
+
+	clientset := fake.NewSimpleClientset(uninstallTestObjects("other")...)
+
+	_, err := uninstall(clientset, types.UninstallOptions{Namespace: "test", KeepPVCs: true, DryRun: true})
+	req.NoError(err)
+	_, err = clientset.AppsV1().Deployments("test").Get("kotsadm-api", metav1.GetOptions{})
+	req.NoError(err)
+
+	_, err = uninstall(clientset, types.UninstallOptions{Namespace: "test", KeepPVCs: true})
+	req.NoError(err)
+
+	_, err = clientset.AppsV1().Deployments("test").Get("kotsadm-api", metav1.GetOptions{})
+	req.True(kuberneteserrors.IsNotFound(err))
+	_, err = clientset.CoreV1().Secrets("test").Get("kotsadm-password", metav1.GetOptions{})
+	req.True(kuberneteserrors.IsNotFound(err))
+	_, err = clientset.AppsV1().Deployments("test").Get("not-kotsadm", metav1.GetOptions{})
+	req.NoError(err)
+	_, err = clientset.CoreV1().PersistentVolumeClaims("test").Get("kotsadm-postgres-kotsadm-postgres-0", metav1.GetOptions{})
+	req.NoError(err)
+
+	clusterRoleBinding, err := clientset.RbacV1().ClusterRoleBindings().Get("kotsadm-operator-rolebinding", metav1.GetOptions{})
+	req.NoError(err)
+	req.Equal([]rbacv1.Subject{{Kind: "ServiceAccount", Name: "kotsadm-operator", Namespace: "other"}}, clusterRoleBinding.Subjects)
+	_, err = clientset.RbacV1().ClusterRoles().Get("kotsadm-operator-role", metav1.GetOptions{})
+	req.NoError(err)
+}
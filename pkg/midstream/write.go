@@ -1,10 +1,10 @@
 package midstream
 
 import (
-	"io/ioutil"
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 
 	"github.com/pkg/errors"
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
@@ -23,6 +23,9 @@ const (
 type WriteOptions struct {
 	MidstreamDir string
 	BaseDir      string
+	// NamespaceOverride is set when a downstream sets the namespace of all objects, so that the pull
+	// secret is only written once instead of once for each namespace in the base
+	NamespaceOverride bool
 }
 
 func (m *Midstream) KustomizationFilename(options WriteOptions) string {
@@ -72,14 +75,14 @@ func (m *Midstream) mergeKustomization(existing *kustomizetypes.Kustomization) {
 		return
 	}
 
-	filteredImages := removeExistingImages(m.Kustomization.Images, existing.Images)
+	filteredImages := k8sutil.RemoveExistingImages(m.Kustomization.Images, existing.Images)
 	m.Kustomization.Images = append(m.Kustomization.Images, filteredImages...)
 
 	existing.PatchesStrategicMerge = removeFromPatches(existing.PatchesStrategicMerge, patchesFilename)
-	newPatches := findNewPatches(m.Kustomization.PatchesStrategicMerge, existing.PatchesStrategicMerge)
+	newPatches := k8sutil.FindNewPatches(m.Kustomization.PatchesStrategicMerge, existing.PatchesStrategicMerge)
 	m.Kustomization.PatchesStrategicMerge = append(existing.PatchesStrategicMerge, newPatches...)
 
-	newResources := findNewStrings(m.Kustomization.Resources, existing.Resources)
+	newResources := k8sutil.FindNewStrings(m.Kustomization.Resources, existing.Resources)
 	m.Kustomization.Resources = append(existing.Resources, newResources...)
 }
 
@@ -109,21 +112,63 @@ func (m *Midstream) writePullSecret(options WriteOptions) (string, error) {
 
 	absFilename := filepath.Join(options.MidstreamDir, secretFilename)
 
-	b, err := k8syaml.Marshal(m.PullSecret)
+	f, err := os.Create(absFilename)
 	if err != nil {
-		return "", errors.Wrap(err, "failed to marshal pull secret")
+		return "", errors.Wrap(err, "failed to create pull secret file")
 	}
+	defer f.Close()
 
-	if err := ioutil.WriteFile(absFilename, b, 0644); err != nil {
-		return "", errors.Wrap(err, "failed to write pull secret file")
+	for _, secret := range m.pullSecrets(options.NamespaceOverride) {
+		b, err := k8syaml.Marshal(secret)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to marshal pull secret")
+		}
+
+		if _, err := f.Write([]byte("---\n")); err != nil {
+			return "", errors.Wrap(err, "failed to write pull secret file")
+		}
+		if _, err := f.Write(b); err != nil {
+			return "", errors.Wrap(err, "failed to write pull secret file")
+		}
 	}
 
 	return secretFilename, nil
 }
 
+// pullSecrets returns a copy of the pull secret for each namespace that the objects with pull secret
+// patches are deployed to. Objects without a namespace are deployed to the namespace of the pull secret.
+// When a downstream overrides the namespace the copies would all end up in the same namespace, so only
+// the pull secret is returned.
+func (m *Midstream) pullSecrets(namespaceOverride bool) []*corev1.Secret {
+	if namespaceOverride {
+		return []*corev1.Secret{m.PullSecret}
+	}
+
+	namespaces := []string{m.PullSecret.Namespace}
+	for _, o := range m.DocForPatches {
+		if o.Metadata.Namespace == "" {
+			continue
+		}
+		namespaces = append(namespaces, o.Metadata.Namespace)
+	}
+	sort.Strings(namespaces)
+
+	secrets := []*corev1.Secret{}
+	for i, namespace := range namespaces {
+		if i > 0 && namespace == namespaces[i-1] {
+			continue
+		}
+		secret := m.PullSecret.DeepCopy()
+		secret.Namespace = namespace
+		secrets = append(secrets, secret)
+	}
+
+	return secrets
+}
+
 func (m *Midstream) writeObjectsWithPullSecret(options WriteOptions) error {
 	filename := filepath.Join(options.MidstreamDir, patchesFilename)
-	if len(m.DocForPatches) == 0 {
+	if len(m.DocForPatches) == 0 || m.PullSecret == nil {
 		err := os.Remove(filename)
 		if err != nil && !os.IsNotExist(err) {
 			return errors.Wrap(err, "failed to delete pull secret patches")
@@ -174,13 +219,14 @@ func obejctWithPullSecret(obj *k8sdoc.Doc, secret *corev1.Secret) *k8sdoc.Doc {
 		APIVersion: obj.APIVersion,
 		Kind:       obj.Kind,
 		Metadata: k8sdoc.Metadata{
-			Name: obj.Metadata.Name,
+			Name:      obj.Metadata.Name,
+			Namespace: obj.Metadata.Namespace,
 		},
 		Spec: k8sdoc.Spec{
 			Template: k8sdoc.Template{
 				Spec: k8sdoc.PodSpec{
 					ImagePullSecrets: []k8sdoc.ImagePullSecret{
-						{"name": "kotsadm-replicated-registry"},
+						{"name": secret.Name},
 					},
 				},
 			},
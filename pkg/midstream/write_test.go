@@ -0,0 +1,101 @@
+package midstream
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/base"
+	"github.com/replicatedhq/kots/pkg/docker/registry"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	"github.com/stretchr/testify/require"
+	yaml "gopkg.in/yaml.v2"
+	corev1 "k8s.io/api/core/v1"
+	k8syaml "sigs.k8s.io/yaml"
+)
+
+func Test_WriteMidstreamPullSecretsPerNamespace(t *testing.T) {
+	req := require.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	baseDir := filepath.Join(tmpDir, "base")
+	files := map[string]string{
+		"kustomization.yaml":              "bases:\n- charts/redis\nresources:\n- web.yaml\n",
+		"web.yaml":                        "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  template:\n    spec:\n      containers:\n      - image: registry.example.com/web:1.0\n",
+		"charts/redis/kustomization.yaml": "namespace: redis\nresources:\n- redis.yaml\n",
+		"charts/redis/redis.yaml":         "apiVersion: apps/v1\nkind: StatefulSet\nmetadata:\n  name: redis\nspec:\n  template:\n    spec:\n      containers:\n      - image: registry.example.com/redis:5\n",
+	}
+	for name, content := range files {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(baseDir, name)), 0755)
+		req.NoError(err)
+		err = ioutil.WriteFile(filepath.Join(baseDir, name), []byte(content), 0644)
+		req.NoError(err)
+	}
+
+	objects, err := base.FindObjectsWithImages(base.FindObjectsWithImagesOptions{BaseDir: baseDir})
+	req.NoError(err)
+	req.Len(objects, 2)
+
+	pullSecret, err := registry.PullSecretForRegistries([]string{"registry.example.com"}, "user", "pass", "default")
+	req.NoError(err)
+
+	m, err := CreateMidstream(nil, nil, objects, pullSecret)
+	req.NoError(err)
+
+	midstreamDir := filepath.Join(tmpDir, "overlays", "midstream")
+	err = m.WriteMidstream(WriteOptions{
+		MidstreamDir: midstreamDir,
+		BaseDir:      baseDir,
+	})
+	req.NoError(err)
+
+	secretsContent, err := ioutil.ReadFile(filepath.Join(midstreamDir, secretFilename))
+	req.NoError(err)
+	secretDocs, err := k8sdoc.SplitYAML(secretsContent)
+	req.NoError(err)
+	secretNamespaces := []string{}
+	for _, doc := range secretDocs {
+		secret := corev1.Secret{}
+		err := k8syaml.Unmarshal(doc.Content, &secret)
+		req.NoError(err)
+		req.Equal("kotsadm-replicated-registry", secret.Name)
+		secretNamespaces = append(secretNamespaces, secret.Namespace)
+	}
+	req.Equal([]string{"default", "redis"}, secretNamespaces)
+
+	patchesContent, err := ioutil.ReadFile(filepath.Join(midstreamDir, patchesFilename))
+	req.NoError(err)
+	patchDocs, err := k8sdoc.SplitYAML(patchesContent)
+	req.NoError(err)
+	patchNamespaces := map[string]string{}
+	for _, doc := range patchDocs {
+		patch := k8sdoc.Doc{}
+		err := yaml.Unmarshal(doc.Content, &patch)
+		req.NoError(err)
+		req.Equal([]k8sdoc.ImagePullSecret{{"name": "kotsadm-replicated-registry"}}, patch.Spec.Template.Spec.ImagePullSecrets)
+		patchNamespaces[patch.Metadata.Name] = patch.Metadata.Namespace
+	}
+	req.Equal(map[string]string{"web": "", "redis": "redis"}, patchNamespaces)
+
+	// when a downstream overrides the namespace, the copies would have the same id after the override
+	err = m.WriteMidstream(WriteOptions{
+		MidstreamDir:      midstreamDir,
+		BaseDir:           baseDir,
+		NamespaceOverride: true,
+	})
+	req.NoError(err)
+
+	secretsContent, err = ioutil.ReadFile(filepath.Join(midstreamDir, secretFilename))
+	req.NoError(err)
+	secretDocs, err = k8sdoc.SplitYAML(secretsContent)
+	req.NoError(err)
+	req.Len(secretDocs, 1)
+	secret := corev1.Secret{}
+	err = k8syaml.Unmarshal(secretDocs[0].Content, &secret)
+	req.NoError(err)
+	req.Equal("default", secret.Namespace)
+}
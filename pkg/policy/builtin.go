@@ -0,0 +1,140 @@
+package policy
+
+import (
+	"fmt"
+
+	"github.com/docker/distribution/reference"
+)
+
+// BuiltinRules returns the rules that are always checked unless they are turned off in a rules file
+func BuiltinRules() []Rule {
+	return []Rule{
+		{
+			Name:         "privileged-container",
+			Severity:     SeverityError,
+			check:        checkPrivilegedContainers,
+			checkPatches: true,
+		},
+		{
+			Name:         "host-path-volume",
+			Severity:     SeverityError,
+			check:        checkHostPathVolumes,
+			checkPatches: true,
+		},
+		{
+			Name:     "missing-resource-limits",
+			Severity: SeverityWarning,
+			check:    checkResourceLimits,
+		},
+		{
+			Name:         "latest-image-tag",
+			Severity:     SeverityWarning,
+			check:        checkLatestImageTags,
+			checkPatches: true,
+		},
+	}
+}
+
+// podSpec returns the pod spec of pods and of the workload kinds that have a pod template
+func podSpec(obj map[string]interface{}) map[string]interface{} {
+	kind, _ := obj["kind"].(string)
+	spec, _ := obj["spec"].(map[string]interface{})
+	if spec == nil {
+		return nil
+	}
+
+	switch kind {
+	case "Pod":
+		return spec
+	case "CronJob":
+		jobTemplate, _ := spec["jobTemplate"].(map[string]interface{})
+		spec, _ = jobTemplate["spec"].(map[string]interface{})
+	}
+
+	template, _ := spec["template"].(map[string]interface{})
+	templateSpec, _ := template["spec"].(map[string]interface{})
+	return templateSpec
+}
+
+func containers(obj map[string]interface{}) []map[string]interface{} {
+	spec := podSpec(obj)
+	if spec == nil {
+		return nil
+	}
+
+	result := []map[string]interface{}{}
+	for _, key := range []string{"initContainers", "containers"} {
+		list, _ := spec[key].([]interface{})
+		for _, item := range list {
+			if container, ok := item.(map[string]interface{}); ok {
+				result = append(result, container)
+			}
+		}
+	}
+	return result
+}
+
+func checkPrivilegedContainers(obj map[string]interface{}) []string {
+	messages := []string{}
+	for _, container := range containers(obj) {
+		securityContext, _ := container["securityContext"].(map[string]interface{})
+		if privileged, _ := securityContext["privileged"].(bool); privileged {
+			messages = append(messages, fmt.Sprintf("container %v is privileged", container["name"]))
+		}
+	}
+	return messages
+}
+
+func checkHostPathVolumes(obj map[string]interface{}) []string {
+	spec := podSpec(obj)
+	if spec == nil {
+		return nil
+	}
+
+	messages := []string{}
+	volumes, _ := spec["volumes"].([]interface{})
+	for _, item := range volumes {
+		volume, _ := item.(map[string]interface{})
+		if hostPath, ok := volume["hostPath"].(map[string]interface{}); ok {
+			messages = append(messages, fmt.Sprintf("volume %v mounts host path %v", volume["name"], hostPath["path"]))
+		}
+	}
+	return messages
+}
+
+func checkResourceLimits(obj map[string]interface{}) []string {
+	messages := []string{}
+	for _, container := range containers(obj) {
+		resources, _ := container["resources"].(map[string]interface{})
+		limits, _ := resources["limits"].(map[string]interface{})
+		for _, resource := range []string{"cpu", "memory"} {
+			if _, ok := limits[resource]; !ok {
+				messages = append(messages, fmt.Sprintf("container %v has no %s limit", container["name"], resource))
+			}
+		}
+	}
+	return messages
+}
+
+func checkLatestImageTags(obj map[string]interface{}) []string {
+	messages := []string{}
+	for _, container := range containers(obj) {
+		image, ok := container["image"].(string)
+		if !ok {
+			continue
+		}
+
+		ref, err := reference.ParseNormalizedNamed(image)
+		if err != nil {
+			continue
+		}
+		if _, ok := ref.(reference.Digested); ok {
+			continue
+		}
+		tagged, ok := ref.(reference.Tagged)
+		if !ok || tagged.Tag() == "latest" {
+			messages = append(messages, fmt.Sprintf("container %v uses image %s with the latest tag", container["name"], image))
+		}
+	}
+	return messages
+}
@@ -0,0 +1,132 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/history"
+	"github.com/replicatedhq/kots/pkg/k8sdoc"
+	kustomizetypes "sigs.k8s.io/kustomize/v3/pkg/types"
+)
+
+// CheckDir runs the rules on the files that the kustomizations in the dir reference, such as the base,
+// midstream and downstream of an app. Resources are checked as objects and strategic merge patches as
+// patches. The versions in the history of an app are not checked. File names in violations are relative
+// to the dir.
+func CheckDir(rules []Rule, dir string) ([]Violation, error) {
+	violations := []Violation{}
+
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && isHistoryDir(p) {
+			return filepath.SkipDir
+		}
+		if info.IsDir() || info.Name() != "kustomization.yaml" {
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(p)
+		if err != nil {
+			return errors.Wrapf(err, "failed to read %s", p)
+		}
+		kustomization := kustomizetypes.Kustomization{}
+		if err := yaml.Unmarshal(b, &kustomization); err != nil {
+			return errors.Wrapf(err, "failed to unmarshal %s", p)
+		}
+
+		kustomizationDir := filepath.Dir(p)
+		for _, resource := range kustomization.Resources {
+			v, err := checkFile(rules, dir, filepath.Join(kustomizationDir, resource), false)
+			if err != nil {
+				return err
+			}
+			violations = append(violations, v...)
+		}
+		for _, patch := range kustomization.PatchesStrategicMerge {
+			v, err := checkFile(rules, dir, filepath.Join(kustomizationDir, string(patch)), true)
+			if err != nil {
+				return err
+			}
+			violations = append(violations, v...)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to walk dir")
+	}
+
+	SortViolations(violations)
+	return violations, nil
+}
+
+// isHistoryDir returns true for the history dir of an app, which is next to the base of the app
+func isHistoryDir(p string) bool {
+	if filepath.Base(p) != history.DirName {
+		return false
+	}
+	info, err := os.Stat(filepath.Join(filepath.Dir(p), "base"))
+	return err == nil && info.IsDir()
+}
+
+// checkFile checks each object in the file. Directories, such as bases, are checked through their own kustomization.
+func checkFile(rules []Rule, rootDir string, filename string, isPatch bool) ([]Violation, error) {
+	info, err := os.Stat(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrapf(err, "failed to stat %s", filename)
+	}
+	if info.IsDir() {
+		return nil, nil
+	}
+
+	relPath, err := filepath.Rel(rootDir, filename)
+	if err != nil {
+		relPath = filename
+	}
+
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", relPath)
+	}
+
+	return CheckContent(rules, relPath, content, isPatch)
+}
+
+// CheckContent runs the rules on each object in the yaml content of the file
+func CheckContent(rules []Rule, filename string, content []byte, isPatch bool) ([]Violation, error) {
+	docs, err := k8sdoc.SplitYAML(content)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to split %s", filename)
+	}
+
+	violations := []Violation{}
+	for _, doc := range docs {
+		obj := map[string]interface{}{}
+		if err := yaml.Unmarshal(doc.Content, &obj); err != nil {
+			return nil, &k8sdoc.ParseError{File: filename, Index: doc.Index, Err: err}
+		}
+		if _, ok := obj["kind"]; !ok {
+			continue
+		}
+
+		v, err := CheckObject(rules, obj, isPatch)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to check document at index %d in %s", doc.Index, filename)
+		}
+		for i := range v {
+			v[i].File = filename
+			v[i].Index = doc.Index
+		}
+		violations = append(violations, v...)
+	}
+
+	return violations, nil
+}
@@ -0,0 +1,289 @@
+package policy
+
+import (
+	"fmt"
+	"io/ioutil"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+type Severity string
+
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+	// SeverityOff disables a built-in rule
+	SeverityOff Severity = "off"
+)
+
+// Rule is a check that runs on each object. Built-in rules are implemented in go. Custom rules
+// assert something about the values that a jsonpath selects from the object.
+type Rule struct {
+	Name     string   `json:"name"`
+	Severity Severity `json:"severity"`
+	Message  string   `json:"message,omitempty"`
+	// Kinds limits the rule to objects of these kinds, all kinds are checked when empty
+	Kinds []string `json:"kinds,omitempty"`
+	// Path is a kubernetes jsonpath expression, such as {.metadata.labels.team}
+	Path string `json:"path,omitempty"`
+	// Assert is one of exists, absent, equals, notEquals, matches or notMatches
+	Assert string `json:"assert,omitempty"`
+	Value  string `json:"value,omitempty"`
+
+	check func(obj map[string]interface{}) []string
+	// checkPatches is set for rules that only look for values that are present, so that they
+	// are also correct on strategic merge patches
+	checkPatches bool
+}
+
+// RulesFile is the format of a custom rules file. A rule with the name of a built-in rule and no
+// path changes the severity of the built-in rule.
+type RulesFile struct {
+	Rules []Rule `json:"rules"`
+}
+
+// Violation is a rule that an object doesn't pass
+type Violation struct {
+	Rule      string
+	Severity  Severity
+	Message   string
+	File      string
+	Index     int
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+func (v Violation) String() string {
+	object := fmt.Sprintf("%s/%s", v.Kind, v.Name)
+	if v.Namespace != "" {
+		object = fmt.Sprintf("%s/%s/%s", v.Kind, v.Namespace, v.Name)
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%s: %s", v.Severity, v.Rule, v.File, object, v.Message)
+}
+
+// LoadRules returns the built-in rules with the rules in the file merged in. An empty filename
+// returns only the built-in rules.
+func LoadRules(filename string) ([]Rule, error) {
+	rules := BuiltinRules()
+	if filename == "" {
+		return rules, nil
+	}
+
+	b, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read rules file")
+	}
+
+	rulesFile := RulesFile{}
+	if err := yaml.Unmarshal(b, &rulesFile); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal rules file")
+	}
+
+	return mergeRules(rules, rulesFile.Rules)
+}
+
+func mergeRules(rules []Rule, customRules []Rule) ([]Rule, error) {
+	for _, customRule := range customRules {
+		if customRule.Name == "" {
+			return nil, errors.New("rule is missing a name")
+		}
+		if err := validateSeverity(customRule.Severity); err != nil {
+			return nil, errors.Wrapf(err, "invalid rule %s", customRule.Name)
+		}
+
+		builtin := -1
+		for i, rule := range rules {
+			if rule.Name == customRule.Name && rule.check != nil {
+				builtin = i
+			}
+		}
+
+		if customRule.Path == "" {
+			if builtin == -1 {
+				return nil, errors.Errorf("rule %s has no path and is not a built-in rule", customRule.Name)
+			}
+			rules[builtin].Severity = customRule.Severity
+			continue
+		}
+		if builtin != -1 {
+			return nil, errors.Errorf("rule %s has the name of a built-in rule", customRule.Name)
+		}
+
+		if err := validateCustomRule(customRule); err != nil {
+			return nil, errors.Wrapf(err, "invalid rule %s", customRule.Name)
+		}
+		rules = append(rules, customRule)
+	}
+
+	enabled := []Rule{}
+	for _, rule := range rules {
+		if rule.Severity != SeverityOff {
+			enabled = append(enabled, rule)
+		}
+	}
+	return enabled, nil
+}
+
+func validateSeverity(severity Severity) error {
+	switch severity {
+	case SeverityError, SeverityWarning, SeverityOff:
+		return nil
+	}
+	return errors.Errorf("unknown severity %q", severity)
+}
+
+func validateCustomRule(rule Rule) error {
+	if _, err := jsonpath.Parse(rule.Name, rule.Path); err != nil {
+		return errors.Wrap(err, "failed to parse path")
+	}
+
+	switch rule.Assert {
+	case "exists", "absent", "equals", "notEquals":
+		return nil
+	case "matches", "notMatches":
+		if _, err := regexp.Compile(rule.Value); err != nil {
+			return errors.Wrap(err, "failed to compile value")
+		}
+		return nil
+	}
+	return errors.Errorf("unknown assert %q", rule.Assert)
+}
+
+// CheckObject runs the rules on the object. Patches are partial objects, so only rules that look
+// for values that are present run on them.
+func CheckObject(rules []Rule, obj map[string]interface{}, isPatch bool) ([]Violation, error) {
+	kind, _ := obj["kind"].(string)
+	metadata, _ := obj["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+
+	violations := []Violation{}
+	for _, rule := range rules {
+		if isPatch && !rule.checkPatches {
+			continue
+		}
+		if len(rule.Kinds) > 0 && !containsString(rule.Kinds, kind) {
+			continue
+		}
+
+		var messages []string
+		if rule.check != nil {
+			messages = rule.check(obj)
+		} else {
+			m, err := checkCustomRule(rule, obj)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to check rule %s", rule.Name)
+			}
+			messages = m
+		}
+
+		for _, message := range messages {
+			violations = append(violations, Violation{
+				Rule:      rule.Name,
+				Severity:  rule.Severity,
+				Message:   message,
+				Kind:      kind,
+				Name:      name,
+				Namespace: namespace,
+			})
+		}
+	}
+
+	return violations, nil
+}
+
+func checkCustomRule(rule Rule, obj map[string]interface{}) ([]string, error) {
+	j := jsonpath.New(rule.Name)
+	j.AllowMissingKeys(true)
+	if err := j.Parse(rule.Path); err != nil {
+		return nil, errors.Wrap(err, "failed to parse path")
+	}
+
+	results, err := j.FindResults(obj)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find results")
+	}
+
+	values := []string{}
+	for _, result := range results {
+		for _, value := range result {
+			values = append(values, fmt.Sprint(value.Interface()))
+		}
+	}
+
+	message := rule.Message
+	if message == "" {
+		message = fmt.Sprintf("%s %s %s", rule.Path, rule.Assert, rule.Value)
+	}
+
+	switch rule.Assert {
+	case "exists":
+		if len(values) == 0 {
+			return []string{message}, nil
+		}
+	case "absent":
+		if len(values) > 0 {
+			return []string{message}, nil
+		}
+	case "equals", "notEquals", "matches", "notMatches":
+		messages := []string{}
+		for _, value := range values {
+			var ok bool
+			switch rule.Assert {
+			case "equals":
+				ok = value == rule.Value
+			case "notEquals":
+				ok = value != rule.Value
+			case "matches":
+				ok = regexp.MustCompile(rule.Value).MatchString(value)
+			case "notMatches":
+				ok = !regexp.MustCompile(rule.Value).MatchString(value)
+			}
+			if !ok {
+				messages = append(messages, fmt.Sprintf("%s (found %q)", message, value))
+			}
+		}
+		return messages, nil
+	}
+
+	return nil, nil
+}
+
+// SortViolations orders violations by file, document and rule
+func SortViolations(violations []Violation) {
+	sort.SliceStable(violations, func(i, j int) bool {
+		if violations[i].File != violations[j].File {
+			return violations[i].File < violations[j].File
+		}
+		if violations[i].Index != violations[j].Index {
+			return violations[i].Index < violations[j].Index
+		}
+		return violations[i].Rule < violations[j].Rule
+	})
+}
+
+// CountErrors returns how many violations have the error severity. Warnings are not counted.
+func CountErrors(violations []Violation) int {
+	count := 0
+	for _, violation := range violations {
+		if violation.Severity == SeverityError {
+			count++
+		}
+	}
+	return count
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if strings.EqualFold(item, s) {
+			return true
+		}
+	}
+	return false
+}
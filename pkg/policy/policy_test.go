@@ -0,0 +1,185 @@
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/replicatedhq/kots/pkg/history"
+	"github.com/stretchr/testify/require"
+)
+
+const testDeployment = `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: app
+spec:
+  template:
+    spec:
+      volumes:
+        - name: host
+          hostPath:
+            path: /var/run
+      containers:
+        - name: nginx
+          image: nginx
+          securityContext:
+            privileged: true
+        - name: sidecar
+          image: busybox:1.31@sha256:1828edd60c5efd34b2bf5dd3282ec0cc04d47b2ff9caa0b6d4f07a21d1c08084
+          resources:
+            limits:
+              cpu: 100m
+              memory: 64Mi
+`
+
+func Test_CheckContent(t *testing.T) {
+	tests := []struct {
+		name        string
+		customRules []Rule
+		content     string
+		isPatch     bool
+		expected    []string
+	}{
+		{
+			name:    "built-in rules",
+			content: testDeployment,
+			expected: []string{
+				"error\thost-path-volume\tdeployment.yaml\tDeployment/app/web: volume host mounts host path /var/run",
+				"warning\tlatest-image-tag\tdeployment.yaml\tDeployment/app/web: container nginx uses image nginx with the latest tag",
+				"warning\tmissing-resource-limits\tdeployment.yaml\tDeployment/app/web: container nginx has no cpu limit",
+				"warning\tmissing-resource-limits\tdeployment.yaml\tDeployment/app/web: container nginx has no memory limit",
+				"error\tprivileged-container\tdeployment.yaml\tDeployment/app/web: container nginx is privileged",
+			},
+		},
+		{
+			name:    "patches skip rules for missing values",
+			content: testDeployment,
+			isPatch: true,
+			expected: []string{
+				"error\thost-path-volume\tdeployment.yaml\tDeployment/app/web: volume host mounts host path /var/run",
+				"warning\tlatest-image-tag\tdeployment.yaml\tDeployment/app/web: container nginx uses image nginx with the latest tag",
+				"error\tprivileged-container\tdeployment.yaml\tDeployment/app/web: container nginx is privileged",
+			},
+		},
+		{
+			name: "severity overrides and custom rules",
+			customRules: []Rule{
+				{Name: "missing-resource-limits", Severity: SeverityOff},
+				{Name: "host-path-volume", Severity: SeverityOff},
+				{Name: "latest-image-tag", Severity: SeverityError},
+				{Name: "team-label", Severity: SeverityWarning, Kinds: []string{"Deployment"}, Path: "{.metadata.labels.team}", Assert: "exists", Message: "workloads need a team label"},
+				{Name: "registry", Severity: SeverityError, Path: "{.spec.template.spec.containers[*].image}", Assert: "matches", Value: "^registry.example.com/", Message: "images must come from the private registry"},
+				{Name: "configmap-only", Severity: SeverityError, Kinds: []string{"ConfigMap"}, Path: "{.metadata.name}", Assert: "absent"},
+			},
+			content: testDeployment,
+			expected: []string{
+				"error\tlatest-image-tag\tdeployment.yaml\tDeployment/app/web: container nginx uses image nginx with the latest tag",
+				"error\tprivileged-container\tdeployment.yaml\tDeployment/app/web: container nginx is privileged",
+				"error\tregistry\tdeployment.yaml\tDeployment/app/web: images must come from the private registry (found \"nginx\")",
+				"error\tregistry\tdeployment.yaml\tDeployment/app/web: images must come from the private registry (found \"busybox:1.31@sha256:1828edd60c5efd34b2bf5dd3282ec0cc04d47b2ff9caa0b6d4f07a21d1c08084\")",
+				"warning\tteam-label\tdeployment.yaml\tDeployment/app/web: workloads need a team label",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			rules, err := mergeRules(BuiltinRules(), test.customRules)
+			req.NoError(err)
+
+			violations, err := CheckContent(rules, "deployment.yaml", []byte(test.content), test.isPatch)
+			req.NoError(err)
+			SortViolations(violations)
+
+			actual := []string{}
+			for _, violation := range violations {
+				actual = append(actual, violation.String())
+			}
+			req.Equal(test.expected, actual)
+		})
+	}
+}
+
+func Test_mergeRulesErrors(t *testing.T) {
+	tests := []struct {
+		name          string
+		rule          Rule
+		expectedError string
+	}{
+		{
+			name:          "unknown built-in",
+			rule:          Rule{Name: "nope", Severity: SeverityError},
+			expectedError: "rule nope has no path and is not a built-in rule",
+		},
+		{
+			name:          "unknown severity",
+			rule:          Rule{Name: "latest-image-tag", Severity: "fatal"},
+			expectedError: `invalid rule latest-image-tag: unknown severity "fatal"`,
+		},
+		{
+			name:          "unknown assert",
+			rule:          Rule{Name: "custom", Severity: SeverityError, Path: "{.kind}", Assert: "contains"},
+			expectedError: `invalid rule custom: unknown assert "contains"`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := mergeRules(BuiltinRules(), []Rule{test.rule})
+			require.EqualError(t, err, test.expectedError)
+		})
+	}
+}
+
+func Test_CheckDir(t *testing.T) {
+	req := require.New(t)
+
+	appDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(appDir)
+
+	files := map[string]string{
+		"base/kustomization.yaml":                              "resources:\n- deployment.yaml\n",
+		"base/deployment.yaml":                                 testDeployment,
+		"base/not-referenced.yaml":                             "apiVersion: v1\nkind: Pod\nspec:\n  containers:\n  - name: x\n    image: x:latest\n",
+		"overlays/downstreams/this-cluster/kustomization.yaml": "bases:\n- ../../../base\npatchesStrategicMerge:\n- patch.yaml\n",
+		"overlays/downstreams/this-cluster/patch.yaml":         "apiVersion: apps/v1\nkind: Deployment\nmetadata:\n  name: web\nspec:\n  template:\n    spec:\n      containers:\n      - name: nginx\n        image: nginx:latest\n",
+	}
+	for name, content := range files {
+		err := os.MkdirAll(filepath.Dir(filepath.Join(appDir, name)), 0755)
+		req.NoError(err)
+		err = ioutil.WriteFile(filepath.Join(appDir, name), []byte(content), 0644)
+		req.NoError(err)
+	}
+
+	// the recorded version has the same violations, but only the current version is checked
+	_, err = history.Record(appDir, history.Version{UpdateCursor: "1"}, history.DefaultKeepVersions)
+	req.NoError(err)
+	_, err = os.Stat(filepath.Join(appDir, history.DirName, "0", "base", "kustomization.yaml"))
+	req.NoError(err)
+
+	rules, err := LoadRules("")
+	req.NoError(err)
+
+	violations, err := CheckDir(rules, appDir)
+	req.NoError(err)
+
+	actual := []string{}
+	for _, violation := range violations {
+		actual = append(actual, violation.Rule+" "+violation.File)
+	}
+	req.Equal([]string{
+		"host-path-volume base/deployment.yaml",
+		"latest-image-tag base/deployment.yaml",
+		"missing-resource-limits base/deployment.yaml",
+		"missing-resource-limits base/deployment.yaml",
+		"privileged-container base/deployment.yaml",
+		"latest-image-tag overlays/downstreams/this-cluster/patch.yaml",
+	}, actual)
+	req.Equal(2, CountErrors(violations))
+}
@@ -7,13 +7,14 @@ import (
 )
 
 type GetUpdatesOptions struct {
-	HelmRepoURI    string
-	Namespace      string
-	LocalPath      string
-	LicenseFile    string
-	CurrentCursor  string
-	CurrentChannel string
-	Silent         bool
+	HelmRepoURI         string
+	HelmRepoCredentials upstream.HelmRepoCredentials
+	Namespace           string
+	LocalPath           string
+	LicenseFile         string
+	CurrentCursor       string
+	CurrentChannel      string
+	Silent              bool
 }
 
 // GetUpdates will retrieve all later versions of the application specified in upstreamURI
@@ -29,6 +30,7 @@ func GetUpdates(upstreamURI string, getUpdatesOptions GetUpdatesOptions) ([]upst
 
 	fetchOptions := upstream.FetchOptions{}
 	fetchOptions.HelmRepoURI = getUpdatesOptions.HelmRepoURI
+	fetchOptions.HelmRepoCredentials = getUpdatesOptions.HelmRepoCredentials
 	fetchOptions.LocalPath = getUpdatesOptions.LocalPath
 	fetchOptions.CurrentCursor = getUpdatesOptions.CurrentCursor
 	fetchOptions.CurrentChannel = getUpdatesOptions.CurrentChannel
@@ -13,6 +13,8 @@ import (
 	"github.com/replicatedhq/kots/pkg/base"
 	"github.com/replicatedhq/kots/pkg/docker/registry"
 	"github.com/replicatedhq/kots/pkg/downstream"
+	"github.com/replicatedhq/kots/pkg/gitops"
+	"github.com/replicatedhq/kots/pkg/history"
 	"github.com/replicatedhq/kots/pkg/k8sdoc"
 	"github.com/replicatedhq/kots/pkg/logger"
 	"github.com/replicatedhq/kots/pkg/midstream"
@@ -25,9 +27,12 @@ import (
 
 type PullOptions struct {
 	HelmRepoURI         string
+	HelmRepoCredentials upstream.HelmRepoCredentials
 	RootDir             string
 	Namespace           string
 	Downstreams         []string
+	// DownstreamSpecFiles maps downstream names to the file with their customization
+	DownstreamSpecFiles map[string]string
 	LocalPath           string
 	LicenseFile         string
 	InstallationFile    string
@@ -42,7 +47,18 @@ type PullOptions struct {
 	RewriteImages       bool
 	RewriteImageOptions RewriteImageOptions
 	HelmOptions         []string
+	KubeVersion         string
+	APIVersions         []string
 	ReportWriter        io.Writer
+	// StrictYAML fails the pull when a document in the base can't be parsed while looking for images,
+	// instead of skipping the document with a warning
+	StrictYAML bool
+	// KeepVersions is how many versions of the upstream and base are kept in the history of the app. The
+	// history is disabled when it's 0, and it's only kept for replicated apps, since only the application of
+	// a replicated release can allow rollback.
+	KeepVersions int
+	// GitOps, when set, commits the base and overlays of the app to a git repo after they are written
+	GitOps *gitops.Options
 }
 
 type RewriteImageOptions struct {
@@ -113,6 +129,7 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 
 	fetchOptions := upstream.FetchOptions{}
 	fetchOptions.HelmRepoURI = pullOptions.HelmRepoURI
+	fetchOptions.HelmRepoCredentials = pullOptions.HelmRepoCredentials
 	fetchOptions.RootDir = pullOptions.RootDir
 	fetchOptions.UseAppDir = pullOptions.CreateAppDir
 	fetchOptions.LocalPath = pullOptions.LocalPath
@@ -132,6 +149,9 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 
 		fetchOptions.License = license
 	}
+	if pullOptions.GitOps != nil && fetchOptions.License != nil && !fetchOptions.License.Spec.IsGitOpsSupported {
+		return "", errors.New("gitops is not supported by the license")
+	}
 	if pullOptions.ConfigFile != "" {
 		config, err := parseConfigValuesFromFile(pullOptions.ConfigFile)
 		if err != nil {
@@ -177,6 +197,7 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		CreateAppDir:        pullOptions.CreateAppDir,
 		IncludeAdminConsole: includeAdminConsole,
 		SharedPassword:      pullOptions.SharedPassword,
+		ReportWriter:        pullOptions.ReportWriter,
 	}
 	if err := upstream.WriteUpstream(u, writeUpstreamOptions); err != nil {
 		log.FinishSpinnerWithError()
@@ -190,6 +211,8 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		SplitMultiDocYAML: true,
 		Namespace:         pullOptions.Namespace,
 		HelmOptions:       pullOptions.HelmOptions,
+		KubeVersion:       pullOptions.KubeVersion,
+		APIVersions:       pullOptions.APIVersions,
 		Log:               log,
 	}
 	log.ActionWithSpinner("Creating base")
@@ -223,6 +246,7 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		if pullOptions.RewriteImageOptions.ImageFiles == "" {
 			writeUpstreamImageOptions := base.WriteUpstreamImageOptions{
 				BaseDir: writeBaseOptions.BaseDir,
+				Strict:  pullOptions.StrictYAML,
 				Log:     log,
 				SourceRegistry: registry.RegistryOptions{
 					Endpoint:      replicatedRegistryInfo.Registry,
@@ -288,6 +312,8 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 
 			findObjectsOptions := base.FindObjectsWithImagesOptions{
 				BaseDir: writeBaseOptions.BaseDir,
+				Strict:  pullOptions.StrictYAML,
+				Log:     log,
 			}
 			affectedObjects, err := base.FindObjectsWithImages(findObjectsOptions)
 			if err != nil {
@@ -328,6 +354,8 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 				Endpoint:      replicatedRegistryInfo.Registry,
 				ProxyEndpoint: replicatedRegistryInfo.Proxy,
 			},
+			Strict: pullOptions.StrictYAML,
+			Log:    log,
 		}
 		rewrittenImages, affectedObjects, err := base.FindPrivateImages(findPrivateImagesOptions)
 		if err != nil {
@@ -364,6 +392,26 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		MidstreamDir: filepath.Join(b.GetOverlaysDir(writeBaseOptions), "midstream"),
 		BaseDir:      u.GetBaseDir(writeUpstreamOptions),
 	}
+
+	downstreamSpecs := map[string]*downstream.Spec{}
+	for _, downstreamName := range pullOptions.Downstreams {
+		if specFile, ok := pullOptions.DownstreamSpecFiles[downstreamName]; ok {
+			spec, err := downstream.LoadSpec(specFile)
+			if err != nil {
+				return "", errors.Wrapf(err, "failed to load spec for downstream %s", downstreamName)
+			}
+			downstreamSpecs[downstreamName] = spec
+		}
+
+		setsNamespace, err := downstream.SetsNamespace(filepath.Join(b.GetOverlaysDir(writeBaseOptions), "downstreams", downstreamName), downstreamSpecs[downstreamName])
+		if err != nil {
+			return "", errors.Wrapf(err, "failed to check namespace of downstream %s", downstreamName)
+		}
+		if setsNamespace {
+			writeMidstreamOptions.NamespaceOverride = true
+		}
+	}
+
 	if err := m.WriteMidstream(writeMidstreamOptions); err != nil {
 		return "", errors.Wrap(err, "failed to write midstream")
 	}
@@ -375,6 +423,7 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		if err != nil {
 			return "", errors.Wrap(err, "failed to create downstream")
 		}
+		d.Spec = downstreamSpecs[downstreamName]
 
 		writeDownstreamOptions := downstream.WriteOptions{
 			DownstreamDir: filepath.Join(b.GetOverlaysDir(writeBaseOptions), "downstreams", downstreamName),
@@ -388,6 +437,41 @@ func Pull(upstreamURI string, pullOptions PullOptions) (string, error) {
 		log.FinishSpinner()
 	}
 
+	appDir := pullOptions.RootDir
+	if pullOptions.CreateAppDir {
+		appDir = filepath.Join(appDir, u.Name)
+	}
+	version := history.Version{
+		UpdateCursor: u.UpdateCursor,
+		VersionLabel: u.VersionLabel,
+		ReleaseNotes: u.ReleaseNotes,
+	}
+	if u.Type == "replicated" {
+		if _, err := history.Record(appDir, version, pullOptions.KeepVersions); err != nil {
+			return "", errors.Wrap(err, "failed to record version history")
+		}
+	}
+
+	if pullOptions.GitOps != nil {
+		log.ActionWithSpinner("Committing to gitops repo")
+		io.WriteString(pullOptions.ReportWriter, "Committing to gitops repo\n")
+		dirs := map[string]string{
+			"base":     u.GetBaseDir(writeUpstreamOptions),
+			"overlays": b.GetOverlaysDir(writeBaseOptions),
+		}
+		change := gitops.Change{
+			AppName:      u.Name,
+			VersionLabel: u.VersionLabel,
+			UpdateCursor: u.UpdateCursor,
+			ReleaseNotes: u.ReleaseNotes,
+		}
+		if _, err := gitops.CommitDirs(*pullOptions.GitOps, dirs, change); err != nil {
+			log.FinishSpinnerWithError()
+			return "", errors.Wrap(err, "failed to commit to gitops repo")
+		}
+		log.FinishSpinner()
+	}
+
 	if includeAdminConsole {
 		if err := writeArchiveAsConfigMap(pullOptions, u, u.GetBaseDir(writeUpstreamOptions)); err != nil {
 			return "", errors.Wrap(err, "failed to write archive as config map")
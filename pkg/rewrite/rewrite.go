@@ -77,6 +77,7 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 		RootDir:             rewriteOptions.RootDir,
 		CreateAppDir:        rewriteOptions.CreateAppDir,
 		IncludeAdminConsole: includeAdminConsole,
+		ReportWriter:        rewriteOptions.ReportWriter,
 	}
 	if err := upstream.WriteUpstream(u, writeUpstreamOptions); err != nil {
 		log.FinishSpinnerWithError()
@@ -147,6 +148,7 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 
 		findObjectsOptions := base.FindObjectsWithImagesOptions{
 			BaseDir: writeBaseOptions.BaseDir,
+			Log:     log,
 		}
 		affectedObjects, err := base.FindObjectsWithImages(findObjectsOptions)
 		if err != nil {
@@ -184,6 +186,7 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 				Endpoint:      replicatedRegistryInfo.Registry,
 				ProxyEndpoint: replicatedRegistryInfo.Proxy,
 			},
+			Log: log,
 		}
 		rewrittenImages, affectedObjects, err := base.FindPrivateImages(findPrivateImagesOptions)
 		if err != nil {
@@ -220,6 +223,15 @@ func Rewrite(rewriteOptions RewriteOptions) error {
 		MidstreamDir: filepath.Join(b.GetOverlaysDir(writeBaseOptions), "midstream"),
 		BaseDir:      u.GetBaseDir(writeUpstreamOptions),
 	}
+	for _, downstreamName := range rewriteOptions.Downstreams {
+		setsNamespace, err := downstream.SetsNamespace(filepath.Join(b.GetOverlaysDir(writeBaseOptions), "downstreams", downstreamName), nil)
+		if err != nil {
+			return errors.Wrapf(err, "failed to check namespace of downstream %s", downstreamName)
+		}
+		if setsNamespace {
+			writeMidstreamOptions.NamespaceOverride = true
+		}
+	}
 	if err := m.WriteMidstream(writeMidstreamOptions); err != nil {
 		return errors.Wrap(err, "failed to write midstream")
 	}
@@ -7,6 +7,8 @@ import (
 	"text/template"
 
 	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/crypto"
 )
 
 var (
@@ -18,6 +20,40 @@ type Builder struct {
 	Functs template.FuncMap
 }
 
+// NewBuilder returns a builder with the contexts that the files of a replicated app are rendered with
+func NewBuilder(config *kotsv1beta1.Config, configValues *kotsv1beta1.ConfigValues, license *kotsv1beta1.License, cipher *crypto.AESCipher) (Builder, error) {
+	templateContext := map[string]ItemValue{}
+	if configValues != nil {
+		for k, v := range configValues.Spec.Values {
+			templateContext[k] = ItemValue{
+				Value:   v.Value,
+				Default: v.Default,
+			}
+		}
+	}
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	// the license context is added first so that config items can use it
+	if license != nil {
+		licenseCtx := LicenseCtx{
+			License: license,
+		}
+		builder.AddCtx(licenseCtx)
+	}
+
+	if config != nil {
+		configCtx, err := builder.NewConfigContext(config.Spec.Groups, templateContext, cipher)
+		if err != nil {
+			return Builder{}, errors.Wrap(err, "failed to create config context")
+		}
+		builder.AddCtx(configCtx)
+	}
+
+	return builder, nil
+}
+
 func (b *Builder) AddCtx(ctx Ctx) {
 	b.Ctx = append(b.Ctx, ctx)
 }
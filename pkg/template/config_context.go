@@ -10,42 +10,58 @@ import (
 	"github.com/replicatedhq/kots/pkg/crypto"
 )
 
+// ComputedItemType is the config item type for values that are always rendered from the
+// item's value template and cannot be set by the user.
+const ComputedItemType = "computed"
+
+// NewConfigContext evaluates the config items in dependency order, so that an item's default,
+// value and when can reference any other item with ConfigOption. Values already present in
+// templateContext are used as-is, except for computed items, which are always rendered.
 func (b *Builder) NewConfigContext(configGroups []kotsv1beta1.ConfigGroup, templateContext map[string]ItemValue, cipher *crypto.AESCipher) (*ConfigCtx, error) {
 	configCtx := &ConfigCtx{
 		ItemValues: templateContext,
 	}
 
-	for _, configGroup := range configGroups {
-		for _, configItem := range configGroup.Items {
-			// if the pending value is different from the built, then use the pending every time
-			// We have to ignore errors here because we only have the static context loaded
-			// for rendering. some items have templates that need the config context,
-			// so we can ignore these.
-
-			var itemValue ItemValue
-			if v, ok := templateContext[configItem.Name]; ok {
-				itemValue = ItemValue{
-					Value:   v.Value,
-					Default: v.Default,
-				}
-			} else {
-				builtDefault, _ := b.String(configItem.Default.String())
-				builtValue, _ := b.String(configItem.Value.String())
-				itemValue = ItemValue{
-					Value:   builtValue,
-					Default: builtDefault,
-				}
+	sortedItems, err := sortConfigItems(configGroups)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to sort config items")
+	}
+
+	// items are rendered with everything evaluated so far available to ConfigOption
+	itemBuilder := Builder{
+		Ctx: append(append([]Ctx{}, b.Ctx...), configCtx),
+	}
+
+	for _, configItem := range sortedItems {
+		var itemValue ItemValue
+		if v, ok := templateContext[configItem.Name]; ok && configItem.Type != ComputedItemType {
+			itemValue = ItemValue{
+				Value:   v.Value,
+				Default: v.Default,
+			}
+		} else {
+			builtDefault, err := itemBuilder.String(configItem.Default.String())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to render default for config item %s", configItem.Name)
+			}
+			builtValue, err := itemBuilder.String(configItem.Value.String())
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to render value for config item %s", configItem.Name)
 			}
+			itemValue = ItemValue{
+				Value:   builtValue,
+				Default: builtDefault,
+			}
+		}
 
-			if configItem.Type == "password" && itemValue.HasValue() {
-				// FIXME: this temporarily ignores errors and falls back on old behavior
-				val, err := decrypt(itemValue.ValueStr(), cipher)
-				if err == nil {
-					itemValue.Value = val
-				}
+		if configItem.Type == "password" && itemValue.HasValue() {
+			// FIXME: this temporarily ignores errors and falls back on old behavior
+			val, err := decrypt(itemValue.ValueStr(), cipher)
+			if err == nil {
+				itemValue.Value = val
 			}
-			configCtx.ItemValues[configItem.Name] = itemValue
 		}
+		configCtx.ItemValues[configItem.Name] = itemValue
 	}
 
 	return configCtx, nil
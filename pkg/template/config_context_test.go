@@ -0,0 +1,108 @@
+package template
+
+import (
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/kotskinds/multitype"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewConfigContext_dependencyOrder(t *testing.T) {
+	req := require.New(t)
+
+	configGroups := []kotsv1beta1.ConfigGroup{
+		{
+			Name: "group",
+			Items: []kotsv1beta1.ConfigItem{
+				{
+					Name:    "url",
+					Type:    "text",
+					Default: multitype.FromString(`http://repl{{ ConfigOption "hostname" }}:repl{{ ConfigOption "port" }}`),
+				},
+				{
+					Name:    "hostname",
+					Type:    "text",
+					Default: multitype.FromString("example.com"),
+				},
+				{
+					Name:    "port",
+					Type:    "text",
+					Default: multitype.FromString("8080"),
+				},
+			},
+		},
+	}
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	configCtx, err := builder.NewConfigContext(configGroups, map[string]ItemValue{
+		"port": {Value: "9090"},
+	}, nil)
+	req.NoError(err)
+
+	req.Equal("http://example.com:9090", configCtx.ItemValues["url"].DefaultStr())
+}
+
+func TestNewConfigContext_cycle(t *testing.T) {
+	req := require.New(t)
+
+	configGroups := []kotsv1beta1.ConfigGroup{
+		{
+			Name: "group",
+			Items: []kotsv1beta1.ConfigItem{
+				{
+					Name:    "a",
+					Type:    "text",
+					Default: multitype.FromString(`repl{{ ConfigOption "b" }}`),
+				},
+				{
+					Name: "b",
+					Type: "text",
+					When: `repl{{ ConfigOptionEquals "a" "1" }}`,
+				},
+			},
+		},
+	}
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	_, err := builder.NewConfigContext(configGroups, map[string]ItemValue{}, nil)
+	req.Error(err)
+	req.Contains(err.Error(), "a -> b -> a")
+}
+
+func TestNewConfigContext_computed(t *testing.T) {
+	req := require.New(t)
+
+	configGroups := []kotsv1beta1.ConfigGroup{
+		{
+			Name: "group",
+			Items: []kotsv1beta1.ConfigItem{
+				{
+					Name:  "replicas",
+					Type:  "text",
+					Value: multitype.FromString("2"),
+				},
+				{
+					Name:  "total",
+					Type:  ComputedItemType,
+					Value: multitype.FromString(`repl{{ Mult (ConfigOption "replicas" | ParseInt) 3 }}`),
+				},
+			},
+		},
+	}
+
+	builder := Builder{}
+	builder.AddCtx(StaticCtx{})
+
+	configCtx, err := builder.NewConfigContext(configGroups, map[string]ItemValue{
+		"replicas": {Value: "4"},
+		"total":    {Value: "100"},
+	}, nil)
+	req.NoError(err)
+
+	req.Equal("12", configCtx.ItemValues["total"].ValueStr())
+}
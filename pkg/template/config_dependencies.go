@@ -0,0 +1,96 @@
+package template
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+)
+
+var (
+	configOptionRefRegexp = regexp.MustCompile(`ConfigOption(?:Index|Data|Equals|NotEquals)?\s+"([^"]+)"`)
+)
+
+// ConfigOptionReferences returns the names of all config items referenced by ConfigOption
+// functions in the template text.
+func ConfigOptionReferences(text string) []string {
+	refs := []string{}
+	for _, match := range configOptionRefRegexp.FindAllStringSubmatch(text, -1) {
+		refs = append(refs, match[1])
+	}
+	return refs
+}
+
+// configItemDependencies builds a graph from each config item name to the names of the items
+// it references in its default, value and when.
+func configItemDependencies(configGroups []kotsv1beta1.ConfigGroup) map[string][]string {
+	dependencies := map[string][]string{}
+	for _, configGroup := range configGroups {
+		for _, configItem := range configGroup.Items {
+			deps := []string{}
+			deps = append(deps, ConfigOptionReferences(configItem.Default.String())...)
+			deps = append(deps, ConfigOptionReferences(configItem.Value.String())...)
+			deps = append(deps, ConfigOptionReferences(configItem.When)...)
+			dependencies[configItem.Name] = deps
+		}
+	}
+	return dependencies
+}
+
+// sortConfigItems returns the config items in an order where every item comes after the items
+// it depends on. Items without dependencies between them keep the order they were declared in.
+// An error is returned if the references form a cycle.
+func sortConfigItems(configGroups []kotsv1beta1.ConfigGroup) ([]kotsv1beta1.ConfigItem, error) {
+	dependencies := configItemDependencies(configGroups)
+
+	itemsByName := map[string]kotsv1beta1.ConfigItem{}
+	for _, configGroup := range configGroups {
+		for _, configItem := range configGroup.Items {
+			itemsByName[configItem.Name] = configItem
+		}
+	}
+
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := map[string]int{}
+	sorted := []kotsv1beta1.ConfigItem{}
+
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case visited:
+			return nil
+		case visiting:
+			return errors.Errorf("config items have a circular dependency: %s", strings.Join(append(path, name), " -> "))
+		}
+
+		state[name] = visiting
+		for _, dep := range dependencies[name] {
+			// references to items that are not in the config are rendered as empty strings
+			if _, ok := itemsByName[dep]; !ok {
+				continue
+			}
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = visited
+
+		sorted = append(sorted, itemsByName[name])
+		return nil
+	}
+
+	for _, configGroup := range configGroups {
+		for _, configItem := range configGroup.Items {
+			if err := visit(configItem.Name, []string{}); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return sorted, nil
+}
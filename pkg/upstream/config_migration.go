@@ -0,0 +1,146 @@
+package upstream
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/pkg/errors"
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/template"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// ConfigMigrationChange describes a single config value changed by a ConfigMigration
+type ConfigMigrationChange struct {
+	Action   string
+	Name     string
+	From     string
+	OldValue string
+	NewValue string
+}
+
+func (c ConfigMigrationChange) String() string {
+	switch c.Action {
+	case "rename":
+		return fmt.Sprintf("renamed config value %s to %s", c.From, c.Name)
+	case "transform":
+		return fmt.Sprintf("changed config value %s from %q to %q", c.Name, c.OldValue, c.NewValue)
+	case "remove":
+		return fmt.Sprintf("removed config value %s", c.Name)
+	}
+	return fmt.Sprintf("%s config value %s", c.Action, c.Name)
+}
+
+func findConfigMigration(files []types.UpstreamFile) *kotsv1beta1.ConfigMigration {
+	for _, file := range files {
+		decode := scheme.Codecs.UniversalDeserializer().Decode
+		obj, gvk, err := decode(file.Content, nil, nil)
+		if err != nil {
+			continue
+		}
+
+		if gvk.Group == "kots.io" && gvk.Version == "v1beta1" && gvk.Kind == "ConfigMigration" {
+			return obj.(*kotsv1beta1.ConfigMigration)
+		}
+	}
+
+	return nil
+}
+
+// applyConfigMigration changes values in place: renames first, then transforms, which can read
+// the renamed values, and removals last. Transforms are skipped when their when-condition is false
+// or they would not change the value.
+func applyConfigMigration(migration *kotsv1beta1.ConfigMigration, values map[string]kotsv1beta1.ConfigValue) ([]ConfigMigrationChange, error) {
+	changes := []ConfigMigrationChange{}
+	if migration == nil {
+		return changes, nil
+	}
+
+	for _, rename := range migration.Spec.Renames {
+		value, ok := values[rename.From]
+		if !ok {
+			continue
+		}
+		if _, ok := values[rename.To]; ok {
+			// the new item already has a value, the rename was applied in a previous pull
+			continue
+		}
+
+		values[rename.To] = value
+		delete(values, rename.From)
+		changes = append(changes, ConfigMigrationChange{
+			Action:   "rename",
+			Name:     rename.To,
+			From:     rename.From,
+			OldValue: value.Value,
+			NewValue: value.Value,
+		})
+	}
+
+	templateContext := map[string]template.ItemValue{}
+	for name, value := range values {
+		templateContext[name] = template.ItemValue{
+			Value:   value.Value,
+			Default: value.Default,
+		}
+	}
+	builder := template.Builder{}
+	builder.AddCtx(template.StaticCtx{})
+	builder.AddCtx(template.ConfigCtx{ItemValues: templateContext})
+
+	for _, transform := range migration.Spec.Transforms {
+		if transform.When != "" {
+			renderedWhen, err := builder.RenderTemplate(transform.Name, transform.When)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to render when for config migration of %s", transform.Name)
+			}
+			apply, err := strconv.ParseBool(renderedWhen)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse when for config migration of %s", transform.Name)
+			}
+			if !apply {
+				continue
+			}
+		}
+
+		renderedValue, err := builder.RenderTemplate(transform.Name, transform.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to render config migration of %s", transform.Name)
+		}
+
+		value := values[transform.Name]
+		if renderedValue == "" || renderedValue == value.Value {
+			continue
+		}
+
+		changes = append(changes, ConfigMigrationChange{
+			Action:   "transform",
+			Name:     transform.Name,
+			OldValue: value.Value,
+			NewValue: renderedValue,
+		})
+		value.Value = renderedValue
+		values[transform.Name] = value
+		templateContext[transform.Name] = template.ItemValue{
+			Value:   value.Value,
+			Default: value.Default,
+		}
+	}
+
+	for _, name := range migration.Spec.Removals {
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+
+		delete(values, name)
+		changes = append(changes, ConfigMigrationChange{
+			Action:   "remove",
+			Name:     name,
+			OldValue: value.Value,
+		})
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,220 @@
+package upstream
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+func Test_applyConfigMigration(t *testing.T) {
+	tests := []struct {
+		name            string
+		migration       *kotsv1beta1.ConfigMigration
+		values          map[string]kotsv1beta1.ConfigValue
+		expectedValues  map[string]kotsv1beta1.ConfigValue
+		expectedActions []string
+	}{
+		{
+			name:      "no migration",
+			migration: nil,
+			values: map[string]kotsv1beta1.ConfigValue{
+				"a": {Value: "1"},
+			},
+			expectedValues: map[string]kotsv1beta1.ConfigValue{
+				"a": {Value: "1"},
+			},
+			expectedActions: []string{},
+		},
+		{
+			name: "rename, split and remove",
+			migration: &kotsv1beta1.ConfigMigration{
+				Spec: kotsv1beta1.ConfigMigrationSpec{
+					Renames: []kotsv1beta1.ConfigMigrationRename{
+						{From: "hostname", To: "db_hostname"},
+					},
+					Transforms: []kotsv1beta1.ConfigMigrationTransform{
+						{Name: "db_host", Value: `repl{{ index (Split (ConfigOption "db_url") ":") 0 }}`},
+						{Name: "db_port", Value: `repl{{ index (Split (ConfigOption "db_url") ":") 1 }}`, When: `repl{{ ConfigOptionNotEquals "db_url" "" }}`},
+					},
+					Removals: []string{"db_url"},
+				},
+			},
+			values: map[string]kotsv1beta1.ConfigValue{
+				"hostname": {Value: "example.com"},
+				"db_url":   {Value: "postgres:5432"},
+			},
+			expectedValues: map[string]kotsv1beta1.ConfigValue{
+				"db_hostname": {Value: "example.com"},
+				"db_host":     {Value: "postgres"},
+				"db_port":     {Value: "5432"},
+			},
+			expectedActions: []string{"rename", "transform", "transform", "remove"},
+		},
+		{
+			name: "already migrated",
+			migration: &kotsv1beta1.ConfigMigration{
+				Spec: kotsv1beta1.ConfigMigrationSpec{
+					Renames: []kotsv1beta1.ConfigMigrationRename{
+						{From: "hostname", To: "db_hostname"},
+					},
+					Transforms: []kotsv1beta1.ConfigMigrationTransform{
+						{Name: "db_port", Value: `repl{{ ConfigOption "db_url" }}`},
+					},
+					Removals: []string{"db_url"},
+				},
+			},
+			values: map[string]kotsv1beta1.ConfigValue{
+				"db_hostname": {Value: "example.com"},
+				"db_port":     {Value: "5432"},
+			},
+			expectedValues: map[string]kotsv1beta1.ConfigValue{
+				"db_hostname": {Value: "example.com"},
+				"db_port":     {Value: "5432"},
+			},
+			expectedActions: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			changes, err := applyConfigMigration(test.migration, test.values)
+			req.NoError(err)
+
+			req.Equal(test.expectedValues, test.values)
+
+			actions := []string{}
+			for _, change := range changes {
+				actions = append(actions, change.Action)
+			}
+			req.Equal(test.expectedActions, actions)
+		})
+	}
+}
+
+func Test_WriteUpstreamAppliesConfigMigrationOnce(t *testing.T) {
+	req := require.New(t)
+
+	rootDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(rootDir)
+
+	configValues := func(port string) types.UpstreamFile {
+		return types.UpstreamFile{
+			Path: "userdata/config.yaml",
+			Content: []byte(`apiVersion: kots.io/v1beta1
+kind: ConfigValues
+metadata:
+  name: app
+spec:
+  values:
+    port:
+      value: "` + port + `"`),
+		}
+	}
+	// the migration appends a digit to the port, so it would change the value on every pull
+	migration := types.UpstreamFile{
+		Path: "migration.yaml",
+		Content: []byte(`apiVersion: kots.io/v1beta1
+kind: ConfigMigration
+metadata:
+  name: port-suffix
+spec:
+  transforms:
+  - name: port
+    value: repl{{ ConfigOption "port" }}0`),
+	}
+
+	readPort := func() string {
+		c, err := ioutil.ReadFile(filepath.Join(rootDir, "app", "upstream", "userdata", "config.yaml"))
+		req.NoError(err)
+		obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(c, nil, nil)
+		req.NoError(err)
+		return obj.(*kotsv1beta1.ConfigValues).Spec.Values["port"].Value
+	}
+
+	options := types.WriteOptions{RootDir: rootDir, CreateAppDir: true}
+
+	u := &types.Upstream{Name: "app", Files: []types.UpstreamFile{configValues("80")}}
+	err = WriteUpstream(u, options)
+	req.NoError(err)
+	req.Equal("80", readPort())
+
+	for _, expected := range []string{"changed config value port from \"80\" to \"800\"\n", ""} {
+		report := bytes.Buffer{}
+		options.ReportWriter = &report
+
+		u = &types.Upstream{Name: "app", Files: []types.UpstreamFile{configValues("80"), migration}}
+		err = WriteUpstream(u, options)
+		req.NoError(err)
+		req.Equal("800", readPort())
+		req.Equal(expected, report.String())
+	}
+}
+
+func Test_WriteUpstreamDoesNotRestoreMigratedValues(t *testing.T) {
+	req := require.New(t)
+
+	rootDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(rootDir)
+
+	// the delivered values are seeded from the existing values, so they still have the old items
+	configValues := types.UpstreamFile{
+		Path: "userdata/config.yaml",
+		Content: []byte(`apiVersion: kots.io/v1beta1
+kind: ConfigValues
+metadata:
+  name: app
+spec:
+  values:
+    hostname:
+      value: example.com
+    db_url:
+      value: postgres:5432`),
+	}
+	migration := types.UpstreamFile{
+		Path: "migration.yaml",
+		Content: []byte(`apiVersion: kots.io/v1beta1
+kind: ConfigMigration
+metadata:
+  name: split-db-url
+spec:
+  renames:
+  - from: hostname
+    to: db_hostname
+  transforms:
+  - name: db_port
+    value: repl{{ index (Split (ConfigOption "db_url") ":") 1 }}
+  removals:
+  - db_url`),
+	}
+
+	options := types.WriteOptions{RootDir: rootDir, CreateAppDir: true}
+
+	u := &types.Upstream{Name: "app", Files: []types.UpstreamFile{configValues}}
+	err = WriteUpstream(u, options)
+	req.NoError(err)
+
+	u = &types.Upstream{Name: "app", Files: []types.UpstreamFile{configValues, migration}}
+	err = WriteUpstream(u, options)
+	req.NoError(err)
+
+	c, err := ioutil.ReadFile(filepath.Join(rootDir, "app", "upstream", "userdata", "config.yaml"))
+	req.NoError(err)
+	obj, _, err := scheme.Codecs.UniversalDeserializer().Decode(c, nil, nil)
+	req.NoError(err)
+
+	values := obj.(*kotsv1beta1.ConfigValues).Spec.Values
+	req.Len(values, 2)
+	req.Equal("example.com", values["db_hostname"].Value)
+	req.Equal("5432", values["db_port"].Value)
+}
@@ -2,6 +2,7 @@ package upstream
 
 import (
 	"net/url"
+	"path/filepath"
 
 	"github.com/pkg/errors"
 	kotsv1beta1 "github.com/replicatedhq/kots/kotskinds/apis/kots/v1beta1"
@@ -15,6 +16,7 @@ type FetchOptions struct {
 	UseAppDir           bool
 	HelmRepoName        string
 	HelmRepoURI         string
+	HelmRepoCredentials HelmRepoCredentials
 	HelmOptions         []string
 	LocalPath           string
 	License             *kotsv1beta1.License
@@ -54,7 +56,7 @@ func downloadUpstream(upstreamURI string, fetchOptions *FetchOptions) (*types.Up
 		return nil, errors.Wrap(err, "parse request uri failed")
 	}
 	if u.Scheme == "helm" {
-		return downloadHelm(u, fetchOptions.HelmRepoURI)
+		return downloadHelm(u, fetchOptions.HelmRepoURI, fetchOptions.HelmRepoCredentials, getPreviousUpstreamDir(u, fetchOptions))
 	}
 	if u.Scheme == "replicated" {
 		return downloadReplicated(u, fetchOptions.LocalPath, fetchOptions.RootDir, fetchOptions.UseAppDir, fetchOptions.License, fetchOptions.ConfigValues, pickCursor(fetchOptions), pickVersionLabel(fetchOptions), cipher)
@@ -88,3 +90,22 @@ func pickCursor(fetchOptions *FetchOptions) ReplicatedCursor {
 		Cursor:      fetchOptions.CurrentCursor,
 	}
 }
+
+// getPreviousUpstreamDir returns the upstream directory written by a previous pull, which
+// is named after the chart when an app dir is used
+func getPreviousUpstreamDir(u *url.URL, fetchOptions *FetchOptions) string {
+	if fetchOptions.RootDir == "" {
+		return ""
+	}
+
+	if !fetchOptions.UseAppDir {
+		return filepath.Join(fetchOptions.RootDir, "upstream")
+	}
+
+	_, chartName, _, err := parseHelmURL(u)
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(fetchOptions.RootDir, chartName, "upstream")
+}
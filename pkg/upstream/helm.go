@@ -11,6 +11,7 @@ import (
 	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
 
 	"github.com/Masterminds/semver"
@@ -18,15 +19,24 @@ import (
 	"github.com/replicatedhq/kots/pkg/upstream/types"
 	"github.com/replicatedhq/kots/pkg/util"
 	"k8s.io/helm/cmd/helm/search"
-	"k8s.io/helm/pkg/downloader"
 	"k8s.io/helm/pkg/getter"
 	"k8s.io/helm/pkg/helm/environment"
-	"k8s.io/helm/pkg/helm/helmpath"
 	"k8s.io/helm/pkg/repo"
 )
 
-func getUpdatesHelm(u *url.URL, repoURI string) ([]Update, error) {
-	repoName, chartName, _, err := parseHelmURL(u)
+// HelmRepoCredentials are used to authenticate to a private helm repo
+type HelmRepoCredentials struct {
+	Username string
+	Password string
+	CAFile   string
+	CertFile string
+	KeyFile  string
+}
+
+// getUpdatesHelm returns the versions of the chart that are newer than the current cursor
+// and match the version constraint in the uri, if any, from oldest to newest
+func getUpdatesHelm(u *url.URL, repoURI string, credentials HelmRepoCredentials, currentCursor string) ([]Update, error) {
+	repoName, chartName, chartVersion, err := parseHelmURL(u)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse helm uri")
 	}
@@ -37,23 +47,34 @@ func getUpdatesHelm(u *url.URL, repoURI string) ([]Update, error) {
 	}
 	defer os.RemoveAll(helmHome)
 
-	i, err := helmLoadRepositoriesIndex(helmHome, repoName, repoURI)
+	i, err := helmLoadRepositoriesIndex(helmHome, repoName, repoURI, credentials)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load helm repositories")
 	}
 
-	var updates []Update
-	for _, result := range i.All() {
-		if result.Chart.GetName() != chartName {
+	versions, err := matchHelmChartVersions(getHelmChartVersions(i, chartName), chartVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to match chart versions")
+	}
+
+	var currentVersion *semver.Version
+	if currentCursor != "" {
+		// a cursor that isn't a version can't be compared, so every matching version is an update
+		currentVersion, _ = semver.NewVersion(currentCursor)
+	}
+
+	updates := []Update{}
+	for _, version := range versions {
+		if currentVersion != nil && !version.GreaterThan(currentVersion) {
 			continue
 		}
 
-		updates = append(updates, Update{Cursor: result.Chart.GetVersion()})
+		updates = append(updates, Update{Cursor: version.Original()})
 	}
 	return updates, nil
 }
 
-func downloadHelm(u *url.URL, repoURI string) (*types.Upstream, error) {
+func downloadHelm(u *url.URL, repoURI string, credentials HelmRepoCredentials, previousUpstreamDir string) (*types.Upstream, error) {
 	repoName, chartName, chartVersion, err := parseHelmURL(u)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to parse helm uri")
@@ -69,30 +90,22 @@ func downloadHelm(u *url.URL, repoURI string) (*types.Upstream, error) {
 	}
 	defer os.RemoveAll(helmHome)
 
-	i, err := helmLoadRepositoriesIndex(helmHome, repoName, repoURI)
+	i, err := helmLoadRepositoriesIndex(helmHome, repoName, repoURI, credentials)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to load helm repositories")
 	}
 
-	if chartVersion == "" {
-		highestChartVersion := semver.MustParse("0.0.0")
-		for _, result := range i.All() {
-			if result.Chart.GetName() != chartName {
-				continue
-			}
-
-			v, err := semver.NewVersion(result.Chart.GetVersion())
-			if err != nil {
-				return nil, errors.Wrap(err, "unable to parse chart version")
-			}
-
-			if v.GreaterThan(highestChartVersion) {
-				highestChartVersion = v
-			}
+	versions, err := matchHelmChartVersions(getHelmChartVersions(i, chartName), chartVersion)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to match chart versions")
+	}
+	if len(versions) == 0 {
+		if chartVersion == "" {
+			return nil, errors.Errorf("no released version of chart %s found", chartName)
 		}
-
-		chartVersion = highestChartVersion.String()
+		return nil, errors.Errorf("no version of chart %s matches %q", chartName, chartVersion)
 	}
+	chartVersion = versions[len(versions)-1].Original()
 
 	for _, result := range i.All() {
 		if result.Chart.GetName() != chartName {
@@ -103,33 +116,47 @@ func downloadHelm(u *url.URL, repoURI string) (*types.Upstream, error) {
 			continue
 		}
 
-		dl := downloader.ChartDownloader{
-			HelmHome: helmpath.Home(helmHome),
-			Out:      os.Stdout,
-			Getters:  getter.All(environment.EnvSettings{}),
-		}
-
 		archiveDir, err := ioutil.TempDir("", "archive")
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to create archive directory for chart")
 		}
 		defer os.RemoveAll(archiveDir)
 
-		chartRef, err := repo.FindChartInRepoURL(repoURI, result.Chart.GetName(), chartVersion, "", "", "", getter.All(environment.EnvSettings{}))
+		chartRef, err := repo.FindChartInAuthRepoURL(repoURI, credentials.Username, credentials.Password, result.Chart.GetName(), chartVersion, credentials.CertFile, credentials.KeyFile, credentials.CAFile, getter.All(environment.EnvSettings{}))
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to find chart in repo url")
 		}
 
-		_, _, err = dl.DownloadTo(chartRef, result.Chart.GetVersion(), archiveDir)
+		archive, err := downloadHelmChartArchive(chartRef, credentialsForURL(repoURI, chartRef, credentials))
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to download chart")
 		}
 
+		if err := ioutil.WriteFile(path.Join(archiveDir, fmt.Sprintf("%s-%s.tgz", chartName, chartVersion)), archive, 0644); err != nil {
+			return nil, errors.Wrap(err, "failed to write chart archive")
+		}
+
 		upstream, err := chartArchiveToSparseUpstream(path.Join(archiveDir, fmt.Sprintf("%s-%s.tgz", chartName, chartVersion)))
 		if err != nil {
 			return nil, errors.Wrap(err, "failed to parse chart archive as upstream")
 		}
 
+		previousLock, err := readPreviousHelmLock(previousUpstreamDir, upstream.Files)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read previous lock file")
+		}
+
+		repos := []helmRepo{
+			{
+				Name:        repoName,
+				URI:         repoURI,
+				Credentials: credentials,
+			},
+		}
+		if err := resolveHelmDependencies(upstream, previousLock, repos); err != nil {
+			return nil, errors.Wrap(err, "failed to resolve chart dependencies")
+		}
+
 		upstream.URI = u.RequestURI()
 		upstream.Name = chartName
 		upstream.UpdateCursor = chartVersion
@@ -141,6 +168,48 @@ func downloadHelm(u *url.URL, repoURI string) (*types.Upstream, error) {
 	return nil, errors.New("chart version not found")
 }
 
+func getHelmChartVersions(i *search.Index, chartName string) []string {
+	versions := []string{}
+	for _, result := range i.All() {
+		if result.Chart.GetName() != chartName {
+			continue
+		}
+
+		versions = append(versions, result.Chart.GetVersion())
+	}
+	return versions
+}
+
+// matchHelmChartVersions returns the versions that match the constraint, sorted from oldest to newest.
+// An empty constraint matches every version. Prereleases only match a constraint that includes a
+// prerelease, such as an exact prerelease version or ">= 1.0.0-0". Versions that aren't valid semver are ignored.
+func matchHelmChartVersions(versions []string, constraint string) ([]*semver.Version, error) {
+	if constraint == "" {
+		constraint = "*"
+	}
+
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse version constraint %q", constraint)
+	}
+
+	matched := []*semver.Version{}
+	for _, version := range versions {
+		v, err := semver.NewVersion(version)
+		if err != nil {
+			continue
+		}
+
+		if c.Check(v) {
+			matched = append(matched, v)
+		}
+	}
+
+	sort.Sort(semver.Collection(matched))
+
+	return matched, nil
+}
+
 func chartArchiveToSparseUpstream(chartArchivePath string) (*types.Upstream, error) {
 	files, err := readTarGz(chartArchivePath)
 	if err != nil {
@@ -155,7 +224,7 @@ func chartArchiveToSparseUpstream(chartArchivePath string) (*types.Upstream, err
 	return upstream, nil
 }
 
-func helmLoadRepositoriesIndex(helmHome, repoName, repoURI string) (*search.Index, error) {
+func helmLoadRepositoriesIndex(helmHome, repoName, repoURI string, credentials HelmRepoCredentials) (*search.Index, error) {
 	if repoURI == "" {
 		repoURI = getKnownHelmRepoURI(repoName)
 	}
@@ -189,9 +258,14 @@ repositories: []`
 	}
 
 	c := repo.Entry{
-		Name:  repoName,
-		Cache: repoIndexFile.Name(),
-		URL:   repoURI,
+		Name:     repoName,
+		Cache:    repoIndexFile.Name(),
+		URL:      repoURI,
+		Username: credentials.Username,
+		Password: credentials.Password,
+		CAFile:   credentials.CAFile,
+		CertFile: credentials.CertFile,
+		KeyFile:  credentials.KeyFile,
 	}
 	r, err := repo.NewChartRepository(&c, getter.All(environment.EnvSettings{}))
 	if err != nil {
@@ -235,6 +309,26 @@ func parseHelmURL(u *url.URL) (string, string, string, error) {
 	return repo, chartName, chartVersion, nil
 }
 
+// credentialsForURL only keeps the username and password when the url is on the same host
+// as the repo, so that they aren't sent to where the chart archives are hosted
+func credentialsForURL(repoURI string, u string, credentials HelmRepoCredentials) HelmRepoCredentials {
+	parsedRepoURI, err := url.Parse(repoURI)
+	if err != nil {
+		return HelmRepoCredentials{}
+	}
+	parsedURL, err := url.Parse(u)
+	if err != nil {
+		return HelmRepoCredentials{}
+	}
+
+	if parsedRepoURI.Host != parsedURL.Host {
+		credentials.Username = ""
+		credentials.Password = ""
+	}
+
+	return credentials
+}
+
 func getKnownHelmRepoURI(repoName string) string {
 	val, ok := KnownRepos[repoName]
 	if !ok {
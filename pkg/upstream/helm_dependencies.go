@@ -0,0 +1,463 @@
+package upstream
+
+import (
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ghodss/yaml"
+	"github.com/pkg/errors"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/getter"
+	"k8s.io/helm/pkg/helm/environment"
+	"k8s.io/helm/pkg/repo"
+	"k8s.io/helm/pkg/resolver"
+)
+
+// helmRepo is a repo that dependencies can be fetched from
+type helmRepo struct {
+	Name        string
+	URI         string
+	Credentials HelmRepoCredentials
+}
+
+// helmChartfile is the part of Chart.yaml that declares dependencies in apiVersion v2 charts
+type helmChartfile struct {
+	APIVersion   string                  `json:"apiVersion"`
+	Dependencies []*chartutil.Dependency `json:"dependencies,omitempty"`
+}
+
+// getHelmDependencies returns the dependencies of the chart and the name of the lock file
+// that helm uses for them, Chart.lock for apiVersion v2 charts and requirements.lock otherwise
+func getHelmDependencies(files []types.UpstreamFile) (*chartutil.Requirements, string, error) {
+	for _, file := range files {
+		if file.Path != "Chart.yaml" {
+			continue
+		}
+
+		chartfile := helmChartfile{}
+		if err := yaml.Unmarshal(file.Content, &chartfile); err != nil {
+			return nil, "", errors.Wrap(err, "failed to unmarshal Chart.yaml")
+		}
+
+		if chartfile.APIVersion == "v2" {
+			if len(chartfile.Dependencies) == 0 {
+				return nil, "", nil
+			}
+			return &chartutil.Requirements{Dependencies: chartfile.Dependencies}, "Chart.lock", nil
+		}
+	}
+
+	for _, file := range files {
+		if file.Path != "requirements.yaml" {
+			continue
+		}
+
+		requirements := chartutil.Requirements{}
+		if err := yaml.Unmarshal(file.Content, &requirements); err != nil {
+			return nil, "", errors.Wrap(err, "failed to unmarshal requirements.yaml")
+		}
+
+		if len(requirements.Dependencies) == 0 {
+			return nil, "", nil
+		}
+		return &requirements, "requirements.lock", nil
+	}
+
+	return nil, "", nil
+}
+
+// resolveHelmDependencies fetches the dependencies of the chart that aren't vendored in charts/
+// and records the versions in the chart's lock file. A lock file in the chart, or one from
+// the previous pull, is used when it was generated from the same dependencies.
+func resolveHelmDependencies(u *types.Upstream, previousLock []byte, repos []helmRepo) error {
+	requirements, lockName, err := getHelmDependencies(u.Files)
+	if err != nil {
+		return errors.Wrap(err, "failed to get dependencies")
+	}
+	if requirements == nil {
+		return nil
+	}
+
+	digest, err := resolver.HashReq(requirements)
+	if err != nil {
+		return errors.Wrap(err, "failed to hash dependencies")
+	}
+
+	var chartLock []byte
+	for _, file := range u.Files {
+		if file.Path == lockName {
+			chartLock = file.Content
+		}
+	}
+
+	lock, err := parseHelmLock(chartLock, digest)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse chart lock file")
+	}
+	if lock == nil {
+		lock, err = parseHelmLock(previousLock, digest)
+		if err != nil {
+			return errors.Wrap(err, "failed to parse previous lock file")
+		}
+	}
+
+	lockedVersions := map[string]string{}
+	if lock != nil {
+		for _, dependency := range lock.Dependencies {
+			lockedVersions[dependency.Name] = dependency.Version
+		}
+	}
+
+	indexes := map[string]*repo.IndexFile{}
+	resolved := []*chartutil.Dependency{}
+	fetched := false
+	for _, dependency := range requirements.Dependencies {
+		version := dependency.Version
+		if lockedVersion, ok := lockedVersions[dependency.Name]; ok {
+			version = lockedVersion
+		}
+
+		if isHelmDependencyVendored(u.Files, dependency.Name) {
+			resolved = append(resolved, &chartutil.Dependency{
+				Name:       dependency.Name,
+				Repository: dependency.Repository,
+				Version:    version,
+				Alias:      dependency.Alias,
+			})
+			continue
+		}
+		fetched = true
+
+		var dependencyRepo helmRepo
+		if isSiblingHelmDependency(dependency) {
+			// the chart's upstream is its repo, sibling charts are published there next to it
+			if len(repos) == 0 {
+				return errors.Errorf("dependency %s in %s is outside of the chart and there is no repo to fetch it from", dependency.Name, dependency.Repository)
+			}
+			dependencyRepo = repos[0]
+		} else if strings.HasPrefix(dependency.Repository, "file://") {
+			files, err := readLocalHelmDependency(u.Files, dependency)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read dependency %s", dependency.Name)
+			}
+
+			u.Files = append(u.Files, files...)
+			resolved = append(resolved, &chartutil.Dependency{
+				Name:       dependency.Name,
+				Repository: dependency.Repository,
+				Version:    dependency.Version,
+				Alias:      dependency.Alias,
+			})
+			continue
+		} else {
+			dependencyRepo, err = getHelmDependencyRepo(dependency.Repository, repos)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get repo for dependency %s", dependency.Name)
+			}
+		}
+
+		index, ok := indexes[dependencyRepo.URI]
+		if !ok {
+			index, err = downloadHelmRepoIndex(dependencyRepo.URI, dependencyRepo.Credentials)
+			if err != nil {
+				return errors.Wrapf(err, "failed to download index of %s", dependencyRepo.URI)
+			}
+			indexes[dependencyRepo.URI] = index
+		}
+
+		chartVersion, err := index.Get(dependency.Name, version)
+		if err != nil {
+			return errors.Wrapf(err, "failed to find version %s of dependency %s", version, dependency.Name)
+		}
+		if len(chartVersion.URLs) == 0 {
+			return errors.Errorf("version %s of dependency %s has no downloadable urls", chartVersion.Version, dependency.Name)
+		}
+
+		chartURL, err := repo.ResolveReferenceURL(dependencyRepo.URI, chartVersion.URLs[0])
+		if err != nil {
+			return errors.Wrapf(err, "failed to resolve url of dependency %s", dependency.Name)
+		}
+
+		archive, err := downloadHelmChartArchive(chartURL, credentialsForURL(dependencyRepo.URI, chartURL, dependencyRepo.Credentials))
+		if err != nil {
+			return errors.Wrapf(err, "failed to download dependency %s", dependency.Name)
+		}
+
+		u.Files = append(u.Files, types.UpstreamFile{
+			Path:    path.Join("charts", dependency.Name+"-"+chartVersion.Version+".tgz"),
+			Content: archive,
+		})
+		resolved = append(resolved, &chartutil.Dependency{
+			Name:       dependency.Name,
+			Repository: dependency.Repository,
+			Version:    chartVersion.Version,
+			Alias:      dependency.Alias,
+		})
+	}
+
+	if !fetched {
+		// everything is vendored in the chart, there's nothing to record
+		return nil
+	}
+
+	// keep the generated time of a lock that was reused, so that a re-pull doesn't change it
+	generated := time.Now()
+	if lock != nil {
+		generated = lock.Generated
+	}
+
+	b, err := yaml.Marshal(chartutil.RequirementsLock{
+		Generated:    generated,
+		Digest:       digest,
+		Dependencies: resolved,
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal lock file")
+	}
+
+	for i, file := range u.Files {
+		if file.Path == lockName {
+			u.Files[i].Content = b
+			return nil
+		}
+	}
+	u.Files = append(u.Files, types.UpstreamFile{
+		Path:    lockName,
+		Content: b,
+	})
+
+	return nil
+}
+
+// readPreviousHelmLock reads the lock file written by the previous pull of the chart, if any
+func readPreviousHelmLock(previousUpstreamDir string, files []types.UpstreamFile) ([]byte, error) {
+	if previousUpstreamDir == "" {
+		return nil, nil
+	}
+
+	_, lockName, err := getHelmDependencies(files)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get dependencies")
+	}
+	if lockName == "" {
+		return nil, nil
+	}
+
+	content, err := ioutil.ReadFile(filepath.Join(previousUpstreamDir, lockName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, errors.Wrap(err, "failed to read lock file")
+	}
+
+	return content, nil
+}
+
+// parseHelmLock returns the lock, or nil if there is no lock or it was generated
+// for different dependencies
+func parseHelmLock(content []byte, digest string) (*chartutil.RequirementsLock, error) {
+	if len(content) == 0 {
+		return nil, nil
+	}
+
+	lock := chartutil.RequirementsLock{}
+	if err := yaml.Unmarshal(content, &lock); err != nil {
+		return nil, errors.Wrap(err, "failed to unmarshal")
+	}
+
+	if lock.Digest != digest {
+		return nil, nil
+	}
+
+	return &lock, nil
+}
+
+func isHelmDependencyVendored(files []types.UpstreamFile, name string) bool {
+	for _, file := range files {
+		if file.Path == path.Join("charts", name, "Chart.yaml") {
+			return true
+		}
+
+		dir, filename := path.Split(file.Path)
+		if dir == "charts/" && strings.HasPrefix(filename, name+"-") && strings.HasSuffix(filename, ".tgz") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// isSiblingHelmDependency returns true for a file:// dependency with a relative path outside of the chart,
+// such as file://../common
+func isSiblingHelmDependency(dependency *chartutil.Dependency) bool {
+	if !strings.HasPrefix(dependency.Repository, "file://") {
+		return false
+	}
+
+	dependencyPath := strings.TrimPrefix(dependency.Repository, "file://")
+	if filepath.IsAbs(dependencyPath) {
+		return false
+	}
+
+	cleaned := path.Clean(dependencyPath)
+	return cleaned == ".." || strings.HasPrefix(cleaned, "../")
+}
+
+// readLocalHelmDependency copies a file:// dependency into charts/. Relative paths are
+// looked up in the chart itself, absolute paths on the local filesystem. Relative paths
+// outside of the chart are fetched from the chart's repo instead.
+func readLocalHelmDependency(files []types.UpstreamFile, dependency *chartutil.Dependency) ([]types.UpstreamFile, error) {
+	dependencyPath := strings.TrimPrefix(dependency.Repository, "file://")
+	dependencyDir := path.Join("charts", dependency.Name)
+
+	dependencyFiles := []types.UpstreamFile{}
+
+	if filepath.IsAbs(dependencyPath) {
+		err := filepath.Walk(dependencyPath, func(p string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if info.IsDir() {
+				return nil
+			}
+
+			content, err := ioutil.ReadFile(p)
+			if err != nil {
+				return errors.Wrapf(err, "failed to read %s", p)
+			}
+
+			rel, err := filepath.Rel(dependencyPath, p)
+			if err != nil {
+				return errors.Wrapf(err, "failed to get relative path of %s", p)
+			}
+
+			dependencyFiles = append(dependencyFiles, types.UpstreamFile{
+				Path:    path.Join(dependencyDir, filepath.ToSlash(rel)),
+				Content: content,
+			})
+			return nil
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read %s", dependencyPath)
+		}
+	} else {
+		prefix := path.Clean(dependencyPath) + "/"
+		for _, file := range files {
+			if !strings.HasPrefix(file.Path, prefix) {
+				continue
+			}
+
+			dependencyFiles = append(dependencyFiles, types.UpstreamFile{
+				Path:    path.Join(dependencyDir, strings.TrimPrefix(file.Path, prefix)),
+				Content: file.Content,
+			})
+		}
+	}
+
+	if len(dependencyFiles) == 0 {
+		return nil, errors.Errorf("no files found in %s", dependency.Repository)
+	}
+
+	return dependencyFiles, nil
+}
+
+// getHelmDependencyRepo returns the repo of a dependency, which can be a url or a repo name
+// in the form @name or alias:name. Credentials are only used for the repos they were given for.
+func getHelmDependencyRepo(repository string, repos []helmRepo) (helmRepo, error) {
+	repoName := ""
+	if strings.HasPrefix(repository, "@") {
+		repoName = strings.TrimPrefix(repository, "@")
+	} else if strings.HasPrefix(repository, "alias:") {
+		repoName = strings.TrimPrefix(repository, "alias:")
+	}
+
+	if repoName == "" {
+		if repository == "" {
+			return helmRepo{}, errors.New("dependency has no repository and is not in charts/")
+		}
+
+		for _, r := range repos {
+			if strings.TrimSuffix(r.URI, "/") == strings.TrimSuffix(repository, "/") {
+				return r, nil
+			}
+		}
+		return helmRepo{URI: repository}, nil
+	}
+
+	for _, r := range repos {
+		if r.Name == repoName {
+			return r, nil
+		}
+	}
+	if repoURI := getKnownHelmRepoURI(repoName); repoURI != "" {
+		return helmRepo{Name: repoName, URI: repoURI}, nil
+	}
+
+	return helmRepo{}, errors.Errorf("unknown helm repo %q", repoName)
+}
+
+func downloadHelmRepoIndex(repoURI string, credentials HelmRepoCredentials) (*repo.IndexFile, error) {
+	indexFile, err := ioutil.TempFile("", "index")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create temporary index file")
+	}
+	defer os.Remove(indexFile.Name())
+
+	c := repo.Entry{
+		URL:      repoURI,
+		Cache:    indexFile.Name(),
+		Username: credentials.Username,
+		Password: credentials.Password,
+		CAFile:   credentials.CAFile,
+		CertFile: credentials.CertFile,
+		KeyFile:  credentials.KeyFile,
+	}
+	r, err := repo.NewChartRepository(&c, getter.All(environment.EnvSettings{}))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create chart repository")
+	}
+	if err := r.DownloadIndexFile(""); err != nil {
+		return nil, errors.Wrap(err, "failed to download index file")
+	}
+
+	index, err := repo.LoadIndexFile(indexFile.Name())
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load index file")
+	}
+
+	return index, nil
+}
+
+func downloadHelmChartArchive(chartURL string, credentials HelmRepoCredentials) ([]byte, error) {
+	u, err := url.Parse(chartURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse chart url")
+	}
+
+	provider, err := getter.ByScheme(u.Scheme, environment.EnvSettings{})
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get getter for %s", u.Scheme)
+	}
+
+	g, err := provider.New(chartURL, credentials.CertFile, credentials.KeyFile, credentials.CAFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create getter")
+	}
+	if httpGetter, ok := g.(*getter.HttpGetter); ok && credentials.Username != "" {
+		httpGetter.SetCredentials(credentials.Username, credentials.Password)
+	}
+
+	buf, err := g.Get(chartURL)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get chart")
+	}
+
+	return buf.Bytes(), nil
+}
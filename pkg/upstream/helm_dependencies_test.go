@@ -0,0 +1,197 @@
+package upstream
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ghodss/yaml"
+	"github.com/replicatedhq/kots/pkg/upstream/types"
+	"github.com/stretchr/testify/require"
+	"k8s.io/helm/pkg/chartutil"
+	"k8s.io/helm/pkg/resolver"
+)
+
+func newTestHelmRepo(versions []string) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.yaml", func(w http.ResponseWriter, r *http.Request) {
+		index := "apiVersion: v1\nentries:\n  sub:\n"
+		for _, version := range versions {
+			index += fmt.Sprintf("  - name: sub\n    version: %s\n    urls:\n    - charts/sub-%s.tgz\n", version, version)
+		}
+		w.Write([]byte(index))
+	})
+	mux.HandleFunc("/charts/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(strings.TrimPrefix(r.URL.Path, "/charts/")))
+	})
+	return httptest.NewServer(mux)
+}
+
+func Test_resolveHelmDependencies(t *testing.T) {
+	server := newTestHelmRepo([]string{"1.2.0", "1.2.3", "1.3.0"})
+	defer server.Close()
+
+	requirements := `dependencies:
+- name: sub
+  version: ~1.2.0
+  repository: "@test"
+- name: local
+  version: 0.1.0
+  repository: file://deps/local
+`
+
+	newUpstream := func() *types.Upstream {
+		return &types.Upstream{
+			Files: []types.UpstreamFile{
+				{Path: "Chart.yaml", Content: []byte("apiVersion: v1\nname: umbrella\nversion: 0.1.0\n")},
+				{Path: "requirements.yaml", Content: []byte(requirements)},
+				{Path: "deps/local/Chart.yaml", Content: []byte("apiVersion: v1\nname: local\nversion: 0.1.0\n")},
+			},
+		}
+	}
+
+	requirementsForDigest, _, err := getHelmDependencies(newUpstream().Files)
+	require.NoError(t, err)
+	digest, err := resolver.HashReq(requirementsForDigest)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name          string
+		previousLock  string
+		expectedFiles map[string]string
+		expectedLock  map[string]string
+	}{
+		{
+			name:         "resolves the highest matching version",
+			previousLock: "",
+			expectedFiles: map[string]string{
+				"charts/sub-1.2.3.tgz":    "sub-1.2.3.tgz",
+				"charts/local/Chart.yaml": "apiVersion: v1\nname: local\nversion: 0.1.0\n",
+			},
+			expectedLock: map[string]string{
+				"sub":   "1.2.3",
+				"local": "0.1.0",
+			},
+		},
+		{
+			name: "uses the previous lock",
+			previousLock: fmt.Sprintf(`digest: %s
+dependencies:
+- name: sub
+  version: 1.2.0
+  repository: "@test"
+`, digest),
+			expectedFiles: map[string]string{
+				"charts/sub-1.2.0.tgz":    "sub-1.2.0.tgz",
+				"charts/local/Chart.yaml": "apiVersion: v1\nname: local\nversion: 0.1.0\n",
+			},
+			expectedLock: map[string]string{
+				"sub":   "1.2.0",
+				"local": "0.1.0",
+			},
+		},
+		{
+			name: "ignores a lock for other dependencies",
+			previousLock: `digest: sha256:other
+dependencies:
+- name: sub
+  version: 1.2.0
+  repository: "@test"
+`,
+			expectedFiles: map[string]string{
+				"charts/sub-1.2.3.tgz":    "sub-1.2.3.tgz",
+				"charts/local/Chart.yaml": "apiVersion: v1\nname: local\nversion: 0.1.0\n",
+			},
+			expectedLock: map[string]string{
+				"sub":   "1.2.3",
+				"local": "0.1.0",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			u := newUpstream()
+			err := resolveHelmDependencies(u, []byte(test.previousLock), []helmRepo{{Name: "test", URI: server.URL}})
+			req.NoError(err)
+
+			files := map[string]string{}
+			var lock []byte
+			for _, file := range u.Files {
+				if strings.HasPrefix(file.Path, "charts/") {
+					files[file.Path] = string(file.Content)
+				}
+				if file.Path == "requirements.lock" {
+					lock = file.Content
+				}
+			}
+			req.Equal(test.expectedFiles, files)
+
+			requirementsLock := chartutil.RequirementsLock{}
+			req.NoError(yaml.Unmarshal(lock, &requirementsLock))
+			req.Equal(digest, requirementsLock.Digest)
+
+			lockedVersions := map[string]string{}
+			for _, dependency := range requirementsLock.Dependencies {
+				lockedVersions[dependency.Name] = dependency.Version
+			}
+			req.Equal(test.expectedLock, lockedVersions)
+		})
+	}
+}
+
+func Test_resolveHelmDependenciesSiblingAndAlias(t *testing.T) {
+	req := require.New(t)
+
+	server := newTestHelmRepo([]string{"1.2.0", "1.2.3"})
+	defer server.Close()
+
+	// the chart was packaged from a dir next to sub, which is published to the same repo
+	u := &types.Upstream{
+		Files: []types.UpstreamFile{
+			{Path: "Chart.yaml", Content: []byte(`apiVersion: v2
+name: umbrella
+version: 0.1.0
+dependencies:
+- name: sub
+  version: ~1.2.0
+  repository: file://../sub
+  alias: database
+- name: local
+  version: 0.1.0
+  repository: file://deps/local
+  alias: cache
+`)},
+			{Path: "deps/local/Chart.yaml", Content: []byte("apiVersion: v1\nname: local\nversion: 0.1.0\n")},
+		},
+	}
+
+	err := resolveHelmDependencies(u, nil, []helmRepo{{Name: "test", URI: server.URL}})
+	req.NoError(err)
+
+	files := map[string]string{}
+	var lock []byte
+	for _, file := range u.Files {
+		if strings.HasPrefix(file.Path, "charts/") {
+			files[file.Path] = string(file.Content)
+		}
+		if file.Path == "Chart.lock" {
+			lock = file.Content
+		}
+	}
+	req.Equal(map[string]string{
+		"charts/sub-1.2.3.tgz":    "sub-1.2.3.tgz",
+		"charts/local/Chart.yaml": "apiVersion: v1\nname: local\nversion: 0.1.0\n",
+	}, files)
+
+	requirementsLock := chartutil.RequirementsLock{}
+	req.NoError(yaml.Unmarshal(lock, &requirementsLock))
+	req.Equal([]*chartutil.Dependency{
+		{Name: "sub", Version: "1.2.3", Repository: "file://../sub", Alias: "database"},
+		{Name: "local", Version: "0.1.0", Repository: "file://deps/local", Alias: "cache"},
+	}, requirementsLock.Dependencies)
+}
@@ -1,8 +1,25 @@
 package upstream
 
 import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"os"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -23,6 +40,13 @@ func Test_parseHelmURL(t *testing.T) {
 			expectedChartName:    "mysql",
 			expectedChartVersion: "",
 		},
+		{
+			name:                 "stable/redis@~10.5",
+			uri:                  "helm://stable/redis@~10.5",
+			expectedRepo:         "stable",
+			expectedChartName:    "redis",
+			expectedChartVersion: "~10.5",
+		},
 		{
 			name:                 "stable/mysql@1.3.1",
 			uri:                  "helm://stable/mysql@1.3.1",
@@ -47,3 +71,195 @@ func Test_parseHelmURL(t *testing.T) {
 		})
 	}
 }
+
+func Test_helmAuthenticatedRepo(t *testing.T) {
+	req := require.New(t)
+
+	tmpDir, err := ioutil.TempDir("", "kots")
+	req.NoError(err)
+	defer os.RemoveAll(tmpDir)
+
+	clientCertFile, clientKeyFile, clientCert := writeTestClientCert(t, tmpDir)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	chartArchive := newTestChartArchive(t, "private", "0.1.0")
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/index.yaml", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`apiVersion: v1
+entries:
+  private:
+  - name: private
+    version: 0.1.0
+    urls:
+    - charts/private-0.1.0.tgz
+`))
+	})
+	mux.HandleFunc("/charts/private-0.1.0.tgz", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(chartArchive)
+	})
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok := r.BasicAuth()
+		if !ok || username != "user" || password != "pass" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	server.TLS = &tls.Config{
+		ClientAuth: tls.RequireAndVerifyClientCert,
+		ClientCAs:  clientCAs,
+	}
+	server.StartTLS()
+	defer server.Close()
+
+	caFile := filepath.Join(tmpDir, "ca.pem")
+	req.NoError(ioutil.WriteFile(caFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw}), 0644))
+
+	credentials := HelmRepoCredentials{
+		Username: "user",
+		Password: "pass",
+		CAFile:   caFile,
+		CertFile: clientCertFile,
+		KeyFile:  clientKeyFile,
+	}
+
+	u, err := url.ParseRequestURI("helm://private/private")
+	req.NoError(err)
+
+	updates, err := getUpdatesHelm(u, server.URL, credentials, "")
+	req.NoError(err)
+	req.Equal([]Update{{Cursor: "0.1.0"}}, updates)
+
+	updates, err = getUpdatesHelm(u, server.URL, credentials, "0.1.0")
+	req.NoError(err)
+	req.Empty(updates)
+
+	upstream, err := downloadHelm(u, server.URL, credentials, "")
+	req.NoError(err)
+	req.Equal("0.1.0", upstream.UpdateCursor)
+	req.Len(upstream.Files, 2)
+
+	noPassword := credentials
+	noPassword.Password = ""
+	_, err = getUpdatesHelm(u, server.URL, noPassword, "")
+	req.Error(err)
+
+	noClientCert := credentials
+	noClientCert.CertFile = ""
+	noClientCert.KeyFile = ""
+	_, err = downloadHelm(u, server.URL, noClientCert, "")
+	req.Error(err)
+}
+
+func writeTestClientCert(t *testing.T, dir string) (string, string, *x509.Certificate) {
+	req := require.New(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	req.NoError(err)
+
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kots"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	req.NoError(err)
+	cert, err := x509.ParseCertificate(der)
+	req.NoError(err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	req.NoError(err)
+
+	certFile := filepath.Join(dir, "client.pem")
+	keyFile := filepath.Join(dir, "client-key.pem")
+	req.NoError(ioutil.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0644))
+	req.NoError(ioutil.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	return certFile, keyFile, cert
+}
+
+func newTestChartArchive(t *testing.T, name string, version string) []byte {
+	req := require.New(t)
+
+	files := map[string]string{
+		name + "/Chart.yaml":               "apiVersion: v1\nname: " + name + "\nversion: " + version + "\n",
+		name + "/templates/configmap.yaml": "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: " + name + "\n",
+	}
+
+	var buf bytes.Buffer
+	gzipWriter := gzip.NewWriter(&buf)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for filename, content := range files {
+		req.NoError(tarWriter.WriteHeader(&tar.Header{
+			Name:     filename,
+			Mode:     0644,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tarWriter.Write([]byte(content))
+		req.NoError(err)
+	}
+	req.NoError(tarWriter.Close())
+	req.NoError(gzipWriter.Close())
+
+	return buf.Bytes()
+}
+
+func Test_matchHelmChartVersions(t *testing.T) {
+	versions := []string{"10.5.1", "9.0.0", "10.6.0-rc.1", "10.5.7", "10.6.0", "11.0.0", "not-a-version"}
+
+	tests := []struct {
+		name       string
+		constraint string
+		expected   []string
+	}{
+		{
+			name:       "no constraint",
+			constraint: "",
+			expected:   []string{"9.0.0", "10.5.1", "10.5.7", "10.6.0", "11.0.0"},
+		},
+		{
+			name:       "patch releases",
+			constraint: "~10.5",
+			expected:   []string{"10.5.1", "10.5.7"},
+		},
+		{
+			name:       "minor releases",
+			constraint: "^10.5.0",
+			expected:   []string{"10.5.1", "10.5.7", "10.6.0"},
+		},
+		{
+			name:       "exact prerelease",
+			constraint: "10.6.0-rc.1",
+			expected:   []string{"10.6.0-rc.1"},
+		},
+		{
+			name:       "prereleases included",
+			constraint: ">= 10.6.0-0",
+			expected:   []string{"10.6.0-rc.1", "10.6.0", "11.0.0"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req := require.New(t)
+
+			matched, err := matchHelmChartVersions(versions, test.constraint)
+			req.NoError(err)
+
+			actual := []string{}
+			for _, v := range matched {
+				actual = append(actual, v.Original())
+			}
+			req.Equal(test.expected, actual)
+		})
+	}
+}
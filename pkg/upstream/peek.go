@@ -31,7 +31,7 @@ func getUpdatesUpstream(upstreamURI string, fetchOptions *FetchOptions) ([]Updat
 		return nil, errors.Wrap(err, "parse request uri failed")
 	}
 	if u.Scheme == "helm" {
-		return getUpdatesHelm(u, fetchOptions.HelmRepoURI)
+		return getUpdatesHelm(u, fetchOptions.HelmRepoURI, fetchOptions.HelmRepoCredentials, fetchOptions.CurrentCursor)
 	}
 	if u.Scheme == "replicated" {
 		cursor := ReplicatedCursor{
@@ -523,7 +523,7 @@ func createConfigValues(applicationName string, config *kotsv1beta1.Config, exis
 		for _, item := range group.Items {
 			var foundValue string
 			prevValue, ok := newValues.Values[item.Name]
-			if ok && prevValue.Value != "" {
+			if ok && prevValue.Value != "" && item.Type != template.ComputedItemType {
 				foundValue = prevValue.Value
 			}
 
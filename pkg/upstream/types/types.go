@@ -1,6 +1,7 @@
 package types
 
 import (
+	"io"
 	"path"
 
 	kotsscheme "github.com/replicatedhq/kots/kotskinds/client/kotsclientset/scheme"
@@ -33,6 +34,7 @@ type WriteOptions struct {
 	CreateAppDir        bool
 	IncludeAdminConsole bool
 	SharedPassword      string
+	ReportWriter        io.Writer
 }
 
 func (u *Upstream) GetBaseDir(options WriteOptions) string {
@@ -2,6 +2,7 @@ package upstream
 
 import (
 	"bytes"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
@@ -76,14 +77,34 @@ func WriteUpstream(u *types.Upstream, options types.WriteOptions) error {
 		}
 	}
 
+	// a config migration is applied once, to the values from before the release that it comes with
+	appliedConfigMigrations, err := getAppliedConfigMigrations(previousInstallationContent)
+	if err != nil {
+		return errors.Wrap(err, "failed to get applied config migrations")
+	}
+	configMigration := findConfigMigration(u.Files)
+	if configMigration != nil {
+		if isConfigMigrationApplied(appliedConfigMigrations, configMigration.Name) {
+			configMigration = nil
+		} else {
+			appliedConfigMigrations = append(appliedConfigMigrations, configMigration.Name)
+		}
+	}
+
 	if previousValuesContent != nil {
 		for i, f := range u.Files {
 			if f.Path == path.Join("userdata", "config.yaml") {
-				mergedValues, err := mergeValues(previousValuesContent, f.Content)
+				mergedValues, changes, err := mergeValues(previousValuesContent, f.Content, configMigration)
 				if err != nil {
 					return errors.Wrap(err, "failed to merge config values")
 				}
 
+				if options.ReportWriter != nil {
+					for _, change := range changes {
+						io.WriteString(options.ReportWriter, change.String()+"\n")
+					}
+				}
+
 				err = ioutil.WriteFile(path.Join(renderDir, "userdata", "config.yaml"), mergedValues, 0644)
 				if err != nil {
 					return errors.Wrap(err, "failed to replace configg values with previous config values")
@@ -114,11 +135,12 @@ func WriteUpstream(u *types.Upstream, options types.WriteOptions) error {
 			Name: u.Name,
 		},
 		Spec: kotsv1beta1.InstallationSpec{
-			UpdateCursor:  u.UpdateCursor,
-			ChannelName:   u.ChannelName,
-			VersionLabel:  u.VersionLabel,
-			ReleaseNotes:  u.ReleaseNotes,
-			EncryptionKey: encryptionKey,
+			UpdateCursor:     u.UpdateCursor,
+			ChannelName:      u.ChannelName,
+			VersionLabel:     u.VersionLabel,
+			ReleaseNotes:     u.ReleaseNotes,
+			EncryptionKey:    encryptionKey,
+			ConfigMigrations: appliedConfigMigrations,
 		},
 	}
 	if _, err := os.Stat(path.Join(renderDir, "userdata")); os.IsNotExist(err) {
@@ -155,22 +177,72 @@ func getEncryptionKey(previousInstallationContent []byte) (string, error) {
 	return installation.Spec.EncryptionKey, nil
 }
 
-func mergeValues(previousValues []byte, applicationDeliveredValues []byte) ([]byte, error) {
+func getAppliedConfigMigrations(previousInstallationContent []byte) ([]string, error) {
+	if previousInstallationContent == nil {
+		return []string{}, nil
+	}
+
+	decode := scheme.Codecs.UniversalDeserializer().Decode
+
+	prevObj, _, err := decode(previousInstallationContent, nil, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode previous installation")
+	}
+	installation := prevObj.(*kotsv1beta1.Installation)
+
+	return append([]string{}, installation.Spec.ConfigMigrations...), nil
+}
+
+func isConfigMigrationApplied(appliedConfigMigrations []string, name string) bool {
+	for _, applied := range appliedConfigMigrations {
+		if applied == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mergeValues applies the release's config migration, if any, to the previous values and then adds
+// the values delivered with the application for items that don't have a previous value. Delivered
+// values for items the migration renamed or removed are not added back, replicated upstreams seed
+// them from the existing values.
+func mergeValues(previousValues []byte, applicationDeliveredValues []byte, migration *kotsv1beta1.ConfigMigration) ([]byte, []ConfigMigrationChange, error) {
 	decode := scheme.Codecs.UniversalDeserializer().Decode
 
 	prevObj, _, err := decode(previousValues, nil, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to decode previous values")
+		return nil, nil, errors.Wrap(err, "failed to decode previous values")
 	}
 	prevValues := prevObj.(*kotsv1beta1.ConfigValues)
+	if prevValues.Spec.Values == nil {
+		prevValues.Spec.Values = map[string]kotsv1beta1.ConfigValue{}
+	}
 
 	applicationValuesObj, _, err := decode(applicationDeliveredValues, nil, nil)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to decode application delivered values")
+		return nil, nil, errors.Wrap(err, "failed to decode application delivered values")
 	}
 	applicationValues := applicationValuesObj.(*kotsv1beta1.ConfigValues)
 
+	changes, err := applyConfigMigration(migration, prevValues.Spec.Values)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to apply config migration")
+	}
+
+	migrated := map[string]bool{}
+	for _, change := range changes {
+		switch change.Action {
+		case "rename":
+			migrated[change.From] = true
+		case "remove":
+			migrated[change.Name] = true
+		}
+	}
+
 	for name, value := range applicationValues.Spec.Values {
+		if migrated[name] {
+			continue
+		}
 		_, ok := prevValues.Spec.Values[name]
 		if !ok {
 			prevValues.Spec.Values[name] = value
@@ -181,10 +253,10 @@ func mergeValues(previousValues []byte, applicationDeliveredValues []byte) ([]by
 
 	var b bytes.Buffer
 	if err := s.Encode(prevValues, &b); err != nil {
-		return nil, errors.Wrap(err, "failed to encode merged values")
+		return nil, nil, errors.Wrap(err, "failed to encode merged values")
 	}
 
-	return b.Bytes(), nil
+	return b.Bytes(), changes, nil
 }
 
 func mustMarshalInstallation(installation *kotsv1beta1.Installation) []byte {